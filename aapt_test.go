@@ -0,0 +1,225 @@
+package androidbinary
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// compareWithAapt compares the element tree of the binary XML file axml
+// with aaptOutput, the output of `aapt2 dump xmltree` for the same file.
+// Both are normalized before comparing: the namespace nodes, the line numbers and the raw values are dropped,
+// the indentation is replaced by the depth of the elements, and the hexadecimal numbers are compared in lower case.
+func compareWithAapt(t *testing.T, axml, aaptOutput []byte) {
+	t.Helper()
+	xmlFile, err := NewXMLFile(bytes.NewReader(axml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := normalizeXMLTree(dumpXMLTree(xmlFile))
+	want := normalizeXMLTree(string(aaptOutput))
+	if diff := diffXMLTree(got, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// diffXMLTree returns the first line that differs between the normalized trees got and want,
+// or an empty string if they are the same.
+func diffXMLTree(got, want []string) string {
+	for i := 0; i < len(got) || i < len(want); i++ {
+		var g, w string
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if g != w {
+			return fmt.Sprintf("line %d: got %q want %q", i+1, g, w)
+		}
+	}
+	return ""
+}
+
+// dumpXMLTree renders the element tree of f in the format of `aapt2 dump xmltree`.
+func dumpXMLTree(f *XMLFile) string {
+	var buf strings.Builder
+	var dump func(elem *XMLElement, depth int)
+	dump = func(elem *XMLElement, depth int) {
+		indent := strings.Repeat("  ", depth)
+		fmt.Fprintf(&buf, "%sE: %s (line=0)\n", indent, qualifiedName(elem.Namespace, elem.Name))
+		for i, attr := range elem.Attrs {
+			raw := elem.rawAttrs[i]
+			name := qualifiedName(attr.Namespace, attr.Name)
+			if int(raw.Name) < len(f.resourceIds) {
+				name += fmt.Sprintf("(0x%08x)", uint32(f.resourceIds[raw.Name]))
+			}
+			value := attr.Value
+			if raw.RawValue != NilResStringPoolRef {
+				value = `"` + value + `"`
+			}
+			fmt.Fprintf(&buf, "%s  A: %s=%s\n", indent, name, value)
+		}
+		for _, child := range elem.Children {
+			dump(child, depth+1)
+		}
+	}
+	if f.Root() != nil {
+		dump(f.Root(), 1)
+	}
+	return buf.String()
+}
+
+// qualifiedName returns the name in the "namespace-uri:local-name" form of aapt2.
+func qualifiedName(ns, name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	if ns == "" {
+		return name
+	}
+	return ns + ":" + name
+}
+
+var (
+	xmlTreeLineNumber = regexp.MustCompile(` \(line=\d+\)$`)
+	xmlTreeRawValue   = regexp.MustCompile(` \(Raw: ".*"\)$`)
+	xmlTreeHex        = regexp.MustCompile(`0x[0-9A-Fa-f]+`)
+)
+
+// normalizeXMLTree returns the lines of the output of `aapt2 dump xmltree` to compare.
+// The lines are indented by the depth of the elements, ignoring the namespace nodes
+// that aapt2 nests the elements in, so the children attached to wrong parents don't compare equal.
+func normalizeXMLTree(s string) []string {
+	var lines []string
+	var indents []int // the indentation of the ancestors of the current element
+	depth := 0
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "N: ") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(trimmed, "E: ") {
+			for len(indents) > 0 && indents[len(indents)-1] >= indent {
+				indents = indents[:len(indents)-1]
+			}
+			depth = len(indents)
+			indents = append(indents, indent)
+		}
+		trimmed = xmlTreeLineNumber.ReplaceAllString(trimmed, "")
+		trimmed = xmlTreeRawValue.ReplaceAllString(trimmed, "")
+		trimmed = xmlTreeHex.ReplaceAllStringFunc(trimmed, strings.ToLower)
+		lines = append(lines, strings.Repeat("  ", depth)+trimmed)
+	}
+	return lines
+}
+
+func TestCompareWithAapt(t *testing.T) {
+	cases := []struct {
+		axml   string
+		golden string
+	}{
+		{"testdata/AndroidManifest.xml", "testdata/aapt2/AndroidManifest.xmltree"},
+		{"testdata/MyApplication/AndroidManifest.xml", "testdata/aapt2/MyApplication.xmltree"},
+		{"apk/testdata/helloworld.apk", "testdata/aapt2/helloworld.xmltree"}, // AndroidManifest.xml in the APK
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.axml, func(t *testing.T) {
+			want, err := ioutil.ReadFile(c.golden)
+			if os.IsNotExist(err) {
+				t.Skipf("%s is not found; run testdata/aapt2/update.sh to generate it with aapt2", c.golden)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			axml, err := readAaptInput(c.axml)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareWithAapt(t, axml, want)
+		})
+	}
+}
+
+// readAaptInput reads the binary XML file name, or AndroidManifest.xml in it if it is an APK.
+func readAaptInput(name string) ([]byte, error) {
+	if !strings.HasSuffix(name, ".apk") {
+		return ioutil.ReadFile(name)
+	}
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != "AndroidManifest.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("AndroidManifest.xml is not found in %s", name)
+}
+
+func TestNormalizeXMLTree(t *testing.T) {
+	// the normalization ignores the details that differ from the output of aapt2.
+	aapt := `N: android=http://schemas.android.com/apk/res/android (line=2)
+  E: manifest (line=2)
+    A: http://schemas.android.com/apk/res/android:versionCode(0x0101021b)=1
+    A: package="com.example" (Raw: "com.example")
+      E: uses-permission (line=5)
+        A: http://schemas.android.com/apk/res/android:name(0x01010003)="android.permission.INTERNET" (Raw: "android.permission.INTERNET")
+`
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest",
+		testTypedAttr(testAndroidNS, "versionCode", 0x0101021b, TypeIntDec, 1),
+		testStringAttr("", "package", 0, "com.example"),
+	)
+	b.StartElement("", "uses-permission",
+		testStringAttr(testAndroidNS, "name", 0x01010003, "android.permission.INTERNET"),
+	)
+	b.EndElement("", "uses-permission")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	compareWithAapt(t, b.Bytes(), []byte(aapt))
+}
+
+func TestNormalizeXMLTreeDepth(t *testing.T) {
+	// the nested namespace nodes don't change the depth of the elements.
+	nested := `N: android=http://schemas.android.com/apk/res/android (line=2)
+  N: tools=http://schemas.android.com/tools (line=2)
+    E: manifest (line=2)
+      E: application (line=3)
+        E: activity (line=4)
+`
+	flat := `N: android=http://schemas.android.com/apk/res/android (line=2)
+  E: manifest (line=2)
+    E: application (line=3)
+      E: activity (line=4)
+`
+	if diff := diffXMLTree(normalizeXMLTree(nested), normalizeXMLTree(flat)); diff != "" {
+		t.Errorf("nested namespaces: %s", diff)
+	}
+
+	// the child attached to a wrong parent doesn't compare equal.
+	sibling := `N: android=http://schemas.android.com/apk/res/android (line=2)
+  E: manifest (line=2)
+    E: application (line=3)
+    E: activity (line=4)
+`
+	if diff := diffXMLTree(normalizeXMLTree(sibling), normalizeXMLTree(flat)); diff == "" {
+		t.Error("wrong parent: want difference")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"image"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/shogo82148/androidbinary"
 
@@ -19,6 +21,7 @@ type Apk struct {
 	f         *os.File
 	zipreader *zip.Reader
 	manifest  Manifest
+	xmlFile   *androidbinary.XMLFile
 	table     *androidbinary.TableFile
 }
 
@@ -51,13 +54,24 @@ func OpenZipReader(r io.ReaderAt, size int64) (*Apk, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewApk(zipreader)
+}
+
+// NewApk returns the Apk in zipreader, which the caller has already opened,
+// e.g. an APK in memory or in a custom file system, so the archive is not opened twice.
+// AndroidManifest.xml and resources.arsc are read from zipreader.
+// zipreader must remain readable while the Apk is used, and Close doesn't close it.
+func NewApk(zipreader *zip.Reader) (*Apk, error) {
+	if zipreader == nil {
+		return nil, newError("zip reader is nil")
+	}
 	apk := &Apk{
 		zipreader: zipreader,
 	}
-	if err = apk.parseResources(); err != nil {
-		return nil, err
+	if err := apk.parseResources(); err != nil {
+		return nil, errorf("parse-resources: %w", err)
 	}
-	if err = apk.parseManifest(); err != nil {
+	if err := apk.parseManifest(); err != nil {
 		return nil, errorf("parse-manifest: %w", err)
 	}
 	return apk, nil
@@ -73,13 +87,10 @@ func (k *Apk) Close() error {
 
 // Icon returns the icon image of the APK.
 func (k *Apk) Icon(resConfig *androidbinary.ResTableConfig) (image.Image, error) {
-	iconPath, err := k.manifest.App.Icon.WithResTableConfig(resConfig).String()
+	iconPath, err := k.IconPath(resConfig)
 	if err != nil {
 		return nil, err
 	}
-	if androidbinary.IsResID(iconPath) {
-		return nil, newError("unable to convert icon-id to icon path")
-	}
 	imgData, err := k.readZipFile(iconPath)
 	if err != nil {
 		return nil, err
@@ -88,6 +99,20 @@ func (k *Apk) Icon(resConfig *androidbinary.ResTableConfig) (image.Image, error)
 	return m, err
 }
 
+// IconPath returns the path of the icon file in the APK for resConfig, e.g. "res/mipmap-xxhdpi-v4/ic_launcher.png".
+// The icon of the density closest to the one of resConfig is selected, as Android does.
+// Note that the adaptive icons since API level 26 are XML files, e.g. "res/mipmap-anydpi-v26/ic_launcher.xml".
+func (k *Apk) IconPath(resConfig *androidbinary.ResTableConfig) (string, error) {
+	iconPath, err := k.manifest.App.Icon.WithResTableConfig(resConfig).String()
+	if err != nil {
+		return "", err
+	}
+	if androidbinary.IsResID(iconPath) {
+		return "", newError("unable to convert icon-id to icon path")
+	}
+	return iconPath, nil
+}
+
 // Label returns the label of the APK.
 func (k *Apk) Label(resConfig *androidbinary.ResTableConfig) (s string, err error) {
 	s, err = k.manifest.App.Label.WithResTableConfig(resConfig).String()
@@ -100,16 +125,77 @@ func (k *Apk) Label(resConfig *androidbinary.ResTableConfig) (s string, err erro
 	return
 }
 
+// OpenXML reads the XML file in the APK specified by path, e.g. "res/layout/activity_main.xml", and decodes it.
+// The path of a resource can be found with TableFile.PathForID.
+// Most of XML files in APKs are compiled by aapt, but some build tools store them in text format;
+// they are read by androidbinary.NewXMLFileFromText.
+func (k *Apk) OpenXML(path string) (*androidbinary.XMLFile, error) {
+	data, err := k.readZipFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	var xmlfile *androidbinary.XMLFile
+	switch {
+	case androidbinary.IsBinaryXML(r):
+		xmlfile, err = androidbinary.NewXMLFile(r)
+	case isTextXML(data):
+		xmlfile, err = androidbinary.NewXMLFileFromText(r)
+	default:
+		return nil, errorf("%s is not an XML file", path)
+	}
+	if err != nil {
+		return nil, errorf("failed to parse %s: %w", path, err)
+	}
+	return xmlfile, nil
+}
+
+// isTextXML reports whether data starts with an XML declaration or an element, after the byte order mark and spaces.
+func isTextXML(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '<'
+}
+
 // Manifest returns the manifest of the APK.
 func (k *Apk) Manifest() Manifest {
 	return k.manifest
 }
 
+// ManifestXML returns the decoded AndroidManifest.xml of the APK.
+func (k *Apk) ManifestXML() *androidbinary.XMLFile {
+	return k.xmlFile
+}
+
+// Resources returns the decoded resources.arsc of the APK.
+func (k *Apk) Resources() *androidbinary.TableFile {
+	return k.table
+}
+
 // PackageName returns the package name of the APK.
 func (k *Apk) PackageName() string {
 	return k.manifest.Package.MustString()
 }
 
+// NativeLibraries returns the names of the native libraries in the lib directory of the APK by the ABI,
+// e.g. {"arm64-v8a": {"libfoo.so"}, "x86_64": {"libfoo.so"}}, sorted by the name.
+// It returns an empty map if the APK has no native libraries.
+func (k *Apk) NativeLibraries() map[string][]string {
+	libs := make(map[string][]string)
+	for _, file := range k.zipreader.File {
+		// the libraries are stored as lib/<abi>/<name>.so
+		parts := strings.Split(file.Name, "/")
+		if len(parts) != 3 || parts[0] != "lib" || parts[1] == "" || !strings.HasSuffix(parts[2], ".so") {
+			continue
+		}
+		libs[parts[1]] = append(libs[parts[1]], parts[2])
+	}
+	for _, names := range libs {
+		sort.Strings(names)
+	}
+	return libs
+}
+
 func isMainIntentFilter(intent ActivityIntentFilter) bool {
 	ok := false
 	for _, action := range intent.Actions {
@@ -162,9 +248,13 @@ func (k *Apk) parseManifest() error {
 	if err != nil {
 		return errorf("failed to parse AndroidManifest.xml: %w", err)
 	}
+	k.xmlFile = xmlfile
 	return xmlfile.Decode(&k.manifest, k.table, nil)
 }
 
+// parseResources reads resources.arsc through archive/zip, which locates the file by the central directory,
+// so the files stored with data descriptors and the deflated files, which the zip alignment of APKs doesn't allow
+// but some build tools write, are read as well as the stored ones.
 func (k *Apk) parseResources() (err error) {
 	resData, err := k.readZipFile("resources.arsc")
 	if err != nil {
@@ -1,9 +1,16 @@
 package apk
 
 import (
+	"archive/zip"
+	"bytes"
 	_ "image/jpeg"
 	_ "image/png"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/shogo82148/androidbinary"
 )
 
 func TestParseAPKFile(t *testing.T) {
@@ -47,3 +54,380 @@ func TestParseAPKFile(t *testing.T) {
 		t.Errorf("MainActivity is not com.example.helloworld.MainActivity: %s", mainActivity)
 	}
 }
+
+func TestApkManifestXMLAndResources(t *testing.T) {
+	apk, err := OpenFile("testdata/helloworld.apk")
+	if err != nil {
+		t.Fatalf("OpenFile error: %v", err)
+	}
+	defer apk.Close()
+
+	xmlFile := apk.ManifestXML()
+	if xmlFile == nil {
+		t.Fatal("ManifestXML is nil")
+	}
+	if root := xmlFile.Root(); root == nil || root.Name != "manifest" {
+		t.Errorf("unexpected root element: %v", root)
+	}
+	if v, _ := xmlFile.Root().Attr("package"); v != "com.example.helloworld" {
+		t.Errorf("package is not com.example.helloworld: %s", v)
+	}
+
+	table := apk.Resources()
+	if table == nil {
+		t.Fatal("Resources is nil")
+	}
+	v, err := table.GetResourceByName("@string/app_name", nil)
+	if err != nil {
+		t.Fatalf("GetResourceByName error: %v", err)
+	}
+	if s := table.GetString(androidbinary.ResStringPoolRef(v.Data)); s != "HelloWorld" {
+		t.Errorf("app_name is not HelloWorld: %s", s)
+	}
+}
+
+func TestApkLabelAndIconPath(t *testing.T) {
+	apk, err := NewApk(newTestZipReader(t, map[string][]byte{
+		"resources.arsc": newLocalizedResources(t, "ja", "完了"),
+	}))
+	if err != nil {
+		t.Fatalf("NewApk error: %v", err)
+	}
+
+	labels := []struct {
+		lang string
+		want string
+	}{
+		{"", "HelloWorld"},
+		{"ja", "完了"},
+		// the label is not translated to French, so it falls back to the default.
+		{"fr", "HelloWorld"},
+	}
+	for _, c := range labels {
+		config := &androidbinary.ResTableConfig{}
+		copy(config.Language[:], c.lang)
+		label, err := apk.Label(config)
+		if err != nil {
+			t.Errorf("%q: Label error: %v", c.lang, err)
+			continue
+		}
+		if label != c.want {
+			t.Errorf("%q: got %s want %s", c.lang, label, c.want)
+		}
+	}
+
+	icons := []struct {
+		density uint16
+		want    string
+	}{
+		{120, "res/mipmap-mdpi-v4/ic_launcher.png"},
+		{160, "res/mipmap-mdpi-v4/ic_launcher.png"},
+		{240, "res/mipmap-hdpi-v4/ic_launcher.png"},
+		{320, "res/mipmap-xhdpi-v4/ic_launcher.png"},
+		{480, "res/mipmap-xxhdpi-v4/ic_launcher.png"},
+		{640, "res/mipmap-xxxhdpi-v4/ic_launcher.png"},
+	}
+	for _, c := range icons {
+		path, err := apk.IconPath(&androidbinary.ResTableConfig{Density: c.density, SDKVersion: 24})
+		if err != nil {
+			t.Errorf("%d: IconPath error: %v", c.density, err)
+			continue
+		}
+		if path != c.want {
+			t.Errorf("%d: got %s want %s", c.density, path, c.want)
+		}
+	}
+}
+
+// newLocalizedResources returns resources.arsc of helloworld.apk with app_name translated to lang.
+// The translation must be a string in the pool, which has the translations of the support library.
+func newLocalizedResources(t *testing.T, lang, label string) []byte {
+	t.Helper()
+	apk, err := OpenFile("testdata/helloworld.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer apk.Close()
+	table := apk.Resources()
+
+	config := &androidbinary.ResTableConfig{}
+	copy(config.Language[:], lang)
+	var id androidbinary.ResID
+	var ref androidbinary.ResStringPoolRef
+	found := false
+	table.Entries(config)(func(rid androidbinary.ResID, name string, value androidbinary.ResValue) bool {
+		if strings.HasSuffix(name, ":string/app_name") {
+			id = rid
+		}
+		if value.DataType == androidbinary.TypeString && table.GetString(androidbinary.ResStringPoolRef(value.Data)) == label {
+			ref = androidbinary.ResStringPoolRef(value.Data)
+			found = true
+		}
+		return true
+	})
+	if id == 0 || !found {
+		t.Fatalf("app_name or %q is not found", label)
+	}
+
+	// copy the entry of the default configuration into the type of lang, with the translated string.
+	pkg := table.Package(id.Package())
+	var key *androidbinary.ResTableEntry
+	var typ *androidbinary.TableType
+	for _, tt := range pkg.TableTypes {
+		if int(tt.Header.ID) != id.Type() {
+			continue
+		}
+		switch tt.Header.Config.Locale() {
+		case "":
+			key = tt.Entries[id.Entry()].Key
+		case lang:
+			typ = tt
+		}
+	}
+	if key == nil || typ == nil {
+		t.Fatalf("no string type for %s", lang)
+	}
+	typ.Entries[id.Entry()] = androidbinary.TableEntry{
+		Key: key,
+		Value: &androidbinary.ResValue{
+			Size:     8,
+			DataType: androidbinary.TypeString,
+			Data:     uint32(ref),
+		},
+	}
+	for _, spec := range pkg.TypeSpecs {
+		if int(spec.Header.ID) == id.Type() {
+			spec.Flags[id.Entry()] |= androidbinary.ConfigLocale
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := table.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newTestZipReader returns an in-memory zip archive with the manifest and the resources of helloworld.apk,
+// and the extra files, which replace the manifest or the resources of the same name.
+func newTestZipReader(t *testing.T, extra map[string][]byte) *zip.Reader {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/helloworld.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	create := func(name string, content []byte) {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range src.File {
+		if f.Name != "AndroidManifest.xml" && f.Name != "resources.arsc" {
+			continue
+		}
+		if _, ok := extra[f.Name]; ok {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		create(f.Name, content)
+	}
+	for name, content := range extra {
+		create(name, content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestNewApk(t *testing.T) {
+	apk, err := NewApk(newTestZipReader(t, nil))
+	if err != nil {
+		t.Fatalf("NewApk error: %v", err)
+	}
+	defer apk.Close()
+	if apk.PackageName() != "com.example.helloworld" {
+		t.Errorf("PackageName is not com.example.helloworld: %s", apk.PackageName())
+	}
+	label, err := apk.Label(nil)
+	if err != nil {
+		t.Fatalf("Label error: %v", err)
+	}
+	if label != "HelloWorld" {
+		t.Errorf("Label is not HelloWorld: %s", label)
+	}
+
+	if _, err := NewApk(nil); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestApkOpenXML(t *testing.T) {
+	apk, err := OpenFile("testdata/helloworld.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer apk.Close()
+
+	xmlFile, err := apk.OpenXML("res/layout/activity_main.xml")
+	if err != nil {
+		t.Fatalf("OpenXML error: %v", err)
+	}
+	root := xmlFile.Root()
+	if root == nil || root.Name != "android.support.constraint.ConstraintLayout" {
+		t.Fatalf("unexpected root: %#v", root)
+	}
+	if len(root.Children) != 1 || root.Children[0].Name != "TextView" {
+		t.Errorf("unexpected children: %#v", root.Children)
+	}
+
+	if _, err := apk.OpenXML("res/layout/not_found.xml"); err == nil {
+		t.Error("want error")
+	}
+	if _, err := apk.OpenXML("resources.arsc"); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestApkOpenXMLText(t *testing.T) {
+	zr := newTestZipReader(t, map[string][]byte{
+		"res/xml/config.xml": []byte(`<?xml version="1.0" encoding="utf-8"?>
+<config xmlns:android="http://schemas.android.com/apk/res/android">
+    <item android:name="foo">bar</item>
+</config>
+`),
+		"res/raw/data.bin": {0x00, 0x01, 0x02, 0x03},
+	})
+	apk, err := NewApk(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlFile, err := apk.OpenXML("res/xml/config.xml")
+	if err != nil {
+		t.Fatalf("OpenXML error: %v", err)
+	}
+	items := xmlFile.Find("/config/item")
+	if len(items) != 1 {
+		t.Fatalf("unexpected items: %#v", items)
+	}
+	if v, _ := items[0].Attr("android:name"); v != "foo" {
+		t.Errorf("android:name is not foo: %s", v)
+	}
+
+	if _, err := apk.OpenXML("res/raw/data.bin"); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestApkNativeLibraries(t *testing.T) {
+	zr := newTestZipReader(t, map[string][]byte{
+		"lib/arm64-v8a/libfoo.so":       {0x7f, 'E', 'L', 'F'},
+		"lib/arm64-v8a/libbar.so":       {0x7f, 'E', 'L', 'F'},
+		"lib/x86_64/libfoo.so":          {0x7f, 'E', 'L', 'F'},
+		"lib/x86_64/README.txt":         []byte("not a library"),
+		"assets/lib/x86/libbaz.so":      {0x7f, 'E', 'L', 'F'},
+		"lib/armeabi-v7a/sub/libqux.so": {0x7f, 'E', 'L', 'F'},
+	})
+	apk, err := NewApk(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := apk.NativeLibraries()
+	want := map[string][]string{
+		"arm64-v8a": {"libbar.so", "libfoo.so"},
+		"x86_64":    {"libfoo.so"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	apk, err = NewApk(newTestZipReader(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := apk.NativeLibraries(); len(got) != 0 {
+		t.Errorf("got %v want empty", got)
+	}
+}
+
+func TestNewApkResourcesMethods(t *testing.T) {
+	src, err := zip.OpenReader("testdata/helloworld.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	contents := make(map[string][]byte)
+	for _, f := range src.File {
+		if f.Name != "AndroidManifest.xml" && f.Name != "resources.arsc" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name], err = ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		buf := new(bytes.Buffer)
+		w := zip.NewWriter(buf)
+		for _, name := range []string{"AndroidManifest.xml", "resources.arsc"} {
+			// zip.Writer writes the sizes in a data descriptor after the data,
+			// and leaves them zero in the local file header.
+			fw, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(contents[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		apk, err := NewApk(zr)
+		if err != nil {
+			t.Fatalf("method %d: NewApk error: %v", method, err)
+		}
+		label, err := apk.Label(nil)
+		if err != nil {
+			t.Fatalf("method %d: Label error: %v", method, err)
+		}
+		if label != "HelloWorld" {
+			t.Errorf("method %d: got %q want HelloWorld", method, label)
+		}
+	}
+}
@@ -1,5 +1,16 @@
 package androidbinary
 
+//go:generate go run gen_attributes.go
+
+// getAttributteName returns the name of the attribute of the android framework.
+// It returns an empty string if id is not a known attribute.
+// The table covers the attributes up to API level 30 (android:gwpAsanMode, 0x01010616);
+// run go generate to replace it with the output of gen_attributes.go for the later ones.
+//
+// The switch is compiled into a binary search over the constant ids, which is faster than
+// looking up a map or caching the results, and it is safe for concurrent use.
+// See BenchmarkGetAttributteName.
+//
 // https://github.com/skylot/jadx/blob/master/jadx-core/src/main/resources/android/res-map.txt
 func getAttributteName(id ResStringPoolRef) string {
 	switch id {
@@ -0,0 +1,78 @@
+package androidbinary
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestGetAttributteName(t *testing.T) {
+	cases := []struct {
+		id   ResStringPoolRef
+		want string
+	}{
+		{0x01010000, "theme"},
+		{0x01010001, "label"},
+		{0x01010002, "icon"},
+		{0x01010003, "name"},
+		{0x010100d0, "id"},
+		{0x010100f4, "layout_width"},
+		{0x010100f5, "layout_height"},
+		{0x01010010, "exported"},
+		{0x01010616, "gwpAsanMode"},
+		{0x7F010000, ""},
+		{NilResStringPoolRef, ""},
+	}
+
+	// it may be called from multiple goroutines.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, c := range cases {
+				if got := getAttributteName(c.id); got != c.want {
+					t.Errorf("0x%08X: got %q want %q", uint32(c.id), got, c.want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkGetAttributteName(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		getAttributteName(ResStringPoolRef(0x01010000 + i%0x0700))
+	}
+}
+
+func BenchmarkNewXMLFileManyAttributes(b *testing.B) {
+	// a manifest with hundreds of android attributes
+	builder := new(testXMLBuilder)
+	builder.StartNamespace("android", testAndroidNS)
+	builder.StartElement("", "manifest")
+	builder.StartElement("", "application")
+	for i := 0; i < 200; i++ {
+		builder.StartElement("", "activity",
+			testStringAttr(testAndroidNS, "name", 0x01010003, fmt.Sprintf(".Activity%d", i)),
+			testTypedAttr(testAndroidNS, "label", 0x01010001, TypeReference, 0x7F0B0000),
+			testTypedAttr(testAndroidNS, "theme", 0x01010000, TypeReference, 0x7F0C0000),
+			testTypedAttr(testAndroidNS, "exported", 0x01010010, TypeIntBoolean, 0),
+			testTypedAttr(testAndroidNS, "screenOrientation", 0x0101001e, TypeIntDec, 1),
+		)
+		builder.EndElement("", "activity")
+	}
+	builder.EndElement("", "application")
+	builder.EndElement("", "manifest")
+	builder.EndNamespace("android", testAndroidNS)
+	data := builder.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewXMLFile(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,8 +3,17 @@ package androidbinary
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"html"
+	"image/color"
 	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // ChunkType is a type of a resource chunk.
@@ -34,6 +43,12 @@ const (
 	ResTablePackageType  ChunkType = 0x0200
 	ResTableTypeType     ChunkType = 0x0201
 	ResTableTypeSpecType ChunkType = 0x0202
+	ResTableLibraryType  ChunkType = 0x0203
+
+	// Chunk types in RES_TABLE_PACKAGE_TYPE, added by newer versions of aapt2
+	ResTableOverlayableType       ChunkType = 0x0204
+	ResTableOverlayablePolicyType ChunkType = 0x0205
+	ResTableStagedAliasType       ChunkType = 0x0206
 )
 
 // ResChunkHeader is a header of a resource chunk.
@@ -43,6 +58,16 @@ type ResChunkHeader struct {
 	Size       uint32
 }
 
+func validateChunkHeader(header *ResChunkHeader) error {
+	if header.HeaderSize < uint16(binary.Size(header)) {
+		return fmt.Errorf("androidbinary: invalid chunk header size: %d", header.HeaderSize)
+	}
+	if header.Size < uint32(header.HeaderSize) {
+		return fmt.Errorf("androidbinary: invalid chunk size: %d", header.Size)
+	}
+	return nil
+}
+
 // Flags are flags for string pool header.
 type Flags uint32
 
@@ -52,6 +77,22 @@ const (
 	UTF8Flag   Flags = 1 << 8
 )
 
+// UnsupportedEncodingError is returned for the string pools whose flags have the bits that this package doesn't know,
+// e.g. a future encoding of the strings.
+//
+// Note that this is stricter than Android, which ignores the unknown bits and reads such pools
+// as UTF-8 or UTF-16 by UTF8Flag alone. None of the unknown bits change how the strings are decoded today,
+// so the files that Android installs, e.g. APKs whose flags are tampered by obfuscators, may be rejected.
+// The error is reported instead of reading the strings in a possibly wrong encoding.
+type UnsupportedEncodingError struct {
+	// Flags are the flags of the string pool header.
+	Flags Flags
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("androidbinary: unsupported string pool encoding: flags 0x%08X", uint32(e.Flags))
+}
+
 // ResStringPoolHeader is a chunk header of string pool.
 type ResStringPoolHeader struct {
 	Header      ResChunkHeader
@@ -70,9 +111,40 @@ type ResStringPoolSpan struct {
 
 // ResStringPool is a string pool resource.
 type ResStringPool struct {
-	Header  ResStringPoolHeader
+	Header ResStringPoolHeader
+
+	// Strings are the strings of the pool.
+	// It is nil if the pool is read lazily, see TableOptions.LazyStrings. Use GetString instead.
 	Strings []string
 	Styles  []ResStringPoolSpan
+
+	// the raw style data, kept to encode the pool again.
+	styleStarts []uint32
+	styleData   []byte
+
+	// lazy decodes the strings on demand if the pool is read lazily.
+	lazy *lazyStrings
+}
+
+// lazyStrings decodes the strings of a pool on first use from the section of the pool.
+type lazyStrings struct {
+	mu      sync.Mutex
+	sr      *io.SectionReader
+	starts  []uint32
+	utf8    bool
+	strings []string
+	decoded []bool
+}
+
+func (l *lazyStrings) get(i int) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.decoded[i] {
+		// the broken strings are decoded as empty strings, as GetString can't fail.
+		l.strings[i], _ = readString(l.sr, int64(l.starts[i]), l.utf8)
+		l.decoded[i] = true
+	}
+	return l.strings[i]
 }
 
 // NilResStringPoolRef is nil reference for string pool.
@@ -86,24 +158,26 @@ type DataType uint8
 
 // The constants for DataType
 const (
-	TypeNull          DataType = 0x00
-	TypeReference     DataType = 0x01
-	TypeAttribute     DataType = 0x02
-	TypeString        DataType = 0x03
-	TypeFloat         DataType = 0x04
-	TypeDemention     DataType = 0x05
-	TypeFraction      DataType = 0x06
-	TypeFirstInt      DataType = 0x10
-	TypeIntDec        DataType = 0x10
-	TypeIntHex        DataType = 0x11
-	TypeIntBoolean    DataType = 0x12
-	TypeFirstColorInt DataType = 0x1c
-	TypeIntColorARGB8 DataType = 0x1c
-	TypeIntColorRGB8  DataType = 0x1d
-	TypeIntColorARGB4 DataType = 0x1e
-	TypeIntColorRGB4  DataType = 0x1f
-	TypeLastColorInt  DataType = 0x1f
-	TypeLastInt       DataType = 0x1f
+	TypeNull             DataType = 0x00
+	TypeReference        DataType = 0x01
+	TypeAttribute        DataType = 0x02
+	TypeString           DataType = 0x03
+	TypeFloat            DataType = 0x04
+	TypeDemention        DataType = 0x05
+	TypeFraction         DataType = 0x06
+	TypeDynamicReference DataType = 0x07
+	TypeDynamicAttribute DataType = 0x08
+	TypeFirstInt         DataType = 0x10
+	TypeIntDec           DataType = 0x10
+	TypeIntHex           DataType = 0x11
+	TypeIntBoolean       DataType = 0x12
+	TypeFirstColorInt    DataType = 0x1c
+	TypeIntColorARGB8    DataType = 0x1c
+	TypeIntColorRGB8     DataType = 0x1d
+	TypeIntColorARGB4    DataType = 0x1e
+	TypeIntColorRGB4     DataType = 0x1f
+	TypeLastColorInt     DataType = 0x1f
+	TypeLastInt          DataType = 0x1f
 )
 
 // ResValue is a representation of a value in a resource
@@ -114,9 +188,211 @@ type ResValue struct {
 	Data     uint32
 }
 
+// The units of the complex values of TypeDemention and TypeFraction.
+const (
+	ComplexUnitPx  = 0
+	ComplexUnitDip = 1
+	ComplexUnitSp  = 2
+	ComplexUnitPt  = 3
+	ComplexUnitIn  = 4
+	ComplexUnitMm  = 5
+
+	ComplexUnitFraction       = 0 // a fraction of the object itself, e.g. 50%
+	ComplexUnitFractionParent = 1 // a fraction of the parent, e.g. 50%p
+)
+
+const (
+	complexUnitMask     = 0xf
+	complexRadixShift   = 4
+	complexRadixMask    = 0x3
+	complexMantissaMask = 0xffffff00
+)
+
+// complexRadixMults are the multipliers of the mantissa for each radix:
+// 23p0, 16p7, 8p15 and 0p23, where the mantissa is shifted left by 8 bits.
+var complexRadixMults = [4]float32{
+	1.0 / (1 << 8),
+	1.0 / (1 << 7) / (1 << 8),
+	1.0 / (1 << 15) / (1 << 8),
+	1.0 / (1 << 23) / (1 << 8),
+}
+
+// ComplexToFloat returns the value of the complex data of TypeDemention and TypeFraction,
+// in the same way as Android's complexToFloat does.
+// For TypeFraction, 1.0 means 100%.
+func ComplexToFloat(complex uint32) float32 {
+	mantissa := int32(complex & complexMantissaMask)
+	return float32(mantissa) * complexRadixMults[(complex>>complexRadixShift)&complexRadixMask]
+}
+
+// ComplexUnit returns the unit of the complex data of TypeDemention and TypeFraction,
+// e.g. ComplexUnitDip or ComplexUnitFractionParent.
+func ComplexUnit(complex uint32) int {
+	return int(complex & complexUnitMask)
+}
+
+// Interface returns the value as a Go value of the type for its data type:
+//
+//	TypeNull                               nil
+//	TypeReference, TypeDynamicReference    the resolved value if table is not nil, otherwise ResID;
+//	                                       ResID of the bag for the references to bags, e.g. styles
+//	TypeAttribute, TypeDynamicAttribute    ResID, as attributes are resolved by themes
+//	TypeString                             string in the global string pool of table
+//	TypeFloat                              float32
+//	TypeDemention, TypeFraction            float32 in the unit of ComplexUnit(v.Data)
+//	TypeIntDec                             int
+//	TypeIntHex                             uint32
+//	TypeIntBoolean                         bool, true for any nonzero data
+//	TypeIntColorARGB8 and the other colors color.NRGBA, as Android colors are not premultiplied
+//
+// The values of the other data types are returned as uint32.
+func (v ResValue) Interface(table *TableFile, config *ResTableConfig) (interface{}, error) {
+	switch v.DataType {
+	case TypeNull:
+		return nil, nil
+	case TypeReference, TypeDynamicReference:
+		if table == nil {
+			return ResID(v.Data), nil
+		}
+		id, e, err := table.resolveReference(ResID(v.Data), config)
+		if err != nil {
+			return nil, err
+		}
+		if e.Key != nil && e.Key.Flags&EntryFlagComplex != 0 {
+			// bags, e.g. styles, have no single value.
+			return id, nil
+		}
+		return e.Value.Interface(table, config)
+	case TypeAttribute, TypeDynamicAttribute:
+		return ResID(v.Data), nil
+	case TypeString:
+		if table == nil || !table.HasString(ResStringPoolRef(v.Data)) {
+			return nil, &InvalidReferenceError{Ref: ResStringPoolRef(v.Data)}
+		}
+		return table.GetString(ResStringPoolRef(v.Data)), nil
+	case TypeFloat:
+		return math.Float32frombits(v.Data), nil
+	case TypeDemention, TypeFraction:
+		return ComplexToFloat(v.Data), nil
+	case TypeIntDec:
+		return int(int32(v.Data)), nil
+	case TypeIntHex:
+		return v.Data, nil
+	case TypeIntBoolean:
+		return v.Data != 0, nil
+	case TypeIntColorARGB8, TypeIntColorRGB8, TypeIntColorARGB4, TypeIntColorRGB4:
+		argb := colorARGB(v.DataType, v.Data)
+		return color.NRGBA{
+			A: uint8(argb >> 24),
+			R: uint8(argb >> 16),
+			G: uint8(argb >> 8),
+			B: uint8(argb),
+		}, nil
+	}
+	return v.Data, nil
+}
+
+// String returns the value in the text format of the attribute values in XMLFile, e.g.
+// "@0x7F0B0027" for the references, "?0x01010036" for the attributes, "-1" for TypeIntDec,
+// "0x00000010" for TypeIntHex, "true" for TypeIntBoolean, "1.5" for TypeFloat, "14sp" for TypeDemention,
+// "50%p" for TypeFraction and "#ff00ff00" for the colors.
+// The units of the dimensions and the fractions are the ones of Android's TypedValue.coerceToString:
+// "px", "dip", "sp", "pt", "in", "mm", "%" and "%p"; the unknown units are omitted.
+// TypeString is rendered as an empty string, as the string is in a string pool that the value doesn't have.
+// The data types that this package doesn't know are rendered as the references, as they have always been.
+//
+// As aapt does, TypeIntBoolean is rendered as "true" or "false".
+// aapt stores true as 0xFFFFFFFF, but Android reads any nonzero data as true, and so does String.
+func (v ResValue) String() string {
+	switch v.DataType {
+	case TypeNull, TypeString:
+		return ""
+	case TypeAttribute, TypeDynamicAttribute:
+		return fmt.Sprintf("?0x%08X", v.Data)
+	case TypeFloat:
+		return formatFloat(math.Float32frombits(v.Data))
+	case TypeDemention:
+		return formatFloat(ComplexToFloat(v.Data)) + complexUnitSuffix(dimensionUnits[:], v.Data)
+	case TypeFraction:
+		return formatFloat(ComplexToFloat(v.Data)*100) + complexUnitSuffix(fractionUnits[:], v.Data)
+	case TypeIntDec:
+		return strconv.FormatInt(int64(int32(v.Data)), 10)
+	case TypeIntHex:
+		return fmt.Sprintf("0x%08X", v.Data)
+	case TypeIntBoolean:
+		if v.Data != 0 {
+			return "true"
+		}
+		return "false"
+	case TypeIntColorARGB8, TypeIntColorRGB8, TypeIntColorARGB4, TypeIntColorRGB4:
+		return formatColor(v.DataType, v.Data)
+	}
+	return fmt.Sprintf("@0x%08X", v.Data)
+}
+
+// dimensionUnits and fractionUnits are the suffixes of the units of TypeDemention and TypeFraction.
+var (
+	dimensionUnits = [...]string{
+		ComplexUnitPx:  "px",
+		ComplexUnitDip: "dip",
+		ComplexUnitSp:  "sp",
+		ComplexUnitPt:  "pt",
+		ComplexUnitIn:  "in",
+		ComplexUnitMm:  "mm",
+	}
+	fractionUnits = [...]string{
+		ComplexUnitFraction:       "%",
+		ComplexUnitFractionParent: "%p",
+	}
+)
+
+// complexUnitSuffix returns the suffix of the unit of the complex data in units, or "" if the unit is unknown.
+func complexUnitSuffix(units []string, complex uint32) string {
+	if unit := ComplexUnit(complex); unit < len(units) {
+		return units[unit]
+	}
+	return ""
+}
+
+// formatFloat formats f in the shortest decimal form that reads back as f, e.g. "14" or "1.5".
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+// resValueSize is the size of ResValue in the binary files.
+const resValueSize = 8
+
+// ReadResValue reads a ResValue in the binary format, i.e. the 8 bytes in little endian.
+// It returns io.EOF if r has no bytes, and io.ErrUnexpectedEOF if r ends in the middle of the value.
+func ReadResValue(r io.Reader) (ResValue, error) {
+	var v ResValue
+	if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+		return ResValue{}, err
+	}
+	return v, nil
+}
+
+// Marshal returns v in the binary format that ReadResValue reads.
+// The fields are written as they are, so Size should be 8 for Android to accept the value.
+func (v ResValue) Marshal() []byte {
+	buf := make([]byte, resValueSize)
+	binary.LittleEndian.PutUint16(buf[0:], v.Size)
+	buf[2] = v.Res0
+	buf[3] = uint8(v.DataType)
+	binary.LittleEndian.PutUint32(buf[4:], v.Data)
+	return buf
+}
+
 // GetString returns a string referenced by ref.
-// It panics if the pool doesn't contain ref.
+// It returns an empty string if pool is nil, e.g. the global string pool of a TableFile that has none,
+// and it panics if the pool doesn't contain ref; use HasString to check it first.
 func (pool *ResStringPool) GetString(ref ResStringPoolRef) string {
+	if pool == nil {
+		return ""
+	}
+	if pool.lazy != nil {
+		return pool.lazy.get(int(ref))
+	}
 	return pool.Strings[int(ref)]
 }
 
@@ -125,41 +401,250 @@ func (pool *ResStringPool) HasString(ref ResStringPoolRef) bool {
 	if pool == nil {
 		return false
 	}
-	return int(ref) >= 0 && int(ref) < len(pool.Strings)
+	return int(ref) >= 0 && int(ref) < pool.count()
+}
+
+// count returns the number of the strings in the pool.
+func (pool *ResStringPool) count() int {
+	if pool.lazy != nil {
+		return len(pool.lazy.starts)
+	}
+	return len(pool.Strings)
+}
+
+// strings returns all the strings in the pool, decoding them if the pool is read lazily.
+func (pool *ResStringPool) strings() []string {
+	if pool.lazy == nil {
+		return pool.Strings
+	}
+	strs := make([]string, pool.count())
+	for i := range strs {
+		strs[i] = pool.lazy.get(i)
+	}
+	return strs
+}
+
+// indexOf returns the index of the string s in the pool.
+// If the pool has SortedFlag, s is found by binary search as Android does, otherwise by linear scan.
+func (pool *ResStringPool) indexOf(s string) (ResStringPoolRef, bool) {
+	if pool == nil {
+		return 0, false
+	}
+	n := pool.count()
+	if pool.Header.Flags&SortedFlag != 0 {
+		// both the UTF-8 and the UTF-16 pools are sorted by UTF-16 units,
+		// as indexOfString in Android compares them with strzcmp16.
+		i := sort.Search(n, func(i int) bool {
+			return compareUTF16(pool.GetString(ResStringPoolRef(i)), s) >= 0
+		})
+		if i < n && pool.GetString(ResStringPoolRef(i)) == s {
+			return ResStringPoolRef(i), true
+		}
+		return 0, false
+	}
+	for i := 0; i < n; i++ {
+		if pool.GetString(ResStringPoolRef(i)) == s {
+			return ResStringPoolRef(i), true
+		}
+	}
+	return 0, false
+}
+
+// compareUTF16 compares a and b in the order of their UTF-16 units, as strzcmp16 in Android does.
+// It differs from the order of the code points for the characters after U+E000 and the surrogate pairs.
+func compareUTF16(a, b string) int {
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			ua, ub := firstUTF16Unit(ra), firstUTF16Unit(rb)
+			if ua == ub {
+				// both are surrogate pairs with the same high surrogate.
+				ua, ub = ra, rb
+			}
+			if ua < ub {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+// firstUTF16Unit returns the first UTF-16 unit of r.
+func firstUTF16Unit(r rune) rune {
+	if r < 0x10000 {
+		return r
+	}
+	high, _ := utf16.EncodeRune(r)
+	return high
+}
+
+// styleSpan is a span of the style of a string, as ResStringPool_span in Android.
+// Name refers to the tag in the pool, e.g. "b" or "font;color=#ff0000",
+// and FirstChar and LastChar are the inclusive range of the span in UTF-16 units.
+type styleSpan struct {
+	Name                ResStringPoolRef
+	FirstChar, LastChar uint32
+}
+
+// styleSpanEnd terminates the spans of a string.
+const styleSpanEnd = 0xFFFFFFFF
+
+// spans returns the style spans of the string ref.
+// The strings without styles and the broken style data have no spans.
+func (pool *ResStringPool) spans(ref ResStringPoolRef) []styleSpan {
+	if pool == nil || int(ref) < 0 || int(ref) >= len(pool.styleStarts) {
+		return nil
+	}
+	var spans []styleSpan
+	for offset := int(pool.styleStarts[ref]); offset >= 0 && offset+4 <= len(pool.styleData); offset += 12 {
+		name := binary.LittleEndian.Uint32(pool.styleData[offset:])
+		if name == styleSpanEnd {
+			return spans
+		}
+		if offset+12 > len(pool.styleData) || !pool.HasString(ResStringPoolRef(name)) {
+			return nil
+		}
+		spans = append(spans, styleSpan{
+			Name:      ResStringPoolRef(name),
+			FirstChar: binary.LittleEndian.Uint32(pool.styleData[offset+4:]),
+			LastChar:  binary.LittleEndian.Uint32(pool.styleData[offset+8:]),
+		})
+	}
+	return nil
+}
+
+// GetStyledString returns the string referenced by ref with its style spans reconstructed as HTML-like tags,
+// e.g. "Hello <b>world</b>" or `<font color="#ff0000">red</font>`.
+// The text and the values of the attributes are escaped, so the result is always markup.
+// It panics if the pool doesn't contain ref.
+func (pool *ResStringPool) GetStyledString(ref ResStringPoolRef) string {
+	str := pool.GetString(ref)
+	spans := pool.spans(ref)
+	if len(spans) == 0 {
+		return html.EscapeString(str)
+	}
+
+	var buf strings.Builder
+	var open []styleSpan
+	closeTags := func(pos uint32) {
+		for len(open) > 0 && open[len(open)-1].LastChar < pos {
+			tag := pool.GetString(open[len(open)-1].Name)
+			if i := strings.IndexByte(tag, ';'); i >= 0 {
+				tag = tag[:i]
+			}
+			buf.WriteString("</" + tag + ">")
+			open = open[:len(open)-1]
+		}
+	}
+	units := utf16.Encode([]rune(str))
+	next := 0
+	for pos := 0; pos <= len(units); pos++ {
+		closeTags(uint32(pos))
+		for ; next < len(spans) && spans[next].FirstChar <= uint32(pos); next++ {
+			buf.WriteString(styleStartTag(pool.GetString(spans[next].Name)))
+			open = append(open, spans[next])
+		}
+		if pos == len(units) {
+			break
+		}
+		// don't split the surrogate pairs.
+		end := pos + 1
+		if utf16.IsSurrogate(rune(units[pos])) && end < len(units) {
+			end++
+		}
+		buf.WriteString(html.EscapeString(string(utf16.Decode(units[pos:end]))))
+		pos = end - 1
+	}
+	closeTags(^uint32(0))
+	return buf.String()
+}
+
+// styleStartTag returns the start tag of the style tag, e.g. `<font color="#ff0000">` for "font;color=#ff0000".
+func styleStartTag(tag string) string {
+	parts := strings.Split(tag, ";")
+	var buf strings.Builder
+	buf.WriteString("<" + parts[0])
+	for _, attr := range parts[1:] {
+		name, value := attr, ""
+		if i := strings.IndexByte(attr, '='); i >= 0 {
+			name, value = attr[:i], attr[i+1:]
+		}
+		buf.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	buf.WriteString(">")
+	return buf.String()
 }
 
 func readStringPool(sr *io.SectionReader) (*ResStringPool, error) {
+	return readStringPoolMode(sr, false)
+}
+
+// readLazyStringPool reads the header of the string pool in sr,
+// and the strings are decoded from sr when they are used.
+// sr must remain readable while the pool is used.
+func readLazyStringPool(sr *io.SectionReader) (*ResStringPool, error) {
+	return readStringPoolMode(sr, true)
+}
+
+func readStringPoolMode(sr *io.SectionReader, lazy bool) (*ResStringPool, error) {
 	sp := new(ResStringPool)
 	if err := binary.Read(sr, binary.LittleEndian, &sp.Header); err != nil {
 		return nil, err
 	}
+	if sp.Header.Flags&^(SortedFlag|UTF8Flag) != 0 {
+		// Android ignores the unknown bits; see UnsupportedEncodingError.
+		return nil, &UnsupportedEncodingError{Flags: sp.Header.Flags}
+	}
 
-	stringStarts := make([]uint32, sp.Header.StringCount)
-	if err := binary.Read(sr, binary.LittleEndian, stringStarts); err != nil {
+	stringStarts, err := readUint32s(sr, sp.Header.StringCount)
+	if err != nil {
 		return nil, err
 	}
 
-	styleStarts := make([]uint32, sp.Header.StyleCount)
-	if err := binary.Read(sr, binary.LittleEndian, styleStarts); err != nil {
+	styleStarts, err := readUint32s(sr, sp.Header.StyleCount)
+	if err != nil {
 		return nil, err
 	}
 
-	sp.Strings = make([]string, sp.Header.StringCount)
-	for i, start := range stringStarts {
-		var str string
-		var err error
-		if _, err := sr.Seek(int64(sp.Header.StringStart+start), io.SeekStart); err != nil {
-			return nil, err
+	isUTF8 := (sp.Header.Flags & UTF8Flag) != 0
+	if lazy {
+		if sp.Header.StringCount > 0 && sp.Header.StringStart >= sp.Header.Header.Size {
+			return nil, fmt.Errorf("androidbinary: invalid string start: %d", sp.Header.StringStart)
 		}
-		if (sp.Header.Flags & UTF8Flag) == 0 {
-			str, err = readUTF16(sr)
-		} else {
-			str, err = readUTF8(sr)
+		sp.lazy = &lazyStrings{
+			sr:      io.NewSectionReader(sr, int64(sp.Header.StringStart), int64(sp.Header.Header.Size)-int64(sp.Header.StringStart)),
+			starts:  stringStarts,
+			utf8:    isUTF8,
+			strings: make([]string, len(stringStarts)),
+			decoded: make([]bool, len(stringStarts)),
+		}
+	} else {
+		sp.Strings = make([]string, sp.Header.StringCount)
+		for i, start := range stringStarts {
+			str, err := readString(sr, int64(sp.Header.StringStart+start), isUTF8)
+			if err != nil {
+				return nil, err
+			}
+			sp.Strings[i] = str
 		}
+	}
+
+	if sp.Header.StyleCount > 0 && sp.Header.StylesStart < sp.Header.Header.Size {
+		sp.styleStarts = styleStarts
+		styleSize := int64(sp.Header.Header.Size - sp.Header.StylesStart)
+		sp.styleData, err = readBytes(io.NewSectionReader(sr, int64(sp.Header.StylesStart), styleSize), styleSize)
 		if err != nil {
 			return nil, err
 		}
-		sp.Strings[i] = str
 	}
 
 	sp.Styles = make([]ResStringPoolSpan, sp.Header.StyleCount)
@@ -175,6 +660,17 @@ func readStringPool(sr *io.SectionReader) (*ResStringPool, error) {
 	return sp, nil
 }
 
+// readString reads the string at offset in sr.
+func readString(sr *io.SectionReader, offset int64, isUTF8 bool) (string, error) {
+	if _, err := sr.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	if isUTF8 {
+		return readUTF8(sr)
+	}
+	return readUTF16(sr)
+}
+
 func readUTF16(sr *io.SectionReader) (string, error) {
 	// read length of string
 	size, err := readUTF16length(sr)
@@ -183,10 +679,14 @@ func readUTF16(sr *io.SectionReader) (string, error) {
 	}
 
 	// read string value
-	buf := make([]uint16, size)
-	if err := binary.Read(sr, binary.LittleEndian, buf); err != nil {
+	data, err := readBytes(sr, 2*int64(size))
+	if err != nil {
 		return "", err
 	}
+	buf := make([]uint16, size)
+	for i := range buf {
+		buf[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
 	return string(utf16.Decode(buf)), nil
 }
 
@@ -220,13 +720,46 @@ func readUTF8(sr *io.SectionReader) (string, error) {
 		return "", err
 	}
 
-	buf := make([]uint8, size)
-	if err := binary.Read(sr, binary.LittleEndian, buf); err != nil {
+	buf, err := readBytes(sr, int64(size))
+	if err != nil {
 		return "", err
 	}
+	if !utf8.Valid(buf) {
+		return decodeCESU8(buf), nil
+	}
 	return string(buf), nil
 }
 
+// decodeCESU8 decodes the UTF-8 string whose characters out of the BMP are encoded as surrogate pairs,
+// each half in three bytes, as some tools encode the strings in UTF-8 string pools.
+// The other invalid bytes are decoded as U+FFFD.
+func decodeCESU8(b []byte) string {
+	var buf strings.Builder
+	for len(b) > 0 {
+		if high, ok := decodeSurrogateHalf(b); ok && len(b) >= 6 {
+			if low, ok := decodeSurrogateHalf(b[3:]); ok {
+				if r := utf16.DecodeRune(high, low); r != utf8.RuneError {
+					buf.WriteRune(r)
+					b = b[6:]
+					continue
+				}
+			}
+		}
+		r, size := utf8.DecodeRune(b)
+		buf.WriteRune(r)
+		b = b[size:]
+	}
+	return buf.String()
+}
+
+// decodeSurrogateHalf decodes a surrogate encoded in three bytes, 0xED 0xA0-0xBF 0x80-0xBF.
+func decodeSurrogateHalf(b []byte) (rune, bool) {
+	if len(b) < 3 || b[0] != 0xED || b[1]&0xE0 != 0xA0 || b[2]&0xC0 != 0x80 {
+		return 0, false
+	}
+	return 0xD000 | rune(b[1]&0x3F)<<6 | rune(b[2]&0x3F), true
+}
+
 func readUTF8length(sr *io.SectionReader) (int, error) {
 	var size int
 	var first, second uint8
@@ -244,6 +777,33 @@ func readUTF8length(sr *io.SectionReader) (int, error) {
 	return size, nil
 }
 
+// readBytes reads n bytes from r.
+// Unlike io.ReadFull with a buffer of n bytes, the buffer grows as the data is read,
+// so broken sizes in the file don't cause huge allocations.
+func readBytes(r io.Reader, n int64) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readUint32s reads n uint32 values in little endian from r.
+func readUint32s(r io.Reader, n uint32) ([]uint32, error) {
+	data, err := readBytes(r, 4*int64(n))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]uint32, n)
+	for i := range ret {
+		ret[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return ret, nil
+}
+
 func newZeroFilledReader(r io.Reader, actual int64, expected int64) (io.Reader, error) {
 	if actual >= expected {
 		// no need to fill
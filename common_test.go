@@ -2,8 +2,14 @@ package androidbinary
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
 	"io"
+	"math"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -63,6 +69,115 @@ func TestReadStringPool(t *testing.T) {
 	}
 }
 
+func TestReadLazyStringPool(t *testing.T) {
+	for _, tt := range readStringPoolTests {
+		buf := bytes.NewReader(tt.input)
+		sr := io.NewSectionReader(buf, 0, int64(len(tt.input)))
+		actual, err := readLazyStringPool(sr)
+		if err != nil {
+			t.Fatalf("got %v want no error", err)
+		}
+		if actual.Strings != nil {
+			t.Errorf("got %v want nil", actual.Strings)
+		}
+		for i, want := range tt.strings {
+			ref := ResStringPoolRef(i)
+			if !actual.HasString(ref) {
+				t.Errorf("%d: want HasString", i)
+				continue
+			}
+			if got := actual.GetString(ref); got != want {
+				t.Errorf("%d: got %q want %q", i, got, want)
+			}
+		}
+		if actual.HasString(ResStringPoolRef(len(tt.strings))) {
+			t.Errorf("%d: want no string", len(tt.strings))
+		}
+		if !reflect.DeepEqual(actual.Styles, tt.styles) {
+			t.Errorf("got %v want %v", actual.Styles, tt.styles)
+		}
+	}
+}
+
+func TestReadStringPoolUnsupportedEncoding(t *testing.T) {
+	pool := []uint8{
+		0x01, 0x00, // Type = RES_STRING_POOL_TYPE
+		0x1C, 0x00, // HeaderSize = 28 bytes
+		0x20, 0x00, 0x00, 0x00, // Size = 32
+		0x01, 0x00, 0x00, 0x00, // StringCount = 1
+		0x00, 0x00, 0x00, 0x00, // StyleScount = 0
+		0x01, 0x02, 0x00, 0x00, // Flags = SORTED_FLAG | 0x200
+		0x20, 0x00, 0x00, 0x00, // StringStart = 32
+		0x00, 0x00, 0x00, 0x00, // StylesStart = 0
+		0x00, 0x00, 0x00, 0x00,
+	}
+	for _, read := range []func(*io.SectionReader) (*ResStringPool, error){readStringPool, readLazyStringPool} {
+		sr := io.NewSectionReader(bytes.NewReader(pool), 0, int64(len(pool)))
+		_, err := read(sr)
+		var e *UnsupportedEncodingError
+		if !errors.As(err, &e) {
+			t.Fatalf("got %v want UnsupportedEncodingError", err)
+		}
+		if e.Flags != SortedFlag|0x200 {
+			t.Errorf("got 0x%08X want 0x00000201", uint32(e.Flags))
+		}
+		var ref *InvalidReferenceError
+		if errors.As(err, &ref) {
+			t.Error("want not InvalidReferenceError")
+		}
+	}
+}
+
+func TestStringPoolIndexOf(t *testing.T) {
+	strs := []string{"", "a", "app_name", "b", "colorAccent", "\u00e9", "\uff01", "\U0001F600", "\U0001F601"}
+	missing := []string{"A", "aa", "z", "\uffff", "\U0001F602"}
+	for _, flags := range []Flags{0, UTF8Flag} {
+		// both encodings are sorted by UTF-16 units.
+		sorted := append([]string(nil), strs...)
+		sort.Slice(sorted, func(i, j int) bool { return compareUTF16(sorted[i], sorted[j]) < 0 })
+		sortedPool := &ResStringPool{Header: ResStringPoolHeader{Flags: flags | SortedFlag}, Strings: sorted}
+		unsortedPool := &ResStringPool{Header: ResStringPoolHeader{Flags: flags}, Strings: sorted}
+
+		for _, s := range append(append([]string(nil), strs...), missing...) {
+			want, wantOK := unsortedPool.indexOf(s)
+			got, gotOK := sortedPool.indexOf(s)
+			if got != want || gotOK != wantOK {
+				t.Errorf("flags 0x%X, %q: got %d, %v want %d, %v", flags, s, got, gotOK, want, wantOK)
+			}
+			if gotOK && sorted[got] != s {
+				t.Errorf("flags 0x%X, %q: got %q", flags, s, sorted[got])
+			}
+		}
+	}
+
+	// U+FF01 is after the surrogate pairs in UTF-16.
+	if compareUTF16("\uff01", "\U0001F600") <= 0 {
+		t.Error("want U+FF01 after U+1F600")
+	}
+}
+
+func benchmarkStringPoolIndexOf(b *testing.B, flags Flags) {
+	strs := make([]string, 10000)
+	for i := range strs {
+		strs[i] = fmt.Sprintf("string_%05d", i)
+	}
+	pool := &ResStringPool{Header: ResStringPoolHeader{Flags: flags}, Strings: strs}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := pool.indexOf(strs[i%len(strs)]); !ok {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func BenchmarkStringPoolIndexOf(b *testing.B) {
+	benchmarkStringPoolIndexOf(b, UTF8Flag)
+}
+
+func BenchmarkStringPoolIndexOfSorted(b *testing.B) {
+	benchmarkStringPoolIndexOf(b, UTF8Flag|SortedFlag)
+}
+
 var readUTF16Tests = []struct {
 	input  []uint8
 	output string
@@ -83,6 +198,16 @@ var readUTF16Tests = []struct {
 		[]uint8{0x00, 0x80, 0x01, 0x00, 0x61, 0x00},
 		"a",
 	},
+	{
+		// a surrogate pair
+		[]uint8{0x02, 0x00, 0x3D, 0xD8, 0x00, 0xDE},
+		"\U0001F600",
+	},
+	{
+		// an unpaired surrogate
+		[]uint8{0x02, 0x00, 0x3D, 0xD8, 0x61, 0x00},
+		"\uFFFDa",
+	},
 }
 
 func TestReadUTF16(t *testing.T) {
@@ -99,6 +224,40 @@ func TestReadUTF16(t *testing.T) {
 	}
 }
 
+func TestReadStringPoolLongStrings(t *testing.T) {
+	cases := []struct {
+		flags Flags
+		str   string
+	}{
+		// UTF-16 lengths over 0x7FFF are in two units.
+		{0, strings.Repeat("a", 0x8000)},
+		{0, strings.Repeat("\u3042", 40000)},
+		// UTF-8 lengths over 0x7F are in two bytes, up to 0x7FFF.
+		{UTF8Flag, strings.Repeat("a", 0x80)},
+		{UTF8Flag, strings.Repeat("a", 0x7FFF)},
+	}
+	for _, c := range cases {
+		pool := &ResStringPool{
+			Header:  ResStringPoolHeader{Flags: c.flags},
+			Strings: []string{c.str, "next"},
+		}
+		data, err := pool.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := readStringPool(io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GetString(0) != c.str {
+			t.Errorf("flags 0x%X, length %d: got length %d", c.flags, len(c.str), len(got.GetString(0)))
+		}
+		if got.GetString(1) != "next" {
+			t.Errorf("flags 0x%X, length %d: got %q want next", c.flags, len(c.str), got.GetString(1))
+		}
+	}
+}
+
 var readUTF8Tests = []struct {
 	input  []uint8
 	output string
@@ -119,6 +278,15 @@ var readUTF8Tests = []struct {
 		[]uint8{0x80, 0x01, 0x80, 0x01, 0x61},
 		"a",
 	},
+	{
+		[]uint8{0x02, 0x04, 0xF0, 0x9F, 0x98, 0x80},
+		"\U0001F600",
+	},
+	{
+		// the surrogate pair encoded in CESU-8
+		[]uint8{0x02, 0x06, 0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80},
+		"\U0001F600",
+	},
 }
 
 func TestReadUTF8(t *testing.T) {
@@ -171,3 +339,199 @@ func TestNewZeroFilledReader(t *testing.T) {
 		}
 	}
 }
+
+func TestReadBrokenLength(t *testing.T) {
+	inputs := [][]uint8{
+		// UTF-16 string of 0x7FFFFFFF characters
+		{0xFF, 0xFF, 0xFF, 0xFF, 0x61, 0x00},
+	}
+	for _, input := range inputs {
+		sr := io.NewSectionReader(bytes.NewReader(input), 0, 1<<63-1)
+		if _, err := readUTF16(sr); err == nil {
+			t.Errorf("%v: got no error want an error", input)
+		}
+	}
+
+	pool := []uint8{
+		0x01, 0x00, // Type = RES_STRING_POOL_TYPE
+		0x1C, 0x00, // HeaderSize = 28 bytes
+		0x20, 0x00, 0x00, 0x00, // Size = 32
+		0xFF, 0xFF, 0xFF, 0xFF, // StringCount = 0xFFFFFFFF
+		0x00, 0x00, 0x00, 0x00, // StyleScount = 0
+		0x00, 0x00, 0x00, 0x00, // Flags = 0x00
+		0x20, 0x00, 0x00, 0x00, // StringStart = 32
+		0x00, 0x00, 0x00, 0x00, // StylesStart = 0
+		0x00, 0x00, 0x00, 0x00,
+	}
+	sr := io.NewSectionReader(bytes.NewReader(pool), 0, 1<<63-1)
+	if _, err := readStringPool(sr); err == nil {
+		t.Error("got no error want an error")
+	}
+}
+
+func TestComplexToFloat(t *testing.T) {
+	cases := []struct {
+		complex uint32
+		value   float32
+		unit    int
+	}{
+		// radix 23p0
+		{0x00001001, 16, ComplexUnitDip},  // 16dp
+		{0x00004001, 64, ComplexUnitDip},  // 64dp, in testdata/MyApplication/resources.arsc
+		{0xfffff001, -16, ComplexUnitDip}, // -16dp
+		{0x00000e02, 14, ComplexUnitSp},   // 14sp
+		{0x00000100, 1, ComplexUnitPx},    // 1px
+		{0x00006400, 100, ComplexUnitPx},  // 100px
+		{0x7fffff00, 8388607, ComplexUnitPx},
+
+		// radix 16p7
+		{0x0000c011, 1.5, ComplexUnitDip},  // 1.5dp
+		{0xffff4011, -1.5, ComplexUnitDip}, // -1.5dp
+
+		// radix 8p15
+		{0x00400025, 0.5, ComplexUnitMm}, // 0.5mm
+
+		// radix 0p23
+		{0x20000030, 0.25, ComplexUnitPx},
+		{0x40000030, 0.5, ComplexUnitFraction},       // 50%
+		{0x40000031, 0.5, ComplexUnitFractionParent}, // 50%p
+	}
+	for _, c := range cases {
+		if got := ComplexToFloat(c.complex); got != c.value {
+			t.Errorf("%#08x: got %v want %v", c.complex, got, c.value)
+		}
+		if got := ComplexUnit(c.complex); got != c.unit {
+			t.Errorf("%#08x: got unit %d want %d", c.complex, got, c.unit)
+		}
+	}
+}
+
+func TestResValueInterface(t *testing.T) {
+	table := loadMyApplicationTestData(t)
+	config := &ResTableConfig{}
+	cases := []struct {
+		value ResValue
+		want  interface{}
+	}{
+		{ResValue{DataType: TypeNull}, nil},
+		{ResValue{DataType: TypeFloat, Data: math.Float32bits(1.5)}, float32(1.5)},
+		{ResValue{DataType: TypeDemention, Data: 0x00001001}, float32(16)},
+		{ResValue{DataType: TypeFraction, Data: 0x40000030}, float32(0.5)},
+		{ResValue{DataType: TypeIntDec, Data: 0xFFFFFFD6}, -42},
+		{ResValue{DataType: TypeIntHex, Data: 0x30}, uint32(0x30)},
+		{ResValue{DataType: TypeIntBoolean, Data: 0xFFFFFFFF}, true},
+		{ResValue{DataType: TypeIntBoolean, Data: 0}, false},
+		{ResValue{DataType: TypeIntColorARGB8, Data: 0x80ff0000}, color.NRGBA{R: 0xff, A: 0x80}},
+		{ResValue{DataType: TypeIntColorRGB8, Data: 0x008577}, color.NRGBA{G: 0x85, B: 0x77, A: 0xff}},
+		{ResValue{DataType: TypeIntColorARGB4, Data: 0x8f00}, color.NRGBA{R: 0xff, A: 0x88}},
+		{ResValue{DataType: TypeIntColorRGB4, Data: 0x0f0}, color.NRGBA{G: 0xff, A: 0xff}},
+		{ResValue{DataType: TypeAttribute, Data: 0x7F020052}, ResID(0x7F020052)},
+		{ResValue{DataType: TypeReference, Data: 0x7F0B0027}, "My Application"},
+		{ResValue{DataType: TypeReference, Data: 0x7F040026}, color.NRGBA{R: 0xd8, G: 0x1b, B: 0x60, A: 0xff}},
+		{ResValue{DataType: TypeReference, Data: 0x7F0C0005}, ResID(0x7F0C0005)}, // a style
+	}
+	for _, c := range cases {
+		got, err := c.value.Interface(table, config)
+		if err != nil {
+			t.Errorf("%#v: got %v want no error", c.value, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%#v: got %#v want %#v", c.value, got, c.want)
+		}
+	}
+
+	// the references are kept without the table.
+	got, err := ResValue{DataType: TypeReference, Data: 0x7F0B0027}.Interface(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ResID(0x7F0B0027) {
+		t.Errorf("got %#v want ResID(0x7F0B0027)", got)
+	}
+	if _, err := (ResValue{DataType: TypeString}).Interface(nil, nil); err == nil {
+		t.Error("want error for the string without the table")
+	}
+	if _, err := (ResValue{DataType: TypeReference, Data: 0x7F0BFFFF}).Interface(table, config); err == nil {
+		t.Error("want error for the missing resource")
+	}
+}
+
+func TestResValueString(t *testing.T) {
+	cases := []struct {
+		value ResValue
+		want  string
+	}{
+		{ResValue{DataType: TypeNull}, ""},
+		{ResValue{DataType: TypeReference, Data: 0x7F0B0027}, "@0x7F0B0027"},
+		{ResValue{DataType: TypeDynamicReference, Data: 0x7F0B0027}, "@0x7F0B0027"},
+		{ResValue{DataType: TypeAttribute, Data: 0x01010036}, "?0x01010036"},
+		{ResValue{DataType: TypeDynamicAttribute, Data: 0x7F010001}, "?0x7F010001"},
+		{ResValue{DataType: TypeString, Data: 3}, ""},
+		{ResValue{DataType: TypeFloat, Data: math.Float32bits(1.5)}, "1.5"},
+		{ResValue{DataType: TypeFloat, Data: math.Float32bits(-0.25)}, "-0.25"},
+		{ResValue{DataType: TypeDemention, Data: 0x00000E02}, "14sp"},
+		{ResValue{DataType: TypeDemention, Data: 0x0000C011}, "1.5dip"},
+		{ResValue{DataType: TypeDemention, Data: 0xFFFFF800}, "-8px"},
+		{ResValue{DataType: TypeDemention, Data: 0x00001005}, "16mm"},
+		{ResValue{DataType: TypeFraction, Data: 0x40000030}, "50%"},
+		{ResValue{DataType: TypeFraction, Data: 0x40000031}, "50%p"},
+		{ResValue{DataType: TypeFraction, Data: 0x40000032}, "50"}, // unknown unit
+		{ResValue{DataType: TypeIntDec, Data: 42}, "42"},
+		{ResValue{DataType: TypeIntDec, Data: 0xFFFFFFFF}, "-1"},
+		{ResValue{DataType: TypeIntDec, Data: 0x80000000}, "-2147483648"},
+		{ResValue{DataType: TypeIntHex, Data: 0x10}, "0x00000010"},
+		{ResValue{DataType: TypeIntBoolean, Data: 0xFFFFFFFF}, "true"},
+		{ResValue{DataType: TypeIntBoolean, Data: 0}, "false"},
+		{ResValue{DataType: TypeIntColorARGB8, Data: 0x80ff0000}, "#80ff0000"},
+		{ResValue{DataType: TypeIntColorRGB8, Data: 0x00ff00}, "#ff00ff00"},
+		{ResValue{DataType: TypeIntColorARGB4, Data: 0xf00f}, "#ff0000ff"},
+		{ResValue{DataType: TypeIntColorRGB4, Data: 0x0f0}, "#ff00ff00"},
+	}
+	for _, c := range cases {
+		if got := c.value.String(); got != c.want {
+			t.Errorf("%#v: got %q want %q", c.value, got, c.want)
+		}
+	}
+}
+
+func TestReadResValue(t *testing.T) {
+	values := []ResValue{
+		{Size: 8, DataType: TypeNull},
+		{Size: 8, DataType: TypeReference, Data: 0x7F0B0027},
+		{Size: 8, DataType: TypeString, Data: 3},
+		{Size: 8, DataType: TypeFloat, Data: math.Float32bits(1.5)},
+		{Size: 8, DataType: TypeDemention, Data: 0x00001001},
+		{Size: 8, DataType: TypeIntDec, Data: 0xFFFFFFD6},
+		{Size: 8, DataType: TypeIntBoolean, Data: 0xFFFFFFFF},
+		{Size: 8, DataType: TypeIntColorARGB8, Data: 0x80ff0000},
+	}
+	for _, v := range values {
+		data := v.Marshal()
+		if len(data) != 8 {
+			t.Errorf("%#v: got %d bytes want 8", v, len(data))
+			continue
+		}
+		got, err := ReadResValue(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%#v: got %v want no error", v, err)
+			continue
+		}
+		if got != v {
+			t.Errorf("got %#v want %#v", got, v)
+		}
+	}
+
+	// the layout is the same as the binary files.
+	want := []byte{0x08, 0x00, 0x00, 0x01, 0x27, 0x00, 0x0B, 0x7F}
+	if got := (ResValue{Size: 8, DataType: TypeReference, Data: 0x7F0B0027}).Marshal(); !bytes.Equal(got, want) {
+		t.Errorf("got % x want % x", got, want)
+	}
+
+	if _, err := ReadResValue(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("got %v want io.EOF", err)
+	}
+	if _, err := ReadResValue(bytes.NewReader(want[:5])); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,581 @@
+package androidbinary
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XMLElement is an element of the XML tree decoded from the binary XML file.
+type XMLElement struct {
+	// Name is the name of the element, including its namespace prefix if any.
+	Name string
+
+	// Namespace is the namespace URI of the element.
+	Namespace string
+
+	// Attrs are the attributes of the element in document order.
+	Attrs []XMLAttr
+
+	// Children are the child elements in document order.
+	Children []*XMLElement
+
+	// Parent is the parent element. It is nil for the root element.
+	Parent *XMLElement
+
+	// Namespaces are the namespaces declared on the element, sorted by the prefix.
+	// See NamespaceScope for the namespaces declared on the ancestors.
+	Namespaces []XMLNamespace
+
+	// the attributes as they are in the binary XML file, in the same order as Attrs.
+	rawAttrs []ResXMLTreeAttribute
+
+	// the 1-based indexes in Attrs of the id, class and style attributes. 0 means none.
+	idIndex, classIndex, styleIndex int
+
+	// the text in the element, concatenated.
+	charData string
+}
+
+// XMLAttr is an attribute of XMLElement.
+type XMLAttr struct {
+	// Name is the name of the attribute, including its namespace prefix if any.
+	// e.g. "android:name"
+	Name string
+
+	// Namespace is the namespace URI of the attribute.
+	Namespace string
+
+	// Value is the attribute value in text format.
+	Value string
+}
+
+// XMLNamespace is a namespace declaration, e.g. xmlns:android="http://schemas.android.com/apk/res/android".
+type XMLNamespace struct {
+	// Prefix is the prefix of the namespace. It is empty for the default namespace.
+	Prefix string
+
+	// URI is the namespace URI.
+	URI string
+}
+
+// Root returns the root element of the XML tree.
+// It returns nil if the file has no elements.
+func (f *XMLFile) Root() *XMLElement {
+	return f.root
+}
+
+// Attr returns the value of the attribute named name.
+// name includes the namespace prefix, e.g. "android:name".
+// The attributes are matched by the namespace URI that the prefix refers to at e and the local name,
+// so "android:name" matches a:name if the document declares xmlns:a="http://schemas.android.com/apk/res/android".
+// The prefix "android" refers to that namespace unless the document declares it.
+func (e *XMLElement) Attr(name string) (string, bool) {
+	if i := e.attrIndex(name); i >= 0 {
+		return e.Attrs[i].Value, true
+	}
+	return "", false
+}
+
+// androidNamespace is the namespace URI of the attributes that the Android framework defines.
+const androidNamespace = "http://schemas.android.com/apk/res/android"
+
+// attrIndex returns the index in e.Attrs of the attribute named name, or -1 if e doesn't have it.
+// See Attr for how the attributes are matched.
+func (e *XMLElement) attrIndex(name string) int {
+	prefix, local := "", name
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		prefix, local = name[:i], name[i+1:]
+	}
+	uri := ""
+	if prefix != "" {
+		var ok bool
+		uri, ok = e.LookupNamespace(prefix)
+		if !ok && prefix == "android" {
+			uri, ok = androidNamespace, true
+		}
+		if !ok {
+			// the prefix is not declared. compare the names as they are rendered.
+			for i, attr := range e.Attrs {
+				if attr.Name == name {
+					return i
+				}
+			}
+			return -1
+		}
+	}
+	for i, attr := range e.Attrs {
+		if attr.Namespace == uri && localName(attr.Name) == local {
+			return i
+		}
+	}
+	return -1
+}
+
+// ElementAttributes returns the names of the attributes of the index-th start element
+// in document order, as they are read, including the namespace prefixes, e.g. "android:exported".
+// The elements are indexed while parsing, so it doesn't walk the tree.
+// It returns nil if the document has no such element.
+func (f *XMLFile) ElementAttributes(index int) []string {
+	if index < 0 || index >= len(f.elements) {
+		return nil
+	}
+	elem := f.elements[index]
+	names := make([]string, len(elem.Attrs))
+	for i, attr := range elem.Attrs {
+		names[i] = attr.Name
+	}
+	return names
+}
+
+// RawAttributes returns the attributes of elem exactly as they are stored in the binary XML file,
+// in the same order as elem.Attrs.
+// It is an escape hatch for the typed values that XMLAttr.Value doesn't render faithfully,
+// e.g. the data types that this package doesn't know.
+func (f *XMLFile) RawAttributes(elem *XMLElement) []ResXMLTreeAttribute {
+	if elem == nil || len(elem.rawAttrs) == 0 {
+		return nil
+	}
+	return append([]ResXMLTreeAttribute(nil), elem.rawAttrs...)
+}
+
+// ElementID returns the value of the id attribute of elem, e.g. "@0x7F080001" for android:id="@+id/view".
+// It uses the index of the id attribute that aapt stores in the start element,
+// as Android's LayoutInflater does, instead of scanning the attributes.
+// It returns false if the element has no such index.
+func (f *XMLFile) ElementID(elem *XMLElement) (string, bool) {
+	return elem.indexedAttr(elem.idIndex)
+}
+
+// ElementClass returns the value of the class attribute of elem, e.g. "com.example.CustomView" for <view class="...">.
+// It uses the index of the class attribute that aapt stores in the start element.
+// It returns false if the element has no such index.
+func (f *XMLFile) ElementClass(elem *XMLElement) (string, bool) {
+	return elem.indexedAttr(elem.classIndex)
+}
+
+// ElementStyle returns the value of the style attribute of elem, e.g. "@0x7F0C0005" for style="@style/Text".
+// It uses the index of the style attribute that aapt stores in the start element.
+// It returns false if the element has no such index.
+func (f *XMLFile) ElementStyle(elem *XMLElement) (string, bool) {
+	return elem.indexedAttr(elem.styleIndex)
+}
+
+// LookupNamespace returns the URI of the namespace that prefix refers to at e,
+// i.e. the one declared on e or its nearest ancestor.
+// The empty prefix looks up the default namespace.
+func (e *XMLElement) LookupNamespace(prefix string) (string, bool) {
+	for ; e != nil; e = e.Parent {
+		for _, ns := range e.Namespaces {
+			if ns.Prefix == prefix {
+				return ns.URI, true
+			}
+		}
+	}
+	return "", false
+}
+
+// DefaultNamespace returns the URI of the default namespace at e.
+// It is empty if no default namespace is declared on e and its ancestors.
+func (e *XMLElement) DefaultNamespace() string {
+	uri, _ := e.LookupNamespace("")
+	return uri
+}
+
+// NamespaceScope returns the namespaces in scope at e as a map from the prefixes to the URIs.
+// The declarations on e and the nearer ancestors shadow the ones with the same prefix on the farther ancestors.
+func (e *XMLElement) NamespaceScope() map[string]string {
+	scope := make(map[string]string)
+	for ; e != nil; e = e.Parent {
+		for _, ns := range e.Namespaces {
+			if _, ok := scope[ns.Prefix]; !ok {
+				scope[ns.Prefix] = ns.URI
+			}
+		}
+	}
+	return scope
+}
+
+// attrValue returns the value of the attribute named name, resolved with table and config as ResValue.Interface does.
+// The strings in the string pool of the XML file are returned as they are rendered.
+func (e *XMLElement) attrValue(name string, table *TableFile, config *ResTableConfig) (interface{}, bool, error) {
+	i := e.attrIndex(name)
+	if i < 0 {
+		return nil, false, nil
+	}
+	attr := e.Attrs[i]
+	if i >= len(e.rawAttrs) {
+		return attr.Value, true, nil
+	}
+	raw := e.rawAttrs[i]
+	if raw.RawValue != NilResStringPoolRef || raw.TypedValue.DataType == TypeString {
+		return attr.Value, true, nil
+	}
+	v, err := raw.TypedValue.Interface(table, config)
+	if err != nil {
+		return nil, true, fmt.Errorf("androidbinary: %s of %s: %w", name, e.Name, err)
+	}
+	return v, true, nil
+}
+
+// stringAttr returns the value of the attribute named name as a string.
+// It returns an empty string if the element doesn't have the attribute.
+// The references that can't be resolved without table are kept in the text format, e.g. "@0x7F0B0027".
+func (e *XMLElement) stringAttr(name string, table *TableFile, config *ResTableConfig) (string, error) {
+	v, ok, err := e.attrValue(name, table, config)
+	if !ok || err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case ResID:
+		value, _ := e.Attr(name)
+		return value, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// intAttr returns the value of the attribute named name as an int.
+// It returns 0 if the element doesn't have the attribute.
+func (e *XMLElement) intAttr(name string, table *TableFile, config *ResTableConfig) (int, error) {
+	v, ok, err := e.attrValue(name, table, config)
+	if !ok || err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case uint32:
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("androidbinary: %s of %s is not an integer: %q", name, e.Name, v)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("androidbinary: %s of %s is not an integer: %v", name, e.Name, v)
+}
+
+// boolAttr returns the value of the attribute named name as a bool.
+// It returns def if the element doesn't have the attribute or the value isn't a boolean,
+// e.g. a reference that can't be resolved without table.
+func (e *XMLElement) boolAttr(name string, table *TableFile, config *ResTableConfig, def bool) bool {
+	v, ok, err := e.attrValue(name, table, config)
+	if !ok || err != nil {
+		return def
+	}
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+func (e *XMLElement) indexedAttr(index int) (string, bool) {
+	if e == nil || index <= 0 || index > len(e.Attrs) {
+		return "", false
+	}
+	return e.Attrs[index-1].Value, true
+}
+
+func (f *XMLFile) namespaceURI(ns ResStringPoolRef) string {
+	if ns == NilResStringPoolRef || !f.HasString(ns) {
+		return ""
+	}
+	return f.GetString(ns)
+}
+
+func (f *XMLFile) pushElement(elem *XMLElement) {
+	if f.noTree {
+		return
+	}
+	if f.current != nil {
+		elem.Parent = f.current
+		f.current.Children = append(f.current.Children, elem)
+	} else if f.root == nil {
+		f.root = elem
+	} else {
+		// ignore elements after the root element.
+		return
+	}
+	f.elements = append(f.elements, elem)
+	f.current = elem
+}
+
+func (f *XMLFile) popElement() {
+	if f.current != nil {
+		f.current = f.current.Parent
+	}
+}
+
+// Find returns the elements that match path, in document order.
+// It returns nil if no element matches or path is malformed.
+//
+// The path is a small subset of XPath:
+//
+//	path      = separator step { separator step }
+//	separator = "/" | "//"
+//	step      = nametest { predicate }
+//	nametest  = "*" | qname
+//	predicate = "[" "@" ( "*" | qname ) [ "=" literal ] "]"
+//	literal   = "'" { char } "'" | '"' { char } '"'
+//
+// "/" selects the children of the current elements and "//" selects all their descendants.
+// The path is always evaluated from the document, so "/manifest" selects the root element
+// and "//activity" selects every activity element.
+// qname is compared with the names including the namespace prefix, e.g. "android:name";
+// the names of the attributes are matched as Attr does.
+// "[@name]" tests that the attribute exists, and "[@name='value']" tests its value.
+//
+// For example:
+//
+//	/manifest/application/activity[@android:name='.Main']
+//	//uses-permission[@android:name='android.permission.INTERNET']
+//	/manifest/*/activity
+func (f *XMLFile) Find(path string) []*XMLElement {
+	steps, ok := parseXMLPath(path)
+	if !ok || f.root == nil {
+		return nil
+	}
+
+	// nil is the key for the document node.
+	context := map[*XMLElement]bool{nil: true}
+	var result []*XMLElement
+	for _, step := range steps {
+		result = nil
+		walkXMLElement(f.root, func(elem *XMLElement) {
+			if step.match(elem, context) {
+				result = append(result, elem)
+			}
+		})
+		if len(result) == 0 {
+			return nil
+		}
+		context = make(map[*XMLElement]bool, len(result))
+		for _, elem := range result {
+			context[elem] = true
+		}
+	}
+	return result
+}
+
+// cloneXMLElement returns a deep copy of elem whose parent is parent.
+func cloneXMLElement(elem *XMLElement, parent *XMLElement) *XMLElement {
+	c := *elem
+	c.Parent = parent
+	c.Attrs = append([]XMLAttr(nil), elem.Attrs...)
+	c.rawAttrs = append([]ResXMLTreeAttribute(nil), elem.rawAttrs...)
+	c.Namespaces = append([]XMLNamespace(nil), elem.Namespaces...)
+	c.Children = nil
+	for _, child := range elem.Children {
+		c.Children = append(c.Children, cloneXMLElement(child, &c))
+	}
+	return &c
+}
+
+// xmlTreeTokenReader is an xml.TokenReader of the element tree.
+// It returns the same tokens as xml.Decoder does for the text format,
+// except that the text of an element precedes its children and the comments are omitted.
+type xmlTreeTokenReader struct {
+	tokens []xml.Token
+}
+
+func newXMLTreeTokenReader(root *XMLElement) *xmlTreeTokenReader {
+	r := new(xmlTreeTokenReader)
+	r.appendElement(root)
+	return r
+}
+
+func (r *xmlTreeTokenReader) appendElement(elem *XMLElement) {
+	start := xml.StartElement{
+		Name: xmlName(elem.Name, elem.Namespace),
+		Attr: make([]xml.Attr, 0, len(elem.Namespaces)+len(elem.Attrs)),
+	}
+	for _, ns := range elem.Namespaces {
+		name := xml.Name{Space: "xmlns", Local: ns.Prefix}
+		if ns.Prefix == "" {
+			// the default namespace, xmlns="...".
+			name = xml.Name{Local: "xmlns"}
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: name, Value: ns.URI})
+	}
+	for _, attr := range elem.Attrs {
+		start.Attr = append(start.Attr, xml.Attr{Name: xmlName(attr.Name, attr.Namespace), Value: attr.Value})
+	}
+	r.tokens = append(r.tokens, start)
+	if elem.charData != "" {
+		r.tokens = append(r.tokens, xml.CharData(elem.charData))
+	}
+	for _, child := range elem.Children {
+		r.appendElement(child)
+	}
+	r.tokens = append(r.tokens, start.End())
+}
+
+// Token implements xml.TokenReader.
+func (r *xmlTreeTokenReader) Token() (xml.Token, error) {
+	if len(r.tokens) == 0 {
+		return nil, io.EOF
+	}
+	t := r.tokens[0]
+	r.tokens = r.tokens[1:]
+	return t, nil
+}
+
+// xmlName returns the name of encoding/xml for the name with the namespace prefix, e.g. "android:name".
+func xmlName(name, namespace string) xml.Name {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return xml.Name{Space: namespace, Local: name}
+}
+
+func walkXMLElement(elem *XMLElement, fn func(elem *XMLElement)) {
+	fn(elem)
+	for _, child := range elem.Children {
+		walkXMLElement(child, fn)
+	}
+}
+
+type xmlPathStep struct {
+	descendant bool
+	name       string
+	predicates []xmlPathPredicate
+}
+
+type xmlPathPredicate struct {
+	name     string
+	hasValue bool
+	value    string
+}
+
+func (s xmlPathStep) match(elem *XMLElement, context map[*XMLElement]bool) bool {
+	if s.name != "*" && s.name != elem.Name {
+		return false
+	}
+	for _, p := range s.predicates {
+		if !p.match(elem) {
+			return false
+		}
+	}
+
+	if !s.descendant {
+		return context[elem.Parent]
+	}
+	for p := elem.Parent; p != nil; p = p.Parent {
+		if context[p] {
+			return true
+		}
+	}
+	return context[nil]
+}
+
+func (p xmlPathPredicate) match(elem *XMLElement) bool {
+	if p.name != "*" {
+		v, ok := elem.Attr(p.name)
+		return ok && (!p.hasValue || p.value == v)
+	}
+	for _, attr := range elem.Attrs {
+		if !p.hasValue || p.value == attr.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func parseXMLPath(path string) ([]xmlPathStep, bool) {
+	var steps []xmlPathStep
+	for path != "" {
+		var step xmlPathStep
+		switch {
+		case strings.HasPrefix(path, "//"):
+			step.descendant = true
+			path = path[2:]
+		case strings.HasPrefix(path, "/"):
+			path = path[1:]
+		default:
+			return nil, false
+		}
+
+		// name test
+		i := strings.IndexAny(path, "/[")
+		if i < 0 {
+			i = len(path)
+		}
+		step.name = path[:i]
+		path = path[i:]
+		if !isValidXMLPathName(step.name) {
+			return nil, false
+		}
+
+		// predicates
+		for strings.HasPrefix(path, "[") {
+			var p xmlPathPredicate
+			var ok bool
+			p, path, ok = parseXMLPathPredicate(path)
+			if !ok {
+				return nil, false
+			}
+			step.predicates = append(step.predicates, p)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, false
+	}
+	return steps, true
+}
+
+func parseXMLPathPredicate(path string) (xmlPathPredicate, string, bool) {
+	var p xmlPathPredicate
+	if !strings.HasPrefix(path, "[@") {
+		return p, "", false
+	}
+	path = path[2:]
+
+	i := strings.IndexAny(path, "=]")
+	if i < 0 {
+		return p, "", false
+	}
+	p.name = path[:i]
+	if !isValidXMLPathName(p.name) {
+		return p, "", false
+	}
+	path = path[i:]
+	if path[0] == ']' {
+		return p, path[1:], true
+	}
+
+	// attribute value
+	path = path[1:]
+	if path == "" || (path[0] != '\'' && path[0] != '"') {
+		return p, "", false
+	}
+	quote := path[0]
+	end := strings.IndexByte(path[1:], quote)
+	if end < 0 {
+		return p, "", false
+	}
+	p.hasValue = true
+	p.value = path[1 : end+1]
+	path = path[end+2:]
+	if !strings.HasPrefix(path, "]") {
+		return p, "", false
+	}
+	return p, path[1:], true
+}
+
+func isValidXMLPathName(name string) bool {
+	if name == "*" {
+		return true
+	}
+	return name != "" && !strings.ContainsAny(name, "*@='\"[] ")
+}
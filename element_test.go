@@ -0,0 +1,415 @@
+package androidbinary
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadXMLTestData(t *testing.T, name string) *XMLFile {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	xmlFile, err := NewXMLFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return xmlFile
+}
+
+func TestXMLFileRoot(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	root := xmlFile.Root()
+	if root == nil {
+		t.Fatal("got nil want the root element")
+	}
+	if root.Name != "manifest" {
+		t.Errorf("got %q want manifest", root.Name)
+	}
+	if root.Parent != nil {
+		t.Errorf("got %v want nil", root.Parent)
+	}
+	if v, ok := root.Attr("package"); !ok || v != "net.sorablue.shogo.FWMeasure" {
+		t.Errorf("got %q, %v want net.sorablue.shogo.FWMeasure", v, ok)
+	}
+	if len(root.Children) != 7 {
+		t.Errorf("got %d children want 7", len(root.Children))
+	}
+	for _, attr := range root.Attrs {
+		if attr.Name == "android:versionCode" && attr.Namespace != "http://schemas.android.com/apk/res/android" {
+			t.Errorf("unexpected namespace: %q", attr.Namespace)
+		}
+	}
+}
+
+func TestXMLFileFind(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	cases := []struct {
+		path  string
+		names []string
+	}{
+		// element selectors
+		{
+			path:  "/manifest/application/activity",
+			names: []string{"FWMeasureActivity", "MapActivity", "SettingActivity", "PlaceSettingActivity"},
+		},
+		{
+			path:  "/manifest/application/uses-library",
+			names: []string{"com.google.android.maps"},
+		},
+		{
+			path:  "/application",
+			names: nil,
+		},
+
+		// attribute predicates
+		{
+			path:  "/manifest/application/activity[@android:name='MapActivity']",
+			names: []string{"MapActivity"},
+		},
+		{
+			path:  `/manifest/application/activity[@android:screenOrientation="0"]`,
+			names: []string{"FWMeasureActivity", "MapActivity"},
+		},
+		{
+			path:  "/manifest/application/activity[@android:screenOrientation][@android:name='MapActivity']",
+			names: []string{"MapActivity"},
+		},
+		{
+			path:  "/manifest/uses-permission[@android:name='android.permission.INTERNET']",
+			names: []string{"android.permission.INTERNET"},
+		},
+		{
+			path:  "/manifest/uses-permission[@android:name='android.permission.NOT_FOUND']",
+			names: nil,
+		},
+
+		// wildcard selectors
+		{
+			path:  "/manifest/*/activity",
+			names: []string{"FWMeasureActivity", "MapActivity", "SettingActivity", "PlaceSettingActivity"},
+		},
+		{
+			path:  "/manifest/application/*[@*='com.google.android.maps']",
+			names: []string{"com.google.android.maps"},
+		},
+
+		// descendant selectors
+		{
+			path:  "//activity[@android:name='FWMeasureActivity']",
+			names: []string{"FWMeasureActivity"},
+		},
+		{
+			path:  "/manifest//action",
+			names: []string{"android.intent.action.MAIN"},
+		},
+		{
+			path:  "//intent-filter/*",
+			names: []string{"android.intent.action.MAIN", "android.intent.category.LAUNCHER"},
+		},
+	}
+
+	for _, c := range cases {
+		elems := xmlFile.Find(c.path)
+		if len(elems) != len(c.names) {
+			t.Errorf("%s: got %d elements want %d", c.path, len(elems), len(c.names))
+			continue
+		}
+		for i, elem := range elems {
+			name, _ := elem.Attr("android:name")
+			if name != c.names[i] {
+				t.Errorf("%s: got %q want %q", c.path, name, c.names[i])
+			}
+		}
+	}
+}
+
+func TestXMLFileFindInvalidPath(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	paths := []string{
+		"",
+		"manifest",
+		"/",
+		"/manifest/",
+		"/manifest[android:name]",
+		"/manifest[@package='foo'",
+		"/manifest[@package=foo]",
+		"/manifest[@package='foo'x]",
+	}
+	for _, path := range paths {
+		if elems := xmlFile.Find(path); elems != nil {
+			t.Errorf("%q: got %v want nil", path, elems)
+		}
+	}
+}
+
+func TestXMLFileRawAttributes(t *testing.T) {
+	const vendorType DataType = 0x7e
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "view",
+		testStringAttr(testAndroidNS, "name", 0x01010003, "foo"),
+		testTypedAttr(testAndroidNS, "id", 0x010100d0, vendorType, 0x12345678),
+	)
+	b.EndElement("", "view")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := xmlFile.Root()
+	raw := xmlFile.RawAttributes(root)
+	if len(raw) != len(root.Attrs) {
+		t.Fatalf("got %d attributes want %d", len(raw), len(root.Attrs))
+	}
+	if got := xmlFile.GetString(raw[0].RawValue); got != "foo" {
+		t.Errorf("got %q want foo", got)
+	}
+	if raw[1].RawValue != NilResStringPoolRef {
+		t.Errorf("got %d want no raw value", raw[1].RawValue)
+	}
+	if raw[1].TypedValue.DataType != vendorType || raw[1].TypedValue.Data != 0x12345678 {
+		t.Errorf("unexpected typed value: %#v", raw[1].TypedValue)
+	}
+	if got := root.Attrs[1].Value; got != "@0x12345678" {
+		t.Errorf("got %q want @0x12345678", got)
+	}
+
+	// the returned slice is a copy.
+	raw[1].TypedValue.Data = 0
+	if xmlFile.RawAttributes(root)[1].TypedValue.Data != 0x12345678 {
+		t.Error("the attributes are modified")
+	}
+	if raw := xmlFile.RawAttributes(nil); raw != nil {
+		t.Errorf("got %v want nil", raw)
+	}
+}
+
+func TestXMLFileElementAttributes(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	cases := []struct {
+		index int
+		names []string
+	}{
+		{0, []string{"android:versionCode", "android:versionName", "package"}},
+		{1, []string{"android:name"}},
+		{-1, nil},
+		{1000, nil},
+	}
+	for _, c := range cases {
+		if got := xmlFile.ElementAttributes(c.index); !reflect.DeepEqual(got, c.names) {
+			t.Errorf("%d: got %q want %q", c.index, got, c.names)
+		}
+	}
+
+	// the indexes are in document order, and the copies have their own.
+	clone := xmlFile.Clone()
+	var i int
+	walkXMLElement(xmlFile.Root(), func(elem *XMLElement) {
+		if got := xmlFile.ElementAttributes(i); len(got) != len(elem.Attrs) {
+			t.Errorf("%d: got %d attributes want %d", i, len(got), len(elem.Attrs))
+		}
+		if got, want := clone.ElementAttributes(i), xmlFile.ElementAttributes(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+		i++
+	})
+	if got := clone.ElementAttributes(i); got != nil {
+		t.Errorf("%d: got %q want nil", i, got)
+	}
+
+	// Reset indexes the elements of the new file.
+	data, err := os.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xmlFile.Reset(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	fresh := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	for i := 0; i <= len(fresh.elements); i++ {
+		if got, want := xmlFile.ElementAttributes(i), fresh.ElementAttributes(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestXMLFileElementID(t *testing.T) {
+	// <LinearLayout xmlns:android="http://schemas.android.com/apk/res/android" style="@style/Text">
+	//     <View android:layout_width="match_parent" android:id="@+id/view" />
+	//     <view class="com.example.CustomView" />
+	// </LinearLayout>
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "LinearLayout",
+		testTypedAttr("", "style", 0, TypeReference, 0x7F0C0005),
+	)
+	b.StartElement("", "View",
+		testTypedAttr(testAndroidNS, "layout_width", 0x010100f4, TypeIntDec, 0xFFFFFFFF),
+		testTypedAttr(testAndroidNS, "id", 0x010100d0, TypeReference, 0x7F080001),
+	)
+	b.EndElement("", "View")
+	b.StartElement("", "view",
+		testStringAttr("", "class", 0, "com.example.CustomView"),
+	)
+	b.EndElement("", "view")
+	b.EndElement("", "LinearLayout")
+	b.EndNamespace("android", testAndroidNS)
+
+	for _, opts := range []Options{{}, {SpecialAttributesFirst: true}} {
+		xmlFile, err := NewXMLFileOptions(bytes.NewReader(b.Bytes()), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := xmlFile.Root()
+		view, custom := root.Children[0], root.Children[1]
+
+		if id, ok := xmlFile.ElementID(view); !ok || id != "@0x7F080001" {
+			t.Errorf("got %q, %v want @0x7F080001", id, ok)
+		}
+		if id, ok := xmlFile.ElementID(root); ok {
+			t.Errorf("got %q want no id", id)
+		}
+		if style, ok := xmlFile.ElementStyle(root); !ok || style != "@0x7F0C0005" {
+			t.Errorf("got %q, %v want @0x7F0C0005", style, ok)
+		}
+		if class, ok := xmlFile.ElementClass(custom); !ok || class != "com.example.CustomView" {
+			t.Errorf("got %q, %v want com.example.CustomView", class, ok)
+		}
+		if class, ok := xmlFile.ElementClass(view); ok {
+			t.Errorf("got %q want no class", class)
+		}
+	}
+}
+
+func TestXMLElementNamespaceScope(t *testing.T) {
+	const (
+		resAutoNS = "http://schemas.android.com/apk/res-auto"
+		customNS  = "http://schemas.android.com/apk/res/com.example.custom"
+		toolsNS   = "http://schemas.android.com/tools"
+	)
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartNamespace("app", resAutoNS)
+	b.StartElement("", "LinearLayout")
+	b.StartNamespace("app", customNS)
+	b.StartNamespace("tools", toolsNS)
+	b.StartElement("", "com.example.CustomView")
+	b.StartElement("", "TextView")
+	b.EndElement("", "TextView")
+	b.EndElement("", "com.example.CustomView")
+	b.EndNamespace("tools", toolsNS)
+	b.EndNamespace("app", customNS)
+	b.StartElement("", "Button")
+	b.EndElement("", "Button")
+	b.EndElement("", "LinearLayout")
+	b.EndNamespace("app", resAutoNS)
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := xmlFile.Root()
+	custom := root.Children[0]
+	text := custom.Children[0]
+	button := root.Children[1]
+
+	wantDecls := []XMLNamespace{{Prefix: "android", URI: testAndroidNS}, {Prefix: "app", URI: resAutoNS}}
+	if !reflect.DeepEqual(root.Namespaces, wantDecls) {
+		t.Errorf("got %v want %v", root.Namespaces, wantDecls)
+	}
+	if len(text.Namespaces) != 0 {
+		t.Errorf("got %v want no declarations", text.Namespaces)
+	}
+
+	cases := []struct {
+		elem *XMLElement
+		want map[string]string
+	}{
+		{root, map[string]string{"android": testAndroidNS, "app": resAutoNS}},
+		{custom, map[string]string{"android": testAndroidNS, "app": customNS, "tools": toolsNS}},
+		{text, map[string]string{"android": testAndroidNS, "app": customNS, "tools": toolsNS}},
+		{button, map[string]string{"android": testAndroidNS, "app": resAutoNS}},
+	}
+	for _, c := range cases {
+		if got := c.elem.NamespaceScope(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.elem.Name, got, c.want)
+		}
+	}
+
+	if uri, ok := text.LookupNamespace("app"); !ok || uri != customNS {
+		t.Errorf("got %q, %v want %q", uri, ok, customNS)
+	}
+	if uri, ok := button.LookupNamespace("tools"); ok {
+		t.Errorf("got %q want not found", uri)
+	}
+	if got := text.DefaultNamespace(); got != "" {
+		t.Errorf("got %q want no default namespace", got)
+	}
+
+	// the copy has its own declarations.
+	clone := xmlFile.Clone().Root()
+	clone.Namespaces[0].URI = "http://example.com"
+	if root.Namespaces[0].URI != testAndroidNS {
+		t.Errorf("got %q want %q", root.Namespaces[0].URI, testAndroidNS)
+	}
+}
+
+func TestXMLTreeTokenReaderDefaultNamespace(t *testing.T) {
+	const text = `<root xmlns="http://example.com/ns" xmlns:a="http://example.com/a" a:b="c"><child></child></root>`
+	xmlFile, err := NewXMLFileFromText(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tokens are the same as the ones decoded from the text.
+	var want []xml.Token
+	d := xml.NewDecoder(strings.NewReader(text))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, xml.CopyToken(tok))
+	}
+	var got []xml.Token
+	r := newXMLTreeTokenReader(xmlFile.Root())
+	for {
+		tok, err := r.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+
+	// the default namespace is encoded as xmlns="...".
+	buf := new(bytes.Buffer)
+	e := xml.NewEncoder(buf)
+	if err := e.EncodeToken(got[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, ` xmlns="http://example.com/ns"`) || strings.Contains(s, "xmlns:=") {
+		t.Errorf("unexpected start element: %s", s)
+	}
+}
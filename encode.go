@@ -0,0 +1,302 @@
+package androidbinary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encode writes the table in the binary format of resources.arsc.
+// The sizes of the chunks and the offsets in the string pools are recomputed,
+// so the strings and the entries of the table may be modified before encoding.
+// The chunks in the packages that TableFile doesn't parse, e.g. the shared library chunk of ResTableLibraryType,
+// are written as they are after the parsed chunks of the package.
+// The unknown chunks out of the packages are not written.
+func (f *TableFile) Encode(w io.Writer) error {
+	body := new(bytes.Buffer)
+	if f.stringPool != nil {
+		pool, err := f.stringPool.encode()
+		if err != nil {
+			return err
+		}
+		body.Write(pool)
+	}
+
+	ids := make([]uint32, 0, len(f.tablePackages))
+	for id := range f.tablePackages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		pkg, err := f.tablePackages[id].encode()
+		if err != nil {
+			return err
+		}
+		body.Write(pkg)
+	}
+
+	header := ResTableHeader{
+		Header: ResChunkHeader{
+			Type:       ResTableChunkType,
+			HeaderSize: uint16(binary.Size(ResTableHeader{})),
+		},
+		PackageCount: uint32(len(ids)),
+	}
+	header.Header.Size = uint32(header.Header.HeaderSize) + uint32(body.Len())
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// encodeHeader encodes header over raw, which is the original header in the file.
+// The result has the length of raw if raw is not empty,
+// so that the fields that the struct doesn't have are kept.
+func encodeHeader(header interface{}, raw []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, header)
+	if len(raw) == 0 {
+		return buf.Bytes()
+	}
+	ret := make([]byte, len(raw))
+	copy(ret, raw)
+	copy(ret, buf.Bytes())
+	return ret
+}
+
+func (p *TablePackage) encode() ([]byte, error) {
+	header := p.Header
+	header.Header.Type = ResTablePackageType
+	if len(p.rawHeader) == 0 {
+		header.Header.HeaderSize = uint16(binary.Size(header))
+	} else {
+		header.Header.HeaderSize = uint16(len(p.rawHeader))
+	}
+
+	body := new(bytes.Buffer)
+	header.TypeStrings = 0
+	if p.TypeStrings != nil {
+		pool, err := p.TypeStrings.encode()
+		if err != nil {
+			return nil, err
+		}
+		header.TypeStrings = uint32(header.Header.HeaderSize) + uint32(body.Len())
+		body.Write(pool)
+	}
+	header.KeyStrings = 0
+	if p.KeyStrings != nil {
+		pool, err := p.KeyStrings.encode()
+		if err != nil {
+			return nil, err
+		}
+		header.KeyStrings = uint32(header.Header.HeaderSize) + uint32(body.Len())
+		body.Write(pool)
+	}
+
+	// each type spec is followed by its types, same as aapt.
+	written := make(map[*TableType]bool, len(p.TableTypes))
+	for _, spec := range p.TypeSpecs {
+		body.Write(spec.encode())
+		for _, t := range p.TableTypes {
+			if t.Header.ID != spec.Header.ID {
+				continue
+			}
+			body.Write(t.encode())
+			written[t] = true
+		}
+	}
+	for _, t := range p.TableTypes {
+		if !written[t] {
+			body.Write(t.encode())
+		}
+	}
+	if len(p.StagedAliases) > 0 {
+		body.Write(encodeStagedAliases(p.StagedAliases))
+	}
+	for _, o := range p.Overlayables {
+		body.Write(o.encode())
+	}
+	for _, chunk := range p.unknownChunks {
+		body.Write(chunk)
+	}
+
+	header.Header.Size = uint32(header.Header.HeaderSize) + uint32(body.Len())
+	ret := encodeHeader(header, p.rawHeader)
+	return append(ret, body.Bytes()...), nil
+}
+
+func (s *TableTypeSpec) encode() []byte {
+	header := *s.Header
+	header.Header.Type = ResTableTypeSpecType
+	header.Header.HeaderSize = uint16(binary.Size(header))
+	header.Header.Size = uint32(header.Header.HeaderSize) + uint32(4*len(s.Flags))
+	header.EntryCount = uint32(len(s.Flags))
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, header)
+	binary.Write(buf, binary.LittleEndian, s.Flags)
+	return buf.Bytes()
+}
+
+func (o *TableOverlayable) encode() []byte {
+	body := new(bytes.Buffer)
+	for _, policy := range o.Policies {
+		header := *policy.Header
+		header.Header.Type = ResTableOverlayablePolicyType
+		header.Header.HeaderSize = uint16(binary.Size(header))
+		header.Header.Size = uint32(header.Header.HeaderSize) + uint32(4*len(policy.IDs))
+		header.EntryCount = uint32(len(policy.IDs))
+		binary.Write(body, binary.LittleEndian, header)
+		binary.Write(body, binary.LittleEndian, policy.IDs)
+	}
+
+	header := *o.Header
+	header.Header.Type = ResTableOverlayableType
+	header.Header.HeaderSize = uint16(binary.Size(header))
+	header.Header.Size = uint32(header.Header.HeaderSize) + uint32(body.Len())
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, header)
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+func encodeStagedAliases(aliases []TableStagedAlias) []byte {
+	var header ResTableStagedAlias
+	header.Header.Type = ResTableStagedAliasType
+	header.Header.HeaderSize = uint16(binary.Size(header))
+	header.Header.Size = uint32(header.Header.HeaderSize) + uint32(8*len(aliases))
+	header.Count = uint32(len(aliases))
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, header)
+	binary.Write(buf, binary.LittleEndian, aliases)
+	return buf.Bytes()
+}
+
+func (t *TableType) encode() []byte {
+	header := *t.Header
+	header.Header.Type = ResTableTypeType
+	if len(t.rawHeader) == 0 {
+		header.Header.HeaderSize = uint16(binary.Size(header))
+	} else {
+		header.Header.HeaderSize = uint16(len(t.rawHeader))
+	}
+	header.EntryCount = uint32(len(t.Entries))
+	header.EntriesStart = uint32(header.Header.HeaderSize) + 4*header.EntryCount
+
+	indexes := make([]uint32, len(t.Entries))
+	entries := new(bytes.Buffer)
+	for i, entry := range t.Entries {
+		if entry.Key == nil || entry.Value == nil {
+			indexes[i] = NoEntry
+			continue
+		}
+		indexes[i] = uint32(entries.Len())
+		binary.Write(entries, binary.LittleEndian, entry.Key)
+		if entry.Key.Flags&EntryFlagComplex != 0 {
+			entries.Write(entry.data)
+		} else {
+			binary.Write(entries, binary.LittleEndian, entry.Value)
+		}
+	}
+	header.Header.Size = header.EntriesStart + uint32(entries.Len())
+
+	ret := encodeHeader(header, t.rawHeader)
+	if len(t.rawHeader) != 0 && len(t.rawHeader) < binary.Size(header) {
+		// the header was shorter than ResTableType in the file.
+		ret = ret[:len(t.rawHeader)]
+	}
+	buf := bytes.NewBuffer(ret)
+	binary.Write(buf, binary.LittleEndian, indexes)
+	buf.Write(entries.Bytes())
+	return buf.Bytes()
+}
+
+// encode encodes the pool in the binary format.
+// The strings are encoded in UTF-8 if UTF8Flag is set, otherwise in UTF-16.
+func (pool *ResStringPool) encode() ([]byte, error) {
+	isUTF8 := pool.Header.Flags&UTF8Flag != 0
+	strs := pool.strings()
+	stringStarts := make([]uint32, len(strs))
+	data := new(bytes.Buffer)
+	for i, s := range strs {
+		stringStarts[i] = uint32(data.Len())
+		var err error
+		if isUTF8 {
+			err = writeUTF8(data, s)
+		} else {
+			err = writeUTF16(data, s)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for data.Len()%4 != 0 {
+		data.WriteByte(0)
+	}
+
+	header := pool.Header
+	header.Header.Type = ResStringPoolChunkType
+	header.Header.HeaderSize = uint16(binary.Size(header))
+	header.StringCount = uint32(len(strs))
+	header.StyleCount = uint32(len(pool.styleStarts))
+	header.StringStart = 0
+	if len(strs) > 0 {
+		header.StringStart = uint32(header.Header.HeaderSize) + 4*(header.StringCount+header.StyleCount)
+	}
+	header.StylesStart = 0
+	if len(pool.styleStarts) > 0 {
+		header.StylesStart = uint32(header.Header.HeaderSize) + 4*(header.StringCount+header.StyleCount) + uint32(data.Len())
+	}
+	header.Header.Size = uint32(header.Header.HeaderSize) + 4*(header.StringCount+header.StyleCount) +
+		uint32(data.Len()) + uint32(len(pool.styleData))
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, header)
+	binary.Write(buf, binary.LittleEndian, stringStarts)
+	binary.Write(buf, binary.LittleEndian, pool.styleStarts)
+	buf.Write(data.Bytes())
+	buf.Write(pool.styleData)
+	return buf.Bytes(), nil
+}
+
+func writeUTF16(w *bytes.Buffer, s string) error {
+	u := utf16.Encode([]rune(s))
+	if len(u) > 0x7FFFFFFF {
+		return fmt.Errorf("androidbinary: too long string: %d", len(u))
+	}
+	if len(u) > 0x7FFF {
+		binary.Write(w, binary.LittleEndian, uint16(len(u)>>16)|0x8000)
+	}
+	binary.Write(w, binary.LittleEndian, uint16(len(u)))
+	binary.Write(w, binary.LittleEndian, u)
+	binary.Write(w, binary.LittleEndian, uint16(0))
+	return nil
+}
+
+func writeUTF8(w *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("androidbinary: invalid UTF-8 string: %q", s)
+	}
+	u16len := len(utf16.Encode([]rune(s)))
+	if u16len > 0x7FFF || len(s) > 0x7FFF {
+		return fmt.Errorf("androidbinary: too long string: %d", len(s))
+	}
+	writeUTF8length(w, u16len)
+	writeUTF8length(w, len(s))
+	w.WriteString(s)
+	w.WriteByte(0)
+	return nil
+}
+
+func writeUTF8length(w *bytes.Buffer, n int) {
+	if n > 0x7F {
+		w.WriteByte(byte(n>>8) | 0x80)
+	}
+	w.WriteByte(byte(n))
+}
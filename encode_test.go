@@ -0,0 +1,118 @@
+package androidbinary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTableFileEncode(t *testing.T) {
+	files := []string{
+		"testdata/resources.arsc",
+		"testdata/MyApplication/resources.arsc",
+	}
+	for _, name := range files {
+		orig, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tableFile, err := NewTableFile(bytes.NewReader(orig))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := tableFile.Encode(buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(buf.Bytes(), orig) {
+			t.Errorf("%s: the encoded table differs from the original", name)
+		}
+
+		decoded, err := NewTableFile(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded, tableFile) {
+			t.Errorf("%s: the decoded table differs from the original", name)
+		}
+	}
+}
+
+func TestTableFileEncodeModified(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	val, err := tableFile.GetResourceByName("@string/app_name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableFile.stringPool.Strings[val.Data] = "すごいアプリ"
+	tableFile.tablePackages[0x7F].Header.Name = [128]uint16{'c', 'o', 'm', '.', 'e', 'x', 'a', 'm', 'p', 'l', 'e'}
+
+	buf := new(bytes.Buffer)
+	if err := tableFile.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := NewTableFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decoded.GetResource(0x7F0B0027, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "すごいアプリ" {
+		t.Errorf("got %q want すごいアプリ", got)
+	}
+	if got := decoded.Package(0x7F).Name(); got != "com.example" {
+		t.Errorf("got %q want com.example", got)
+	}
+	got, err = decoded.GetResource(0x7F0B0000, &ResTableConfig{Language: [2]uint8{'j', 'a'}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "ホームへ移動" {
+		t.Errorf("got %q want ホームへ移動", got)
+	}
+}
+
+func TestTableFileEncodeUnknownChunks(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+
+	// a shared library chunk with an entry, which TablePackage doesn't parse.
+	lib := new(bytes.Buffer)
+	binary.Write(lib, binary.LittleEndian, ResChunkHeader{Type: ResTableLibraryType, HeaderSize: 12, Size: 12 + 260})
+	binary.Write(lib, binary.LittleEndian, uint32(1))
+	binary.Write(lib, binary.LittleEndian, uint32(0x02))
+	binary.Write(lib, binary.LittleEndian, [128]uint16{'c', 'o', 'm', '.', 'l', 'i', 'b'})
+	tableFile.tablePackages[0x7F].unknownChunks = [][]byte{lib.Bytes()}
+
+	buf := new(bytes.Buffer)
+	if err := tableFile.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := NewTableFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.tablePackages[0x7F].unknownChunks; !reflect.DeepEqual(got, [][]byte{lib.Bytes()}) {
+		t.Errorf("got %x want %x", got, lib.Bytes())
+	}
+
+	// the chunk is written again as it is.
+	again := new(bytes.Buffer)
+	if err := decoded.Encode(again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), buf.Bytes()) {
+		t.Error("the table encoded again differs")
+	}
+	got, err := decoded.GetResource(0x7F0B0027, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "My Application" {
+		t.Errorf("got %q want My Application", got)
+	}
+}
@@ -23,3 +23,25 @@ func FuzzNewXMLFile(f *testing.F) {
 		}
 	})
 }
+
+func FuzzNewTableFile(f *testing.F) {
+	data, err := os.ReadFile("testdata/resources.arsc")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		table, err := NewTableFile(bytes.NewReader(data))
+		if err != nil {
+			t.Skip(err)
+		}
+		for _, config := range []*ResTableConfig{nil, {}} {
+			table.Entries(config)(func(id ResID, name string, value ResValue) bool {
+				table.GetResource(id, config)
+				table.GetBag(id, config)
+				return true
+			})
+		}
+	})
+}
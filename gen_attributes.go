@@ -0,0 +1,133 @@
+//go:build ignore
+// +build ignore
+
+// gen_attributes.go generates attributes.go from the resource map of jadx,
+// which lists the resource ids of the android framework.
+//
+// Usage:
+//
+//	go run gen_attributes.go [res-map.txt]
+//
+// If the file is omitted, the latest one is downloaded from GitHub.
+// The lines of the file are in the "01010000=attr/theme" format,
+// and the entries other than attributes are ignored.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const resMapURL = "https://raw.githubusercontent.com/skylot/jadx/master/jadx-core/src/main/resources/android/res-map.txt"
+
+type attribute struct {
+	id   uint32
+	name string
+}
+
+func main() {
+	var r io.Reader
+	if len(os.Args) > 1 {
+		f, err := os.Open(os.Args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	} else {
+		resp, err := http.Get(resMapURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("unexpected status: %s", resp.Status)
+		}
+		r = resp.Body
+	}
+
+	attrs, err := readAttributes(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(attrs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("attributes.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readAttributes(r io.Reader) ([]attribute, error) {
+	var attrs []attribute
+	seen := make(map[uint32]bool)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid line: %q", line)
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(line[:i], "0x"), 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line: %q: %v", line, err)
+		}
+		name := line[i+1:]
+		if !strings.HasPrefix(name, "attr/") || id>>24 != 0x01 {
+			continue
+		}
+		if seen[uint32(id)] {
+			return nil, fmt.Errorf("duplicated id: %q", line)
+		}
+		seen[uint32(id)] = true
+		attrs = append(attrs, attribute{id: uint32(id), name: strings.TrimPrefix(name, "attr/")})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].id < attrs[j].id })
+	return attrs, nil
+}
+
+func generate(attrs []attribute) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`// Code generated by gen_attributes.go; DO NOT EDIT.
+
+package androidbinary
+
+//go:generate go run gen_attributes.go
+
+// getAttributteName returns the name of the attribute of the android framework.
+// It returns an empty string if id is not a known attribute.
+//
+// The switch is compiled into a binary search over the constant ids, which is faster than
+// looking up a map or caching the results, and it is safe for concurrent use.
+// See BenchmarkGetAttributteName.
+//
+// https://github.com/skylot/jadx/blob/master/jadx-core/src/main/resources/android/res-map.txt
+func getAttributteName(id ResStringPoolRef) string {
+	switch id {
+`)
+	for _, attr := range attrs {
+		fmt.Fprintf(&buf, "\tcase 0x%08x:\n\t\treturn %q\n", attr.id, attr.name)
+	}
+	buf.WriteString(`	default:
+		return ""
+	}
+}
+`)
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,123 @@
+package androidbinary
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+)
+
+// ToJSON returns the element tree of f as nested JSON objects, e.g.
+//
+//	{"manifest": {"package": "com.example", "android:versionCode": 1, "application": [{"android:label": "Example"}]}}
+//
+// The tree is converted as DecodeMap does, with the following differences for JSON:
+// the attributes become the keys of the objects without the "@" prefix, so if an attribute has the same name as
+// a child element, the children win; the child elements are always grouped into arrays by their names,
+// even if there is only one; and the colors are rendered as "#aarrggbb".
+// The references that can't be resolved, and the attributes that refer to themes, are kept in the text format, e.g. "@0x7F0B0027".
+func (f *XMLFile) ToJSON(table *TableFile, config *ResTableConfig) ([]byte, error) {
+	m := &treeMapper{table: table, config: config, arrays: true, hexColors: true}
+	root, err := m.root(f)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(root)
+}
+
+// DecodeMap returns the element tree of f as nested maps, e.g.
+//
+//	map[string]interface{}{
+//		"manifest": map[string]interface{}{
+//			"@package": "com.example",
+//			"@android:versionCode": 1,
+//			"application": map[string]interface{}{"@android:label": "Example"},
+//		},
+//	}
+//
+// The attributes of an element become the keys prefixed with "@", so they never collide with the child elements.
+// A child element becomes a map under its name, and the children with the same name are
+// gathered into a []interface{} of the maps in document order, e.g. the activities of an application.
+// Note that the type of the value depends on the number of the children; use ToJSON for the uniform arrays.
+// The values are resolved with table and config as ResValue.Interface does, e.g. colors are color.NRGBA,
+// and the references that can't be resolved are kept in the text format, e.g. "@0x7F0B0027".
+func (f *XMLFile) DecodeMap(table *TableFile, config *ResTableConfig) (map[string]interface{}, error) {
+	m := &treeMapper{table: table, config: config, attrPrefix: "@"}
+	return m.root(f)
+}
+
+// treeMapper converts the element tree into nested maps for DecodeMap and ToJSON.
+type treeMapper struct {
+	table  *TableFile
+	config *ResTableConfig
+
+	// attrPrefix is prepended to the names of the attributes.
+	attrPrefix string
+
+	// arrays gathers the child elements into []interface{} even if there is only one with the name.
+	arrays bool
+
+	// hexColors renders the colors as "#aarrggbb" instead of color.NRGBA.
+	hexColors bool
+}
+
+func (m *treeMapper) root(f *XMLFile) (map[string]interface{}, error) {
+	if f.root == nil {
+		return nil, fmt.Errorf("androidbinary: root element not found")
+	}
+	return map[string]interface{}{
+		f.root.Name: m.element(f.root),
+	}, nil
+}
+
+func (m *treeMapper) element(elem *XMLElement) map[string]interface{} {
+	obj := make(map[string]interface{}, len(elem.Attrs)+len(elem.Children))
+	for i, attr := range elem.Attrs {
+		var raw *ResXMLTreeAttribute
+		if i < len(elem.rawAttrs) {
+			raw = &elem.rawAttrs[i]
+		}
+		obj[m.attrPrefix+attr.Name] = m.value(attr, raw)
+	}
+	for _, child := range elem.Children {
+		v := m.element(child)
+		switch prev := obj[child.Name].(type) {
+		case []interface{}:
+			obj[child.Name] = append(prev, v)
+		case map[string]interface{}:
+			obj[child.Name] = []interface{}{prev, v}
+		default:
+			// the first child with the name, which replaces the attribute with the same key if any.
+			if m.arrays {
+				obj[child.Name] = []interface{}{v}
+			} else {
+				obj[child.Name] = v
+			}
+		}
+	}
+	return obj
+}
+
+func (m *treeMapper) value(attr XMLAttr, raw *ResXMLTreeAttribute) interface{} {
+	v := resolvedAttrValue(attr, raw, m.table, m.config)
+	if c, ok := v.(color.NRGBA); ok && m.hexColors {
+		return fmt.Sprintf("#%02x%02x%02x%02x", c.A, c.R, c.G, c.B)
+	}
+	return v
+}
+
+// resolvedAttrValue returns the value of attr resolved with table and config as ResValue.Interface does.
+// The strings in the string pool of the XML file, and the references that can't be resolved, are returned as attr.Value.
+func resolvedAttrValue(attr XMLAttr, raw *ResXMLTreeAttribute, table *TableFile, config *ResTableConfig) interface{} {
+	if raw == nil || raw.RawValue != NilResStringPoolRef || raw.TypedValue.DataType == TypeString {
+		// the strings are in the string pool of the XML file, not in the table.
+		return attr.Value
+	}
+	v, err := raw.TypedValue.Interface(table, config)
+	if err != nil {
+		return attr.Value
+	}
+	if _, ok := v.(ResID); ok {
+		return attr.Value
+	}
+	return v
+}
@@ -0,0 +1,189 @@
+package androidbinary
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestXMLFileToJSON(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	data, err := xmlFile.ToJSON(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Manifest struct {
+			Package     string `json:"package"`
+			VersionCode int    `json:"android:versionCode"`
+			Application []struct {
+				Activity []struct {
+					Name string `json:"android:name"`
+				} `json:"activity"`
+			} `json:"application"`
+		} `json:"manifest"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Manifest.Package != "net.sorablue.shogo.FWMeasure" {
+		t.Errorf("got %q want net.sorablue.shogo.FWMeasure", v.Manifest.Package)
+	}
+	if v.Manifest.VersionCode != 1 {
+		t.Errorf("got %d want 1", v.Manifest.VersionCode)
+	}
+	if len(v.Manifest.Application) != 1 || len(v.Manifest.Application[0].Activity) != 4 {
+		t.Fatalf("unexpected application: %s", data)
+	}
+	if got := v.Manifest.Application[0].Activity[0].Name; got != "FWMeasureActivity" {
+		t.Errorf("got %q want FWMeasureActivity", got)
+	}
+}
+
+func TestXMLFileToJSONResolved(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	data, err := xmlFile.ToJSON(loadMyApplicationTestData(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Manifest struct {
+			Application []map[string]interface{} `json:"application"`
+		} `json:"manifest"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Manifest.Application) != 1 {
+		t.Fatalf("unexpected application: %s", data)
+	}
+	app := v.Manifest.Application[0]
+	cases := map[string]interface{}{
+		"android:label":       "My Application",
+		"android:debuggable":  true,
+		"android:theme":       "@0x7F0C0005", // styles are kept as references
+		"android:allowBackup": true,
+	}
+	for name, want := range cases {
+		if got := app[name]; got != want {
+			t.Errorf("%s: got %#v want %#v", name, got, want)
+		}
+	}
+}
+
+func TestXMLFileDecodeMap(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	m, err := xmlFile.DecodeMap(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, ok := m["manifest"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected manifest: %#v", m["manifest"])
+	}
+	if got := manifest["@package"]; got != "net.sorablue.shogo.FWMeasure" {
+		t.Errorf("got %#v want net.sorablue.shogo.FWMeasure", got)
+	}
+	if got := manifest["@android:versionCode"]; got != 1 {
+		t.Errorf("got %#v want 1", got)
+	}
+
+	// a single element is a map, and the repeated ones are a slice.
+	app, ok := manifest["application"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected application: %#v", manifest["application"])
+	}
+	activities, ok := app["activity"].([]interface{})
+	if !ok || len(activities) != 4 {
+		t.Fatalf("unexpected activities: %#v", app["activity"])
+	}
+	main := activities[0].(map[string]interface{})
+	if got := main["@android:name"]; got != "FWMeasureActivity" {
+		t.Errorf("got %#v want FWMeasureActivity", got)
+	}
+	filter, ok := main["intent-filter"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected intent-filter: %#v", main["intent-filter"])
+	}
+	action := filter["action"].(map[string]interface{})
+	if got := action["@android:name"]; got != "android.intent.action.MAIN" {
+		t.Errorf("got %#v want android.intent.action.MAIN", got)
+	}
+	if _, ok := manifest["uses-permission"].([]interface{}); !ok {
+		t.Errorf("unexpected uses-permission: %#v", manifest["uses-permission"])
+	}
+}
+
+func TestXMLFileDecodeMapResolved(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	m, err := xmlFile.DecodeMap(loadMyApplicationTestData(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := m["manifest"].(map[string]interface{})["application"].(map[string]interface{})
+	cases := map[string]interface{}{
+		"@android:label":      "My Application",
+		"@android:debuggable": true,
+		"@android:theme":      "@0x7F0C0005",
+	}
+	for name, want := range cases {
+		if got := app[name]; got != want {
+			t.Errorf("%s: got %#v want %#v", name, got, want)
+		}
+	}
+}
+
+func TestXMLFileMapCollisions(t *testing.T) {
+	// the attribute "item" collides with the child elements of the same name in ToJSON.
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "list",
+		testStringAttr("", "item", 0, "attribute"),
+		testTypedAttr(testAndroidNS, "background", 0x010100d4, TypeIntColorARGB8, 0xFF336699),
+	)
+	b.StartElement("", "single")
+	b.EndElement("", "single")
+	b.StartElement("", "item", testStringAttr("", "name", 0, "a"))
+	b.EndElement("", "item")
+	b.StartElement("", "item", testStringAttr("", "name", 0, "b"))
+	b.EndElement("", "item")
+	b.EndElement("", "list")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := xmlFile.DecodeMap(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"list": map[string]interface{}{
+			"@item":               "attribute",
+			"@android:background": color.NRGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xFF},
+			"single":              map[string]interface{}{},
+			"item": []interface{}{
+				map[string]interface{}{"@name": "a"},
+				map[string]interface{}{"@name": "b"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %#v want %#v", m, want)
+	}
+
+	data, err := xmlFile.ToJSON(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `{"list":{"android:background":"#ff336699","item":[{"name":"a"},{"name":"b"}],"single":[{}]}}`
+	if string(data) != wantJSON {
+		t.Errorf("got %s want %s", data, wantJSON)
+	}
+}
@@ -0,0 +1,682 @@
+package androidbinary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Instrumentation is an application instrumentation code.
+type Instrumentation struct {
+	Name            String `xml:"http://schemas.android.com/apk/res/android name,attr"`
+	Target          String `xml:"http://schemas.android.com/apk/res/android targetPackage,attr"`
+	HandleProfiling Bool   `xml:"http://schemas.android.com/apk/res/android handleProfiling,attr"`
+	FunctionalTest  Bool   `xml:"http://schemas.android.com/apk/res/android functionalTest,attr"`
+}
+
+// ActivityAction is an action of an activity.
+type ActivityAction struct {
+	Name String `xml:"http://schemas.android.com/apk/res/android name,attr"`
+}
+
+// ActivityCategory is a category of an activity.
+type ActivityCategory struct {
+	Name String `xml:"http://schemas.android.com/apk/res/android name,attr"`
+}
+
+// ActivityData is a data specification of an intent filter.
+type ActivityData struct {
+	Scheme      String `xml:"http://schemas.android.com/apk/res/android scheme,attr"`
+	Host        String `xml:"http://schemas.android.com/apk/res/android host,attr"`
+	Port        String `xml:"http://schemas.android.com/apk/res/android port,attr"`
+	Path        String `xml:"http://schemas.android.com/apk/res/android path,attr"`
+	PathPrefix  String `xml:"http://schemas.android.com/apk/res/android pathPrefix,attr"`
+	PathPattern String `xml:"http://schemas.android.com/apk/res/android pathPattern,attr"`
+	MimeType    String `xml:"http://schemas.android.com/apk/res/android mimeType,attr"`
+}
+
+// ActivityIntentFilter is an intent filter of an activity.
+type ActivityIntentFilter struct {
+	Actions    []ActivityAction   `xml:"action"`
+	Categories []ActivityCategory `xml:"category"`
+	Data       []ActivityData     `xml:"data"`
+}
+
+// AppActivity is an activity in an application.
+type AppActivity struct {
+	Theme             String                 `xml:"http://schemas.android.com/apk/res/android theme,attr"`
+	Name              String                 `xml:"http://schemas.android.com/apk/res/android name,attr"`
+	Label             String                 `xml:"http://schemas.android.com/apk/res/android label,attr"`
+	ScreenOrientation String                 `xml:"http://schemas.android.com/apk/res/android screenOrientation,attr"`
+	IntentFilters     []ActivityIntentFilter `xml:"intent-filter"`
+}
+
+// AppActivityAlias https://developer.android.com/guide/topics/manifest/activity-alias-element
+type AppActivityAlias struct {
+	Name           String                 `xml:"http://schemas.android.com/apk/res/android name,attr"`
+	Label          String                 `xml:"http://schemas.android.com/apk/res/android label,attr"`
+	TargetActivity String                 `xml:"http://schemas.android.com/apk/res/android targetActivity,attr"`
+	IntentFilters  []ActivityIntentFilter `xml:"intent-filter"`
+}
+
+// MetaData is a metadata in an application.
+type MetaData struct {
+	Name  String `xml:"http://schemas.android.com/apk/res/android name,attr"`
+	Value String `xml:"http://schemas.android.com/apk/res/android value,attr"`
+}
+
+// Application is an application in an APK.
+type Application struct {
+	AllowTaskReparenting  Bool               `xml:"http://schemas.android.com/apk/res/android allowTaskReparenting,attr"`
+	AllowBackup           Bool               `xml:"http://schemas.android.com/apk/res/android allowBackup,attr"`
+	BackupAgent           String             `xml:"http://schemas.android.com/apk/res/android backupAgent,attr"`
+	Debuggable            Bool               `xml:"http://schemas.android.com/apk/res/android debuggable,attr"`
+	Description           String             `xml:"http://schemas.android.com/apk/res/android description,attr"`
+	Enabled               Bool               `xml:"http://schemas.android.com/apk/res/android enabled,attr"`
+	HasCode               Bool               `xml:"http://schemas.android.com/apk/res/android hasCode,attr"`
+	HardwareAccelerated   Bool               `xml:"http://schemas.android.com/apk/res/android hardwareAccelerated,attr"`
+	Icon                  String             `xml:"http://schemas.android.com/apk/res/android icon,attr"`
+	KillAfterRestore      Bool               `xml:"http://schemas.android.com/apk/res/android killAfterRestore,attr"`
+	LargeHeap             Bool               `xml:"http://schemas.android.com/apk/res/android largeHeap,attr"`
+	Label                 String             `xml:"http://schemas.android.com/apk/res/android label,attr"`
+	Logo                  String             `xml:"http://schemas.android.com/apk/res/android logo,attr"`
+	ManageSpaceActivity   String             `xml:"http://schemas.android.com/apk/res/android manageSpaceActivity,attr"`
+	Name                  String             `xml:"http://schemas.android.com/apk/res/android name,attr"`
+	Permission            String             `xml:"http://schemas.android.com/apk/res/android permission,attr"`
+	Persistent            Bool               `xml:"http://schemas.android.com/apk/res/android persistent,attr"`
+	Process               String             `xml:"http://schemas.android.com/apk/res/android process,attr"`
+	RestoreAnyVersion     Bool               `xml:"http://schemas.android.com/apk/res/android restoreAnyVersion,attr"`
+	RequiredAccountType   String             `xml:"http://schemas.android.com/apk/res/android requiredAccountType,attr"`
+	RestrictedAccountType String             `xml:"http://schemas.android.com/apk/res/android restrictedAccountType,attr"`
+	SupportsRtl           Bool               `xml:"http://schemas.android.com/apk/res/android supportsRtl,attr"`
+	TaskAffinity          String             `xml:"http://schemas.android.com/apk/res/android taskAffinity,attr"`
+	TestOnly              Bool               `xml:"http://schemas.android.com/apk/res/android testOnly,attr"`
+	Theme                 String             `xml:"http://schemas.android.com/apk/res/android theme,attr"`
+	UIOptions             String             `xml:"http://schemas.android.com/apk/res/android uiOptions,attr"`
+	VMSafeMode            Bool               `xml:"http://schemas.android.com/apk/res/android vmSafeMode,attr"`
+	Activities            []AppActivity      `xml:"activity"`
+	ActivityAliases       []AppActivityAlias `xml:"activity-alias"`
+	MetaData              []MetaData         `xml:"meta-data"`
+}
+
+// UsesSDK is target SDK version.
+type UsesSDK struct {
+	Min    Int32 `xml:"http://schemas.android.com/apk/res/android minSdkVersion,attr"`
+	Target Int32 `xml:"http://schemas.android.com/apk/res/android targetSdkVersion,attr"`
+	Max    Int32 `xml:"http://schemas.android.com/apk/res/android maxSdkVersion,attr"`
+}
+
+// UsesPermission is user grant the system permission.
+type UsesPermission struct {
+	Name String `xml:"http://schemas.android.com/apk/res/android name,attr"`
+	Max  Int32  `xml:"http://schemas.android.com/apk/res/android maxSdkVersion,attr"`
+}
+
+// Manifest is a manifest of an APK.
+type Manifest struct {
+	Package                   String           `xml:"package,attr"`
+	CompileSDKVersion         Int32            `xml:"http://schemas.android.com/apk/res/android compileSdkVersion,attr"`
+	CompileSDKVersionCodename String           `xml:"http://schemas.android.com/apk/res/android compileSdkVersionCodename,attr"`
+	VersionCode               Int32            `xml:"http://schemas.android.com/apk/res/android versionCode,attr"`
+	VersionName               String           `xml:"http://schemas.android.com/apk/res/android versionName,attr"`
+	App                       Application      `xml:"application"`
+	Instrument                Instrumentation  `xml:"instrumentation"`
+	SDK                       UsesSDK          `xml:"uses-sdk"`
+	UsesPermissions           []UsesPermission `xml:"uses-permission"`
+}
+
+// Permissions returns the names of the permissions that the application uses.
+func (m *Manifest) Permissions() []string {
+	perms := make([]string, 0, len(m.UsesPermissions))
+	for _, p := range m.UsesPermissions {
+		name, err := p.Name.String()
+		if err != nil {
+			continue
+		}
+		perms = append(perms, name)
+	}
+	return perms
+}
+
+// DecodeManifest decodes the XML file as AndroidManifest.xml.
+// The attributes that refer to resources are resolved with table and config.
+func (f *XMLFile) DecodeManifest(table *TableFile, config *ResTableConfig) (*Manifest, error) {
+	var m Manifest
+	if err := f.Decode(&m, table, config); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Component is an application component declared in AndroidManifest.xml,
+// i.e. an activity, a service, a broadcast receiver or a content provider.
+type Component struct {
+	// Name is the value of android:name.
+	Name string
+
+	// Exported reports whether the component can be launched by other applications.
+	// If android:exported is omitted, it is inferred in the same way as Android does.
+	Exported bool
+
+	// Permission is the value of android:permission.
+	Permission string
+
+	// IntentFilters are the intent filters of the component.
+	IntentFilters []IntentFilter
+}
+
+// IntentFilter is an intent filter of a component.
+type IntentFilter struct {
+	Actions    []string
+	Categories []string
+	Data       []DataSpec
+}
+
+// DataSpec is a <data> element of an intent filter, which specifies the URIs and the MIME types
+// that the filter accepts. The omitted attributes are empty.
+//
+// Note that Android merges all the <data> elements of a filter: e.g. the schemes of one element
+// and the hosts of another are combined. The elements are returned as they are declared.
+type DataSpec struct {
+	Scheme              string
+	Host                string
+	Port                string
+	Path                string
+	PathPrefix          string
+	PathPattern         string
+	PathAdvancedPattern string
+	PathSuffix          string
+	MimeType            string
+}
+
+// Components returns the components declared in the manifest.
+// Activity aliases are returned as activities.
+//
+// If android:exported is omitted, activities, services and receivers are exported
+// when they have intent filters, and providers are exported when targetSdkVersion is 16 or lower.
+// The references, e.g. android:exported="@bool/exported", are resolved with Options.Table and its DefaultConfig,
+// so the values qualified by a locale, for example, are not used;
+// if targetSdkVersion can't be resolved or is the codename of a preview SDK, it is regarded as 17 or higher.
+func (f *XMLFile) Components() (activities, services, receivers, providers []Component, err error) {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return nil, nil, nil, nil, fmt.Errorf("androidbinary: manifest element not found")
+	}
+
+	table := f.opts.Table
+	var config *ResTableConfig
+	if table != nil {
+		config = table.DefaultConfig()
+	}
+	_, targetSDK, _, sdkErr := f.SDKVersions(table, config)
+	legacyProviders := sdkErr == nil && targetSDK <= 16
+	for _, app := range f.Find("/manifest/application") {
+		for _, elem := range app.Children {
+			switch elem.Name {
+			case "activity", "activity-alias":
+				activities = append(activities, newComponent(elem, table, config))
+			case "service":
+				services = append(services, newComponent(elem, table, config))
+			case "receiver":
+				receivers = append(receivers, newComponent(elem, table, config))
+			case "provider":
+				c := newComponent(elem, table, config)
+				if _, ok := elem.Attr("android:exported"); !ok {
+					// providers are exported by default before API level 17.
+					c.Exported = legacyProviders
+				}
+				providers = append(providers, c)
+			}
+		}
+	}
+	return activities, services, receivers, providers, nil
+}
+
+func newComponent(elem *XMLElement, table *TableFile, config *ResTableConfig) Component {
+	var c Component
+	c.Name, _ = elem.Attr("android:name")
+	c.Permission, _ = elem.Attr("android:permission")
+	for _, child := range elem.Children {
+		if child.Name != "intent-filter" {
+			continue
+		}
+		c.IntentFilters = append(c.IntentFilters, newIntentFilter(child))
+	}
+	c.Exported = elem.boolAttr("android:exported", table, config, len(c.IntentFilters) > 0)
+	return c
+}
+
+// SDKVersions returns android:minSdkVersion, android:targetSdkVersion and android:maxSdkVersion of <uses-sdk>.
+// The references, e.g. targetSdkVersion="@integer/target_sdk", are resolved with table and config.
+// As Android does, min defaults to 1 and target defaults to min; max is 0 if it is omitted.
+// It returns an error if a version is not an integer, e.g. the codename of a preview SDK.
+func (f *XMLFile) SDKVersions(table *TableFile, config *ResTableConfig) (min, target, max int, err error) {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return 0, 0, 0, fmt.Errorf("androidbinary: manifest element not found")
+	}
+	min = 1
+	for _, sdk := range f.Find("/manifest/uses-sdk") {
+		if _, ok := sdk.Attr("android:minSdkVersion"); ok {
+			if min, err = sdk.intAttr("android:minSdkVersion", table, config); err != nil {
+				return 0, 0, 0, err
+			}
+		}
+		if target, err = sdk.intAttr("android:targetSdkVersion", table, config); err != nil {
+			return 0, 0, 0, err
+		}
+		if max, err = sdk.intAttr("android:maxSdkVersion", table, config); err != nil {
+			return 0, 0, 0, err
+		}
+		break
+	}
+	if target == 0 {
+		target = min
+	}
+	return min, target, max, nil
+}
+
+// MainActivity returns the name of the activity that is launched from the launcher,
+// i.e. the first one that has an intent filter with android.intent.action.MAIN and android.intent.category.LAUNCHER.
+// The activities are searched before the activity aliases, and the target activity
+// of the alias is returned, the same as Apk.MainActivity does.
+// It returns an error if no activity is found.
+func (f *XMLFile) MainActivity(table *TableFile, config *ResTableConfig) (string, error) {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return "", fmt.Errorf("androidbinary: manifest element not found")
+	}
+	for _, elem := range f.Find("/manifest/application/activity") {
+		if isMainActivity(elem) {
+			return elem.stringAttr("android:name", table, config)
+		}
+	}
+	for _, elem := range f.Find("/manifest/application/activity-alias") {
+		if isMainActivity(elem) {
+			return elem.stringAttr("android:targetActivity", table, config)
+		}
+	}
+	return "", fmt.Errorf("androidbinary: main activity not found")
+}
+
+// IsDebuggable returns android:debuggable of <application>, resolved with table and config.
+// It returns false, the default of Android, if the attribute is omitted or isn't a boolean.
+func (f *XMLFile) IsDebuggable(table *TableFile, config *ResTableConfig) bool {
+	return f.applicationBool("android:debuggable", table, config, false)
+}
+
+// AllowBackup returns android:allowBackup of <application>, resolved with table and config.
+// It returns true, the default of Android, if the attribute is omitted or isn't a boolean.
+func (f *XMLFile) AllowBackup(table *TableFile, config *ResTableConfig) bool {
+	return f.applicationBool("android:allowBackup", table, config, true)
+}
+
+// UsesCleartextTraffic returns android:usesCleartextTraffic of <application>, resolved with table and config.
+// If the attribute is omitted or isn't a boolean, it returns the default of Android,
+// which is true if targetSdkVersion is 27 or lower and false otherwise.
+// The codename of a preview SDK is regarded as 28 or higher.
+//
+// Note that a network security config of the application overrides the attribute on Android 7.0 and higher.
+func (f *XMLFile) UsesCleartextTraffic(table *TableFile, config *ResTableConfig) bool {
+	_, target, _, err := f.SDKVersions(table, config)
+	def := err == nil && target < 28
+	return f.applicationBool("android:usesCleartextTraffic", table, config, def)
+}
+
+func (f *XMLFile) applicationBool(name string, table *TableFile, config *ResTableConfig, def bool) bool {
+	for _, app := range f.Find("/manifest/application") {
+		return app.boolAttr(name, table, config, def)
+	}
+	return def
+}
+
+// newIntentFilter returns the actions, the categories and the data of an <intent-filter> or an <intent> of <queries>.
+func newIntentFilter(elem *XMLElement) IntentFilter {
+	var filter IntentFilter
+	for _, item := range elem.Children {
+		name, _ := item.Attr("android:name")
+		switch item.Name {
+		case "action":
+			filter.Actions = append(filter.Actions, name)
+		case "category":
+			filter.Categories = append(filter.Categories, name)
+		case "data":
+			filter.Data = append(filter.Data, newDataSpec(item))
+		}
+	}
+	return filter
+}
+
+func isMainActivity(elem *XMLElement) bool {
+	for _, filter := range newComponent(elem, nil, nil).IntentFilters {
+		if containsString(filter.Actions, "android.intent.action.MAIN") &&
+			containsString(filter.Categories, "android.intent.category.LAUNCHER") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newDataSpec(elem *XMLElement) DataSpec {
+	var d DataSpec
+	d.Scheme, _ = elem.Attr("android:scheme")
+	d.Host, _ = elem.Attr("android:host")
+	d.Port, _ = elem.Attr("android:port")
+	d.Path, _ = elem.Attr("android:path")
+	d.PathPrefix, _ = elem.Attr("android:pathPrefix")
+	d.PathPattern, _ = elem.Attr("android:pathPattern")
+	d.PathAdvancedPattern, _ = elem.Attr("android:pathAdvancedPattern")
+	d.PathSuffix, _ = elem.Attr("android:pathSuffix")
+	d.MimeType, _ = elem.Attr("android:mimeType")
+	return d
+}
+
+// Permission is a permission that the application requests
+// with <uses-permission> or <uses-permission-sdk-23>.
+type Permission struct {
+	// Name is the value of android:name, e.g. "android.permission.INTERNET".
+	Name string
+
+	// MaxSDKVersion is the value of android:maxSdkVersion, the highest API level
+	// at which the permission is requested. It is 0 if the permission is requested on all API levels.
+	MaxSDKVersion int
+
+	// SDK23 reports whether the permission is declared by <uses-permission-sdk-23>,
+	// i.e. it is requested only on API level 23 and higher.
+	SDK23 bool
+}
+
+// Permissions returns the names of the permissions that the application requests
+// with <uses-permission> and <uses-permission-sdk-23>, in document order.
+// The references are resolved with table and config.
+func (f *XMLFile) Permissions(table *TableFile, config *ResTableConfig) ([]string, error) {
+	perms, err := f.RequestedPermissions(table, config)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = p.Name
+	}
+	return names, nil
+}
+
+// RequestedPermissions is like Permissions, but it also returns android:maxSdkVersion and the element names.
+func (f *XMLFile) RequestedPermissions(table *TableFile, config *ResTableConfig) ([]Permission, error) {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return nil, fmt.Errorf("androidbinary: manifest element not found")
+	}
+
+	var perms []Permission
+	for _, elem := range root.Children {
+		if elem.Name != "uses-permission" && elem.Name != "uses-permission-sdk-23" {
+			continue
+		}
+		var p Permission
+		var err error
+		if p.Name, err = elem.stringAttr("android:name", table, config); err != nil {
+			return nil, err
+		}
+		if p.MaxSDKVersion, err = elem.intAttr("android:maxSdkVersion", table, config); err != nil {
+			return nil, err
+		}
+		p.SDK23 = elem.Name == "uses-permission-sdk-23"
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// Queries are the other applications that the application interacts with,
+// declared in the <queries> elements for the package visibility of Android 11 and higher.
+type Queries struct {
+	// Packages are the package names of the <package> elements.
+	Packages []string
+
+	// Intents are the <intent> elements, which have the actions, the categories and the data as intent filters do.
+	Intents []IntentFilter
+
+	// Authorities are the content provider authorities of the <provider> elements.
+	// The authorities separated by semicolons in an element are split.
+	Authorities []string
+}
+
+// Queries returns the contents of the <queries> elements of the manifest, in document order.
+// The contents of multiple <queries> elements are merged, as Android does.
+// It returns empty Queries if the manifest has no <queries> element.
+func (f *XMLFile) Queries() (*Queries, error) {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return nil, fmt.Errorf("androidbinary: manifest element not found")
+	}
+	q := new(Queries)
+	for _, queries := range f.Find("/manifest/queries") {
+		for _, elem := range queries.Children {
+			switch elem.Name {
+			case "package":
+				if name, ok := elem.Attr("android:name"); ok {
+					q.Packages = append(q.Packages, name)
+				}
+			case "intent":
+				q.Intents = append(q.Intents, newIntentFilter(elem))
+			case "provider":
+				authorities, _ := elem.Attr("android:authorities")
+				for _, authority := range strings.Split(authorities, ";") {
+					if authority != "" {
+						q.Authorities = append(q.Authorities, authority)
+					}
+				}
+			}
+		}
+	}
+	return q, nil
+}
+
+// OverlayInfo is the <overlay> element of the manifest of a runtime resource overlay (RRO).
+// The omitted attributes are zero.
+type OverlayInfo struct {
+	// TargetPackage is the package whose resources the overlay replaces.
+	TargetPackage string
+
+	// TargetName is the name of the overlayable set of the target that the overlay replaces.
+	TargetName string
+
+	// Priority is the order of the static overlays; the higher one is applied later.
+	Priority int
+
+	// IsStatic reports whether the overlay is static, i.e. enabled by the system and can't be disabled.
+	IsStatic bool
+
+	// RequiredSystemPropertyName and RequiredSystemPropertyValue are the system property
+	// that must have the value for the overlay to be enabled.
+	RequiredSystemPropertyName  string
+	RequiredSystemPropertyValue string
+
+	// ResourcesMap is the reference to the XML file that maps the target resources to the overlay resources,
+	// e.g. "@0x7F0F0000".
+	ResourcesMap string
+}
+
+// Overlay returns the <overlay> element of the manifest of a runtime resource overlay.
+// The references, e.g. android:priority="@integer/priority", are resolved with table and config.
+// It returns an error if the manifest has no <overlay> element, i.e. the APK is not an overlay.
+func (f *XMLFile) Overlay(table *TableFile, config *ResTableConfig) (*OverlayInfo, error) {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return nil, fmt.Errorf("androidbinary: manifest element not found")
+	}
+	for _, elem := range f.Find("/manifest/overlay") {
+		o := new(OverlayInfo)
+		var err error
+		if o.TargetPackage, err = elem.stringAttr("android:targetPackage", table, config); err != nil {
+			return nil, err
+		}
+		if o.TargetName, err = elem.stringAttr("android:targetName", table, config); err != nil {
+			return nil, err
+		}
+		if o.Priority, err = elem.intAttr("android:priority", table, config); err != nil {
+			return nil, err
+		}
+		o.IsStatic = elem.boolAttr("android:isStatic", table, config, false)
+		if o.RequiredSystemPropertyName, err = elem.stringAttr("android:requiredSystemPropertyName", table, config); err != nil {
+			return nil, err
+		}
+		if o.RequiredSystemPropertyValue, err = elem.stringAttr("android:requiredSystemPropertyValue", table, config); err != nil {
+			return nil, err
+		}
+		// the map is a file, so the reference is kept as it is.
+		o.ResourcesMap, _ = elem.Attr("android:resourcesMap")
+		return o, nil
+	}
+	return nil, fmt.Errorf("androidbinary: overlay element not found")
+}
+
+// SplitName returns the split attribute of the manifest element, e.g. "config.arm64_v8a" or "feature_camera".
+// It is empty for the base APK.
+func (f *XMLFile) SplitName() string {
+	return f.manifestAttr("split")
+}
+
+// ConfigForSplit returns the configForSplit attribute of the manifest element,
+// i.e. the name of the feature split that the configuration split is for.
+// It is empty for the configuration splits of the base APK and for the other APKs.
+func (f *XMLFile) ConfigForSplit() string {
+	return f.manifestAttr("configForSplit")
+}
+
+// IsFeatureSplit returns android:isFeatureSplit of the manifest element,
+// i.e. whether the split is a feature split, which has code, rather than a configuration split.
+// It returns false if the attribute is omitted.
+func (f *XMLFile) IsFeatureSplit() bool {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return false
+	}
+	return root.boolAttr("android:isFeatureSplit", nil, nil, false)
+}
+
+func (f *XMLFile) manifestAttr(name string) string {
+	root := f.Root()
+	if root == nil || root.Name != "manifest" {
+		return ""
+	}
+	v, _ := root.Attr(name)
+	return v
+}
+
+// PackageName returns the package attribute of the manifest element of the binary XML file r.
+// Unlike NewXMLFile and Decode, it doesn't build the element tree:
+// it scans the chunks only until the root element, and reads only the strings it needs.
+func PackageName(r io.ReaderAt) (string, error) {
+	header, err := readXMLHeader(r)
+	if err != nil {
+		return "", err
+	}
+
+	var pool *ResStringPoolHeader
+	var poolReader *io.SectionReader
+	var stringStarts []uint32
+	getString := func(ref ResStringPoolRef) (string, error) {
+		if pool == nil || int64(ref) >= int64(len(stringStarts)) {
+			return "", &InvalidReferenceError{Ref: ref}
+		}
+		if _, err := poolReader.Seek(int64(pool.StringStart+stringStarts[ref]), io.SeekStart); err != nil {
+			return "", err
+		}
+		if (pool.Flags & UTF8Flag) == 0 {
+			return readUTF16(poolReader)
+		}
+		return readUTF8(poolReader)
+	}
+
+	offset := int64(header.HeaderSize)
+	for offset < int64(header.Size) {
+		sr := io.NewSectionReader(r, offset, int64(header.Size)-offset)
+		chunkHeader := new(ResChunkHeader)
+		if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
+			return "", err
+		}
+		if err := validateChunkHeader(chunkHeader); err != nil {
+			return "", err
+		}
+		if _, err := sr.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+
+		switch chunkHeader.Type {
+		case ResStringPoolChunkType:
+			pool = new(ResStringPoolHeader)
+			if err := binary.Read(sr, binary.LittleEndian, pool); err != nil {
+				return "", err
+			}
+			stringStarts, err = readUint32s(sr, pool.StringCount)
+			if err != nil {
+				return "", err
+			}
+			poolReader = io.NewSectionReader(r, offset, int64(chunkHeader.Size))
+		case ResXMLStartElementType:
+			return scanPackageName(sr, getString)
+		}
+		offset += int64(chunkHeader.Size)
+	}
+	return "", fmt.Errorf("androidbinary: manifest element not found")
+}
+
+func scanPackageName(sr *io.SectionReader, getString func(ref ResStringPoolRef) (string, error)) (string, error) {
+	node := new(ResXMLTreeNode)
+	if err := binary.Read(sr, binary.LittleEndian, node); err != nil {
+		return "", err
+	}
+	if _, err := sr.Seek(int64(node.Header.HeaderSize), io.SeekStart); err != nil {
+		return "", err
+	}
+	ext := new(ResXMLTreeAttrExt)
+	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
+		return "", err
+	}
+	name, err := getString(ext.Name)
+	if err != nil {
+		return "", err
+	}
+	if ext.NS != NilResStringPoolRef || name != "manifest" {
+		return "", fmt.Errorf("androidbinary: manifest element not found")
+	}
+
+	if ext.AttributeCount > 0 && int(ext.AttributeSize) < binary.Size(ResXMLTreeAttribute{}) {
+		return "", fmt.Errorf("androidbinary: invalid attribute size: %d", ext.AttributeSize)
+	}
+	offset := int64(ext.AttributeStart) + int64(node.Header.HeaderSize)
+	for i := 0; i < int(ext.AttributeCount); i++ {
+		if _, err := sr.Seek(offset, io.SeekStart); err != nil {
+			return "", err
+		}
+		attr := new(ResXMLTreeAttribute)
+		if err := binary.Read(sr, binary.LittleEndian, attr); err != nil {
+			return "", err
+		}
+		offset += int64(ext.AttributeSize)
+
+		// the package attribute has no namespace.
+		if attr.NS != NilResStringPoolRef || attr.RawValue == NilResStringPoolRef {
+			continue
+		}
+		name, err := getString(attr.Name)
+		if err != nil {
+			return "", err
+		}
+		if name == "package" {
+			return getString(attr.RawValue)
+		}
+	}
+	return "", fmt.Errorf("androidbinary: package attribute not found")
+}
@@ -0,0 +1,929 @@
+package androidbinary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeManifest(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	table := loadMyApplicationTestData(t)
+
+	m, err := xmlFile.DecodeManifest(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Package.MustString(); got != "com.shogo82148.androidbinary.myapplication" {
+		t.Errorf("Package: got %q", got)
+	}
+	if got := m.VersionCode.MustInt32(); got != 1 {
+		t.Errorf("VersionCode: got %d want 1", got)
+	}
+	if got := m.VersionName.MustString(); got != "1.0" {
+		t.Errorf("VersionName: got %q want 1.0", got)
+	}
+	if got := m.SDK.Min.MustInt32(); got != 26 {
+		t.Errorf("SDK.Min: got %d want 26", got)
+	}
+	if got := m.SDK.Target.MustInt32(); got != 28 {
+		t.Errorf("SDK.Target: got %d want 28", got)
+	}
+
+	// references are resolved against the table
+	if got := m.App.Label.MustString(); got != "My Application" {
+		t.Errorf("App.Label: got %q want My Application", got)
+	}
+	if got := m.App.Debuggable.MustBool(); !got {
+		t.Errorf("App.Debuggable: got %v want true", got)
+	}
+	if len(m.App.MetaData) != 8 {
+		t.Fatalf("App.MetaData: got %d elements want 8", len(m.App.MetaData))
+	}
+	if got := m.App.MetaData[7].Value.MustString(); got != "foobar" {
+		t.Errorf("App.MetaData[7].Value: got %q want foobar", got)
+	}
+}
+
+func TestManifestPermissions(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	m, err := xmlFile.DecodeManifest(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"android.permission.CAMERA",
+		"android.permission.WAKE_LOCK",
+		"android.permission.ACCESS_FINE_LOCATION",
+		"android.permission.INTERNET",
+		"android.permission.ACCESS_MOCK_LOCATION",
+		"android.permission.RECORD_AUDIO",
+	}
+	if got := m.Permissions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if len(m.App.Activities) != 4 {
+		t.Errorf("App.Activities: got %d elements want 4", len(m.App.Activities))
+	}
+	if got := m.VersionName.MustString(); got != "テスト版" {
+		t.Errorf("VersionName: got %q", got)
+	}
+}
+
+func TestXMLFilePermissions(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest")
+	b.StartElement("", "uses-permission", testStringAttr(testAndroidNS, "name", 0x01010003, "android.permission.INTERNET"))
+	b.EndElement("", "uses-permission")
+	b.StartElement("", "uses-permission",
+		testStringAttr(testAndroidNS, "name", 0x01010003, "android.permission.WRITE_EXTERNAL_STORAGE"),
+		testTypedAttr(testAndroidNS, "maxSdkVersion", 0x01010271, TypeIntDec, 18),
+	)
+	b.EndElement("", "uses-permission")
+	b.StartElement("", "uses-permission-sdk-23",
+		testStringAttr(testAndroidNS, "name", 0x01010003, "android.permission.READ_CONTACTS"),
+		testTypedAttr(testAndroidNS, "maxSdkVersion", 0x01010271, TypeReference, 0x7F010000),
+	)
+	b.EndElement("", "uses-permission-sdk-23")
+	b.StartElement("", "application")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := newTestTableFile(ResValue{DataType: TypeIntDec, Data: 28})
+
+	perms, err := xmlFile.RequestedPermissions(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Permission{
+		{Name: "android.permission.INTERNET"},
+		{Name: "android.permission.WRITE_EXTERNAL_STORAGE", MaxSDKVersion: 18},
+		{Name: "android.permission.READ_CONTACTS", MaxSDKVersion: 28, SDK23: true}, // resolved with the table
+	}
+	if !reflect.DeepEqual(perms, want) {
+		t.Errorf("got %#v want %#v", perms, want)
+	}
+
+	names, err := xmlFile.Permissions(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"android.permission.INTERNET", "android.permission.WRITE_EXTERNAL_STORAGE", "android.permission.READ_CONTACTS"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("got %v want %v", names, wantNames)
+	}
+
+	// the reference can't be an integer without the table.
+	if _, err := xmlFile.Permissions(nil, nil); err == nil {
+		t.Error("want error")
+	}
+
+	// same as Manifest.Permissions
+	xmlFile = loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	m, err := xmlFile.DecodeManifest(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err = xmlFile.Permissions(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, m.Permissions()) {
+		t.Errorf("got %v want %v", names, m.Permissions())
+	}
+}
+
+func TestComponents(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	activities, services, receivers, providers, err := xmlFile.Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 0 || len(receivers) != 0 || len(providers) != 0 {
+		t.Errorf("got %d services, %d receivers, %d providers want none", len(services), len(receivers), len(providers))
+	}
+	want := []Component{
+		{
+			Name:     "FWMeasureActivity",
+			Exported: true, // implied by the intent filter
+			IntentFilters: []IntentFilter{
+				{
+					Actions:    []string{"android.intent.action.MAIN"},
+					Categories: []string{"android.intent.category.LAUNCHER"},
+				},
+			},
+		},
+		{Name: "MapActivity"},
+		{Name: "SettingActivity"},
+		{Name: "PlaceSettingActivity"},
+	}
+	if !reflect.DeepEqual(activities, want) {
+		t.Errorf("got %#v want %#v", activities, want)
+	}
+}
+
+func TestComponentsIntentFilterData(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest")
+	b.StartElement("", "application")
+	b.StartElement("", "activity", testStringAttr(testAndroidNS, "name", 0x01010003, ".MainActivity"))
+
+	b.StartElement("", "intent-filter")
+	b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.action.MAIN"))
+	b.EndElement("", "action")
+	b.StartElement("", "category", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.category.LAUNCHER"))
+	b.EndElement("", "category")
+	b.EndElement("", "intent-filter")
+
+	b.StartElement("", "intent-filter")
+	b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.action.VIEW"))
+	b.EndElement("", "action")
+	b.StartElement("", "category", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.category.DEFAULT"))
+	b.EndElement("", "category")
+	b.StartElement("", "category", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.category.BROWSABLE"))
+	b.EndElement("", "category")
+	b.StartElement("", "data",
+		testStringAttr(testAndroidNS, "scheme", 0x01010027, "https"),
+		testStringAttr(testAndroidNS, "host", 0x01010028, "example.com"),
+		testStringAttr(testAndroidNS, "pathPrefix", 0x0101002b, "/items"),
+	)
+	b.EndElement("", "data")
+	b.StartElement("", "data", testStringAttr(testAndroidNS, "mimeType", 0x01010026, "image/*"))
+	b.EndElement("", "data")
+	b.EndElement("", "intent-filter")
+
+	b.EndElement("", "activity")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activities, _, _, _, err := xmlFile.Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Component{
+		{
+			Name:     ".MainActivity",
+			Exported: true,
+			IntentFilters: []IntentFilter{
+				{
+					Actions:    []string{"android.intent.action.MAIN"},
+					Categories: []string{"android.intent.category.LAUNCHER"},
+				},
+				{
+					Actions:    []string{"android.intent.action.VIEW"},
+					Categories: []string{"android.intent.category.DEFAULT", "android.intent.category.BROWSABLE"},
+					Data: []DataSpec{
+						{Scheme: "https", Host: "example.com", PathPrefix: "/items"},
+						{MimeType: "image/*"},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(activities, want) {
+		t.Errorf("got %#v want %#v", activities, want)
+	}
+
+	m, err := xmlFile.DecodeManifest(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := m.App.Activities[0].IntentFilters[1].Data
+	if len(data) != 2 {
+		t.Fatalf("got %d data elements want 2", len(data))
+	}
+	if got := data[0].Host.MustString(); got != "example.com" {
+		t.Errorf("got %q want example.com", got)
+	}
+	if got := data[1].MimeType.MustString(); got != "image/*" {
+		t.Errorf("got %q want image/*", got)
+	}
+}
+
+func TestXMLFileSDKVersions(t *testing.T) {
+	newManifest := func(attrs ...testXMLAttr) *XMLFile {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "manifest")
+		b.StartElement("", "uses-sdk", attrs...)
+		b.EndElement("", "uses-sdk")
+		b.EndElement("", "manifest")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return xmlFile
+	}
+	table := newTestTableFile(ResValue{DataType: TypeIntDec, Data: 33})
+
+	cases := []struct {
+		name                         string
+		xmlFile                      *XMLFile
+		wantMin, wantTarget, wantMax int
+	}{
+		{
+			name: "reference",
+			xmlFile: newManifest(
+				testTypedAttr(testAndroidNS, "minSdkVersion", 0x0101020c, TypeIntDec, 21),
+				testTypedAttr(testAndroidNS, "targetSdkVersion", 0x01010270, TypeReference, 0x7F010000),
+				testTypedAttr(testAndroidNS, "maxSdkVersion", 0x01010271, TypeIntDec, 34),
+			),
+			wantMin: 21, wantTarget: 33, wantMax: 34,
+		},
+		{
+			name:    "target defaults to min",
+			xmlFile: newManifest(testTypedAttr(testAndroidNS, "minSdkVersion", 0x0101020c, TypeIntDec, 21)),
+			wantMin: 21, wantTarget: 21,
+		},
+		{
+			name:    "min defaults to 1",
+			xmlFile: newManifest(),
+			wantMin: 1, wantTarget: 1,
+		},
+		{
+			name:    "string",
+			xmlFile: newManifest(testStringAttr(testAndroidNS, "minSdkVersion", 0x0101020c, "26")),
+			wantMin: 26, wantTarget: 26,
+		},
+	}
+	for _, c := range cases {
+		min, target, max, err := c.xmlFile.SDKVersions(table, nil)
+		if err != nil {
+			t.Errorf("%s: got %v want no error", c.name, err)
+			continue
+		}
+		if min != c.wantMin || target != c.wantTarget || max != c.wantMax {
+			t.Errorf("%s: got %d, %d, %d want %d, %d, %d", c.name, min, target, max, c.wantMin, c.wantTarget, c.wantMax)
+		}
+	}
+
+	// the codename of a preview SDK
+	xmlFile := newManifest(testStringAttr(testAndroidNS, "minSdkVersion", 0x0101020c, "Q"))
+	if _, _, _, err := xmlFile.SDKVersions(nil, nil); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestXMLFileMainActivity(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	got, err := xmlFile.MainActivity(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "FWMeasureActivity" {
+		t.Errorf("got %q want FWMeasureActivity", got)
+	}
+
+	newManifest := func(alias bool) *XMLFile {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "manifest")
+		b.StartElement("", "application")
+		b.StartElement("", "activity", testStringAttr(testAndroidNS, "name", 0x01010003, ".RealMain"))
+		b.EndElement("", "activity")
+		b.StartElement("", "activity-alias",
+			testStringAttr(testAndroidNS, "name", 0x01010003, ".Launcher"),
+			testStringAttr(testAndroidNS, "targetActivity", 0x01010202, ".RealMain"),
+		)
+		b.StartElement("", "intent-filter")
+		b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.action.MAIN"))
+		b.EndElement("", "action")
+		if alias {
+			b.StartElement("", "category", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.category.LAUNCHER"))
+			b.EndElement("", "category")
+		}
+		b.EndElement("", "intent-filter")
+		b.EndElement("", "activity-alias")
+		b.EndElement("", "application")
+		b.EndElement("", "manifest")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return xmlFile
+	}
+
+	// the target of the alias
+	got, err = newManifest(true).MainActivity(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ".RealMain" {
+		t.Errorf("got %q want .RealMain", got)
+	}
+
+	// MAIN without LAUNCHER
+	if _, err := newManifest(false).MainActivity(nil, nil); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestComponentsExported(t *testing.T) {
+	newManifest := func(targetSDK uint32) *XMLFile {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "manifest")
+		b.StartElement("", "uses-sdk", testTypedAttr(testAndroidNS, "targetSdkVersion", 0x01010270, TypeIntDec, targetSDK))
+		b.EndElement("", "uses-sdk")
+		b.StartElement("", "application")
+
+		// explicitly not exported even though it has an intent filter
+		b.StartElement("", "service",
+			testStringAttr(testAndroidNS, "name", 0x01010003, ".Service"),
+			testTypedAttr(testAndroidNS, "exported", 0x01010010, TypeIntBoolean, 0),
+			testStringAttr(testAndroidNS, "permission", 0x01010006, "com.example.PERMISSION"),
+		)
+		b.StartElement("", "intent-filter")
+		b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "com.example.ACTION"))
+		b.EndElement("", "action")
+		b.EndElement("", "intent-filter")
+		b.EndElement("", "service")
+
+		// exported by the intent filter
+		b.StartElement("", "receiver", testStringAttr(testAndroidNS, "name", 0x01010003, ".Receiver"))
+		b.StartElement("", "intent-filter")
+		b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.action.BOOT_COMPLETED"))
+		b.EndElement("", "action")
+		b.EndElement("", "intent-filter")
+		b.EndElement("", "receiver")
+
+		// depends on targetSdkVersion
+		b.StartElement("", "provider", testStringAttr(testAndroidNS, "name", 0x01010003, ".Provider"))
+		b.EndElement("", "provider")
+
+		b.EndElement("", "application")
+		b.EndElement("", "manifest")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return xmlFile
+	}
+
+	cases := []struct {
+		targetSDK        uint32
+		providerExported bool
+	}{
+		{16, true},
+		{17, false},
+	}
+	for _, c := range cases {
+		_, services, receivers, providers, err := newManifest(c.targetSDK).Components()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != 1 || services[0].Exported {
+			t.Errorf("%d: unexpected services: %#v", c.targetSDK, services)
+		} else if services[0].Permission != "com.example.PERMISSION" {
+			t.Errorf("%d: got %q want com.example.PERMISSION", c.targetSDK, services[0].Permission)
+		}
+		if len(receivers) != 1 || !receivers[0].Exported {
+			t.Errorf("%d: unexpected receivers: %#v", c.targetSDK, receivers)
+		}
+		if len(providers) != 1 || providers[0].Exported != c.providerExported {
+			t.Errorf("%d: unexpected providers: %#v", c.targetSDK, providers)
+		}
+	}
+}
+
+func TestComponentsTargetSDKReference(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest")
+	b.StartElement("", "uses-sdk", testTypedAttr(testAndroidNS, "targetSdkVersion", 0x01010270, TypeReference, 0x7F010000))
+	b.EndElement("", "uses-sdk")
+	b.StartElement("", "application")
+	b.StartElement("", "provider", testStringAttr(testAndroidNS, "name", 0x01010003, ".Provider"))
+	b.EndElement("", "provider")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+
+	cases := []struct {
+		name  string
+		table *TableFile
+		want  bool
+	}{
+		// @integer/target_sdk is 16.
+		{"resolved", newTestTableFile(ResValue{DataType: TypeIntDec, Data: 16}), true},
+		{"unresolved", nil, false},
+	}
+	for _, c := range cases {
+		xmlFile, err := NewXMLFileOptions(bytes.NewReader(b.Bytes()), Options{Table: c.table})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, _, providers, err := xmlFile.Components()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(providers) != 1 || providers[0].Exported != c.want {
+			t.Errorf("%s: unexpected providers: %#v", c.name, providers)
+		}
+	}
+}
+
+func TestComponentsExportedConfig(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest")
+	b.StartElement("", "application")
+	b.StartElement("", "service",
+		testStringAttr(testAndroidNS, "name", 0x01010003, ".Service"),
+		testTypedAttr(testAndroidNS, "exported", 0x01010010, TypeReference, 0x7F010000),
+	)
+	b.EndElement("", "service")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+
+	// @bool/exported is false by default, and true in Japanese.
+	table := newTestTableFile(ResValue{DataType: TypeIntBoolean, Data: 0})
+	p := table.tablePackages[0x7F]
+	p.TableTypes = append(p.TableTypes, &TableType{
+		Header: &ResTableType{ID: 0x01, EntryCount: 1, Config: ResTableConfig{Language: [2]uint8{'j', 'a'}}},
+		Entries: []TableEntry{
+			{Key: &ResTableEntry{Size: 8}, Value: &ResValue{DataType: TypeIntBoolean, Data: 0xFFFFFFFF}},
+		},
+	})
+
+	xmlFile, err := NewXMLFileOptions(bytes.NewReader(b.Bytes()), Options{Table: table})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, services, _, _, err := xmlFile.Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Exported {
+		t.Errorf("unexpected services: %#v", services)
+	}
+}
+
+func TestManifestNamespacePrefix(t *testing.T) {
+	// the android namespace is declared with the prefix "a".
+	b := new(testXMLBuilder)
+	b.StartNamespace("a", testAndroidNS)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.StartElement("", "uses-sdk", testTypedAttr(testAndroidNS, "targetSdkVersion", 0x01010270, TypeIntDec, 30))
+	b.EndElement("", "uses-sdk")
+	b.StartElement("", "uses-permission", testStringAttr(testAndroidNS, "name", 0x01010003, "android.permission.INTERNET"))
+	b.EndElement("", "uses-permission")
+	b.StartElement("", "application", testTypedAttr(testAndroidNS, "debuggable", 0x0101000f, TypeIntBoolean, 1))
+	b.StartElement("", "activity",
+		testStringAttr(testAndroidNS, "name", 0x01010003, ".MainActivity"),
+		testTypedAttr(testAndroidNS, "exported", 0x01010010, TypeIntBoolean, 0),
+	)
+	b.StartElement("", "intent-filter")
+	b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.action.MAIN"))
+	b.EndElement("", "action")
+	b.StartElement("", "category", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.category.LAUNCHER"))
+	b.EndElement("", "category")
+	b.EndElement("", "intent-filter")
+	b.EndElement("", "activity")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("a", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	perms, err := xmlFile.Permissions(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(perms, []string{"android.permission.INTERNET"}) {
+		t.Errorf("Permissions: got %q", perms)
+	}
+	main, err := xmlFile.MainActivity(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main != ".MainActivity" {
+		t.Errorf("MainActivity: got %q want .MainActivity", main)
+	}
+	activities, _, _, _, err := xmlFile.Components()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 1 || activities[0].Name != ".MainActivity" || activities[0].Exported {
+		t.Errorf("Components: unexpected activities: %#v", activities)
+	}
+	if _, target, _, err := xmlFile.SDKVersions(nil, nil); err != nil || target != 30 {
+		t.Errorf("SDKVersions: got %d, %v want 30", target, err)
+	}
+	if !xmlFile.IsDebuggable(nil, nil) {
+		t.Error("IsDebuggable: want true")
+	}
+	if got := xmlFile.Find("//activity[@android:name='.MainActivity']"); len(got) != 1 {
+		t.Errorf("Find: got %d elements want 1", len(got))
+	}
+
+	var m struct {
+		Permissions []struct {
+			Name string `androidbinary:"android:name"`
+		} `xml:"uses-permission"`
+	}
+	if err := xmlFile.Decode(&m, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Permissions) != 1 || m.Permissions[0].Name != "android.permission.INTERNET" {
+		t.Errorf("Decode: unexpected permissions: %#v", m.Permissions)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"testdata/AndroidManifest.xml", "net.sorablue.shogo.FWMeasure"},
+		{"testdata/MyApplication/AndroidManifest.xml", "com.shogo82148.androidbinary.myapplication"},
+	}
+	for _, c := range cases {
+		data, err := ioutil.ReadFile(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := PackageName(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPackageNameNotFound(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest", testStringAttr("", "versionName", 0, "1.0"))
+	b.EndElement("", "manifest")
+	if _, err := PackageName(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("want error")
+	}
+
+	b = new(testXMLBuilder)
+	b.StartElement("", "application", testStringAttr("", "package", 0, "com.example"))
+	b.EndElement("", "application")
+	if _, err := PackageName(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestPackageNameInvalidAttributes(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.EndElement("", "manifest")
+	data := b.Bytes()
+
+	// find ResXMLTreeAttrExt of the start element, which follows the 16-byte node header.
+	var ext int
+	for offset := 8; offset < len(data); offset += int(binary.LittleEndian.Uint32(data[offset+4:])) {
+		if ChunkType(binary.LittleEndian.Uint16(data[offset:])) == ResXMLStartElementType {
+			ext = offset + 16
+			break
+		}
+	}
+	if ext == 0 {
+		t.Fatal("start element not found")
+	}
+	if _, err := PackageName(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		start, size uint16
+	}{
+		// shorter than ResXMLTreeAttribute, which would read the same bytes again.
+		{"small size", 20, 4},
+		// the sum with the header size overflows uint16.
+		{"overflow", 0xFFF0, 20},
+	}
+	for _, c := range cases {
+		crafted := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint16(crafted[ext+8:], c.start)
+		binary.LittleEndian.PutUint16(crafted[ext+10:], c.size)
+		if got, err := PackageName(bytes.NewReader(crafted)); err == nil {
+			t.Errorf("%s: got %q want error", c.name, got)
+		}
+	}
+}
+
+// BenchmarkPackageName and BenchmarkPackageNameDecode compare PackageName
+// with parsing the file and decoding the manifest, both from the bytes of the file.
+func BenchmarkPackageName(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := PackageName(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackageNameDecode(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		xmlFile, err := NewXMLFile(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var m Manifest
+		if err := xmlFile.Decode(&m, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestXMLFileApplicationFlags(t *testing.T) {
+	newManifest := func(target uint32, attrs ...testXMLAttr) *XMLFile {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "manifest")
+		b.StartElement("", "uses-sdk", testTypedAttr(testAndroidNS, "targetSdkVersion", 0x01010270, TypeIntDec, target))
+		b.EndElement("", "uses-sdk")
+		b.StartElement("", "application", attrs...)
+		b.EndElement("", "application")
+		b.EndElement("", "manifest")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return xmlFile
+	}
+	// @bool/debuggable is true.
+	table := newTestTableFile(ResValue{DataType: TypeIntBoolean, Data: 0xFFFFFFFF})
+
+	cases := []struct {
+		name                                      string
+		xmlFile                                   *XMLFile
+		wantDebuggable, wantBackup, wantCleartext bool
+	}{
+		{
+			name: "explicit true",
+			xmlFile: newManifest(30,
+				testTypedAttr(testAndroidNS, "debuggable", 0x0101000f, TypeReference, 0x7F010000),
+				testTypedAttr(testAndroidNS, "allowBackup", 0x01010280, TypeIntBoolean, 0xFFFFFFFF),
+				testTypedAttr(testAndroidNS, "usesCleartextTraffic", 0x010104ec, TypeIntBoolean, 0xFFFFFFFF),
+			),
+			wantDebuggable: true, wantBackup: true, wantCleartext: true,
+		},
+		{
+			name: "explicit false",
+			xmlFile: newManifest(26,
+				testTypedAttr(testAndroidNS, "debuggable", 0x0101000f, TypeIntBoolean, 0),
+				testTypedAttr(testAndroidNS, "allowBackup", 0x01010280, TypeIntBoolean, 0),
+				testStringAttr(testAndroidNS, "usesCleartextTraffic", 0x010104ec, "false"),
+			),
+			wantDebuggable: false, wantBackup: false, wantCleartext: false,
+		},
+		{
+			name:           "absent with targetSdkVersion 27",
+			xmlFile:        newManifest(27),
+			wantDebuggable: false, wantBackup: true, wantCleartext: true,
+		},
+		{
+			name:           "absent with targetSdkVersion 28",
+			xmlFile:        newManifest(28),
+			wantDebuggable: false, wantBackup: true, wantCleartext: false,
+		},
+	}
+	for _, c := range cases {
+		if got := c.xmlFile.IsDebuggable(table, nil); got != c.wantDebuggable {
+			t.Errorf("%s: debuggable: got %v want %v", c.name, got, c.wantDebuggable)
+		}
+		if got := c.xmlFile.AllowBackup(table, nil); got != c.wantBackup {
+			t.Errorf("%s: allowBackup: got %v want %v", c.name, got, c.wantBackup)
+		}
+		if got := c.xmlFile.UsesCleartextTraffic(table, nil); got != c.wantCleartext {
+			t.Errorf("%s: usesCleartextTraffic: got %v want %v", c.name, got, c.wantCleartext)
+		}
+	}
+
+	// the reference that can't be resolved without the table falls back to the default.
+	xmlFile := newManifest(30, testTypedAttr(testAndroidNS, "debuggable", 0x0101000f, TypeReference, 0x7F010000))
+	if xmlFile.IsDebuggable(nil, nil) {
+		t.Error("debuggable: got true want false")
+	}
+}
+
+func TestXMLFileSplit(t *testing.T) {
+	newManifest := func(attrs ...testXMLAttr) *XMLFile {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "manifest", attrs...)
+		b.EndElement("", "manifest")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return xmlFile
+	}
+
+	cases := []struct {
+		name                  string
+		xmlFile               *XMLFile
+		wantSplit, wantConfig string
+		wantFeature           bool
+	}{
+		{
+			name:    "base",
+			xmlFile: newManifest(testStringAttr("", "package", 0, "com.example")),
+		},
+		{
+			name: "config split",
+			xmlFile: newManifest(
+				testStringAttr("", "package", 0, "com.example"),
+				testStringAttr("", "configForSplit", 0, "camera"),
+				testStringAttr("", "split", 0, "camera.config.xxhdpi"),
+			),
+			wantSplit: "camera.config.xxhdpi", wantConfig: "camera",
+		},
+		{
+			name: "feature split",
+			xmlFile: newManifest(
+				testStringAttr("", "package", 0, "com.example"),
+				testStringAttr("", "split", 0, "camera"),
+				testTypedAttr(testAndroidNS, "isFeatureSplit", 0x0101055b, TypeIntBoolean, 0xFFFFFFFF),
+			),
+			wantSplit: "camera", wantFeature: true,
+		},
+	}
+	for _, c := range cases {
+		if got := c.xmlFile.SplitName(); got != c.wantSplit {
+			t.Errorf("%s: split: got %q want %q", c.name, got, c.wantSplit)
+		}
+		if got := c.xmlFile.ConfigForSplit(); got != c.wantConfig {
+			t.Errorf("%s: configForSplit: got %q want %q", c.name, got, c.wantConfig)
+		}
+		if got := c.xmlFile.IsFeatureSplit(); got != c.wantFeature {
+			t.Errorf("%s: isFeatureSplit: got %v want %v", c.name, got, c.wantFeature)
+		}
+	}
+}
+
+func TestXMLFileOverlay(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example.overlay"))
+	b.StartElement("", "overlay",
+		testTypedAttr(testAndroidNS, "priority", 0x0101001c, TypeReference, 0x7F010000),
+		testStringAttr(testAndroidNS, "targetPackage", 0x01010021, "com.example"),
+		testStringAttr(testAndroidNS, "targetName", 0x0101044d, "ThemeResources"),
+		testTypedAttr(testAndroidNS, "isStatic", 0x0101055a, TypeIntBoolean, 0xFFFFFFFF),
+		testTypedAttr(testAndroidNS, "resourcesMap", 0x01010609, TypeReference, 0x7F0F0000),
+	)
+	b.EndElement("", "overlay")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// @integer/priority is 10.
+	table := newTestTableFile(ResValue{DataType: TypeIntDec, Data: 10})
+
+	got, err := xmlFile.Overlay(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &OverlayInfo{
+		TargetPackage: "com.example",
+		TargetName:    "ThemeResources",
+		Priority:      10,
+		IsStatic:      true,
+		ResourcesMap:  "@0x7F0F0000",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+
+	// not an overlay.
+	xmlFile = loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	if _, err := xmlFile.Overlay(nil, nil); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestXMLFileQueries(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.StartElement("", "queries")
+	b.StartElement("", "package", testStringAttr(testAndroidNS, "name", 0x01010003, "com.example.store"))
+	b.EndElement("", "package")
+	b.StartElement("", "intent")
+	b.StartElement("", "action", testStringAttr(testAndroidNS, "name", 0x01010003, "android.intent.action.SEND"))
+	b.EndElement("", "action")
+	b.StartElement("", "data", testStringAttr(testAndroidNS, "mimeType", 0x01010026, "image/jpeg"))
+	b.EndElement("", "data")
+	b.EndElement("", "intent")
+	b.EndElement("", "queries")
+	b.StartElement("", "queries")
+	b.StartElement("", "provider", testStringAttr(testAndroidNS, "authorities", 0x01010018, "com.example.a;com.example.b"))
+	b.EndElement("", "provider")
+	b.EndElement("", "queries")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := xmlFile.Queries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Queries{
+		Packages: []string{"com.example.store"},
+		Intents: []IntentFilter{
+			{
+				Actions: []string{"android.intent.action.SEND"},
+				Data:    []DataSpec{{MimeType: "image/jpeg"}},
+			},
+		},
+		Authorities: []string{"com.example.a", "com.example.b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+
+	// the manifest without <queries>.
+	got, err = loadXMLTestData(t, "testdata/AndroidManifest.xml").Queries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, &Queries{}) {
+		t.Errorf("got %+v want empty", got)
+	}
+}
@@ -0,0 +1,209 @@
+package androidbinary
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Overlay returns a new TableFile that merges the overlay table o into f,
+// as a runtime resource overlay (RRO) does on the device.
+// f and o are not modified.
+//
+// The entries of o are matched with the entries of f by the package, type and entry names,
+// and the entries without a match in f are ignored; an overlay can replace resources but can't add them.
+// An overlay package whose name f doesn't have, as a runtime resource overlay has, targets the application package of f.
+//
+// If both tables have an entry for the same configuration, the overlay wins.
+// Otherwise the best configuration is chosen from the entries of both tables,
+// so an overlaid default value doesn't hide a more specific value of f, e.g. one for a locale.
+// The strings and the references of the overlaid values are rewritten to refer to the merged table.
+func (f *TableFile) Overlay(o *TableFile) *TableFile {
+	merged := &TableFile{
+		tablePackages: make(map[uint32]*TablePackage, len(f.tablePackages)),
+	}
+	for id, p := range f.tablePackages {
+		cp := *p
+		cp.TableTypes = append([]*TableType(nil), p.TableTypes...)
+		merged.tablePackages[id] = &cp
+	}
+
+	// append the strings of the overlay to the global string pool.
+	var offset uint32
+	pool := new(ResStringPool)
+	if f.stringPool != nil {
+		*pool = *f.stringPool
+		pool.Strings = f.stringPool.strings()
+		pool.lazy = nil
+		offset = uint32(len(pool.Strings))
+	}
+	if o.stringPool != nil {
+		pool.Strings = append(append([]string(nil), pool.Strings...), o.stringPool.strings()...)
+	}
+	pool.Header.StringCount = uint32(len(pool.Strings))
+	merged.stringPool = pool
+
+	m := &overlayMerger{base: f, overlay: o, stringOffset: offset}
+	ids := make([]uint32, 0, len(o.tablePackages))
+	for id := range o.tablePackages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		op := o.tablePackages[id]
+		target := m.target(op.Name())
+		if target == nil {
+			continue
+		}
+		mp := merged.tablePackages[target.Header.ID]
+		var types []*TableType
+		for _, t := range op.TableTypes {
+			if mt := m.mergeType(op, target, t); mt != nil {
+				types = append(types, mt)
+			}
+		}
+		// the overlay types come first, so that they win ties in findBestType.
+		mp.TableTypes = append(types, mp.TableTypes...)
+	}
+	return merged
+}
+
+type overlayMerger struct {
+	base         *TableFile
+	overlay      *TableFile
+	stringOffset uint32
+}
+
+// target returns the package of the base table that the overlay package named name overlays.
+func (m *overlayMerger) target(name string) *TablePackage {
+	for _, p := range m.base.tablePackages {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return m.base.defaultPackage()
+}
+
+// mergeType returns the type chunk of target that has the entries of the overlay type chunk t,
+// or nil if none of them is in target.
+func (m *overlayMerger) mergeType(op, target *TablePackage, t *TableType) *TableType {
+	if op.TypeStrings == nil || op.KeyStrings == nil || target.TypeStrings == nil || target.KeyStrings == nil {
+		return nil
+	}
+	typeRef := ResStringPoolRef(t.Header.ID - 1)
+	if !op.TypeStrings.HasString(typeRef) {
+		return nil
+	}
+	typ := op.TypeStrings.GetString(typeRef)
+	typeRef, ok := target.TypeStrings.indexOf(typ)
+	if !ok {
+		return nil
+	}
+	typeID := int(typeRef) + 1
+
+	// the entry indexes and the key strings of the base table by the entry names.
+	count := 0
+	keys := make(map[string]int)
+	keyRefs := make(map[string]ResStringPoolRef)
+	for _, bt := range target.TableTypes {
+		if int(bt.Header.ID) != typeID {
+			continue
+		}
+		if len(bt.Entries) > count {
+			count = len(bt.Entries)
+		}
+		for i, e := range bt.Entries {
+			if e.Key == nil || !target.KeyStrings.HasString(e.Key.Key) {
+				continue
+			}
+			name := target.KeyStrings.GetString(e.Key.Key)
+			keys[name] = i
+			keyRefs[name] = e.Key.Key
+		}
+	}
+
+	entries := make([]TableEntry, count)
+	found := false
+	for _, e := range t.Entries {
+		if e.Key == nil || e.Value == nil || !op.KeyStrings.HasString(e.Key.Key) {
+			continue
+		}
+		name := op.KeyStrings.GetString(e.Key.Key)
+		i, ok := keys[name]
+		if !ok {
+			continue
+		}
+		key := *e.Key
+		key.Key = keyRefs[name]
+		value := m.value(*e.Value)
+		entries[i] = TableEntry{
+			Key:   &key,
+			Value: &value,
+			Flags: e.Flags,
+			data:  m.complexData(e),
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+
+	header := *t.Header
+	header.ID = uint8(typeID)
+	header.EntryCount = uint32(count)
+	return &TableType{
+		Header:  &header,
+		Entries: entries,
+	}
+}
+
+// value rewrites v to refer to the merged table.
+func (m *overlayMerger) value(v ResValue) ResValue {
+	switch v.DataType {
+	case TypeString:
+		v.Data += m.stringOffset
+	case TypeReference, TypeAttribute:
+		v.Data = uint32(m.id(ResID(v.Data)))
+	}
+	return v
+}
+
+// id returns the id in the base table of the resource id of the overlay table.
+// The ids out of the overlay table, e.g. the ones of the android framework, are returned as is.
+func (m *overlayMerger) id(id ResID) ResID {
+	pkg, typ, entry, ok := m.overlay.resourceName(id)
+	if !ok {
+		return id
+	}
+	target := m.target(pkg)
+	if target == nil {
+		return id
+	}
+	if baseID, ok := m.base.findResID(target.Name(), typ, entry); ok {
+		return baseID
+	}
+	return id
+}
+
+// complexData returns the raw data of the complex entry e rewritten to refer to the merged table.
+func (m *overlayMerger) complexData(e TableEntry) []byte {
+	if e.data == nil {
+		return nil
+	}
+	data := append([]byte(nil), e.data...)
+	parent, maps, err := e.bag()
+	if err != nil {
+		return data
+	}
+	if parent != 0 {
+		binary.LittleEndian.PutUint32(data[0:], uint32(m.id(parent)))
+	}
+	start := int(e.Key.Size) - binary.Size(ResTableEntry{})
+	mapSize := binary.Size(ResTableMap{})
+	for i, mp := range maps {
+		b := data[start+i*mapSize:]
+		v := m.value(mp.Value)
+		binary.LittleEndian.PutUint32(b[0:], uint32(m.id(mp.Name)))
+		binary.LittleEndian.PutUint32(b[8:], v.Data)
+	}
+	return data
+}
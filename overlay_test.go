@@ -0,0 +1,132 @@
+package androidbinary
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+// newTestOverlayTableFile returns an overlay for testdata/MyApplication/resources.arsc.
+func newTestOverlayTableFile() *TableFile {
+	var name [128]uint16
+	copy(name[:], utf16.Encode([]rune("com.example.overlay")))
+	key := func(ref ResStringPoolRef) *ResTableEntry {
+		return &ResTableEntry{Size: 8, Key: ref}
+	}
+	return &TableFile{
+		stringPool: &ResStringPool{Strings: []string{"Overlaid App"}},
+		tablePackages: map[uint32]*TablePackage{
+			0x7F: {
+				Header:      ResTablePackage{ID: 0x7F, Name: name},
+				TypeStrings: &ResStringPool{Strings: []string{"color", "string"}},
+				KeyStrings:  &ResStringPool{Strings: []string{"colorPrimary", "colorAccent", "not_in_base", "app_name"}},
+				TableTypes: []*TableType{
+					{
+						Header: &ResTableType{ID: 0x01, EntryCount: 3},
+						Entries: []TableEntry{
+							{Key: key(0), Value: &ResValue{Size: 8, DataType: TypeIntColorRGB8, Data: 0xffff0000}},
+							{Key: key(1), Value: &ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010000}},
+							{Key: key(2), Value: &ResValue{Size: 8, DataType: TypeIntColorRGB8, Data: 0xff00ff00}},
+						},
+					},
+					{
+						Header: &ResTableType{ID: 0x02, EntryCount: 1},
+						Entries: []TableEntry{
+							{Key: key(3), Value: &ResValue{Size: 8, DataType: TypeString, Data: 0}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	base := loadMyApplicationTestData(t)
+	merged := base.Overlay(newTestOverlayTableFile())
+	config := &ResTableConfig{}
+
+	// the overlay replaces the color.
+	v, err := merged.GetResourceByName("@color/colorPrimary", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.DataType != TypeIntColorRGB8 || v.Data != 0xffff0000 {
+		t.Errorf("got %#v want #ffff0000", v)
+	}
+
+	// the references are rewritten to the ids of the base table.
+	v, err = merged.GetResourceByName("@color/colorAccent", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.DataType != TypeReference || ResID(v.Data) != 0x7F040027 {
+		t.Errorf("got %#v want @0x7F040027", v)
+	}
+	v, err = merged.ResolveReference(0x7F040026, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Data != 0xffff0000 {
+		t.Errorf("got %#v want #ffff0000", v)
+	}
+
+	// the strings are resolved in the strings of the overlay.
+	s, err := merged.GetResource(0x7F0B0027, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "Overlaid App" {
+		t.Errorf("got %v want Overlaid App", s)
+	}
+
+	// the entries that the base table doesn't have are ignored.
+	if _, err := merged.GetResourceByName("@color/not_in_base", config); err == nil {
+		t.Error("want error")
+	}
+
+	// the base table is not modified.
+	v, err = base.GetResourceByName("@color/colorPrimary", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Data != 0xff008577 {
+		t.Errorf("got %#v want #ff008577", v)
+	}
+	s, err = base.GetResource(0x7F0B0027, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "My Application" {
+		t.Errorf("got %v want My Application", s)
+	}
+}
+
+func TestOverlayComplex(t *testing.T) {
+	// overlaying the table on itself doesn't change the styles.
+	base := loadMyApplicationTestData(t)
+	merged := base.Overlay(loadMyApplicationTestData(t))
+	want, err := base.GetBag(0x7F0C0005, &ResTableConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := merged.GetBag(0x7F0C0005, &ResTableConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d items want %d", len(got), len(want))
+	}
+	for name, w := range want {
+		g := got[name]
+		if w.DataType == TypeString {
+			// the strings are moved in the merged string pool.
+			if merged.GetString(ResStringPoolRef(g.Data)) != base.GetString(ResStringPoolRef(w.Data)) {
+				t.Errorf("%s: got %q want %q", name, merged.GetString(ResStringPoolRef(g.Data)), base.GetString(ResStringPoolRef(w.Data)))
+			}
+			continue
+		}
+		if g != w {
+			t.Errorf("%s: got %#v want %#v", name, g, w)
+		}
+	}
+}
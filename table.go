@@ -1,12 +1,15 @@
 package androidbinary
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
-	"unsafe"
+	"unicode/utf16"
 )
 
 // ResID is ID for resources.
@@ -16,6 +19,17 @@ type ResID uint32
 type TableFile struct {
 	stringPool    *ResStringPool
 	tablePackages map[uint32]*TablePackage
+	opts          TableOptions
+}
+
+// TableOptions are options for parsing resource tables.
+type TableOptions struct {
+	// LazyStrings decodes the strings of the global string pool on first use
+	// instead of decoding all of them in NewTableFileOptions.
+	// It saves the memory for the huge pools of which only a few strings are used,
+	// at the cost of decoding a string when it is used for the first time.
+	// The io.ReaderAt passed to NewTableFileOptions must remain readable while the table is used.
+	LazyStrings bool
 }
 
 // ResTableHeader is a header of TableFile.
@@ -41,6 +55,19 @@ type TablePackage struct {
 	TypeStrings *ResStringPool
 	KeyStrings  *ResStringPool
 	TableTypes  []*TableType
+	TypeSpecs   []*TableTypeSpec
+
+	// Overlayables are the sets of the resources that runtime resource overlays may override.
+	Overlayables []*TableOverlayable
+
+	// StagedAliases map the ids of the resources staged for a future API to their finalized ids.
+	StagedAliases []TableStagedAlias
+
+	// the raw header, which may be longer than ResTablePackage.
+	rawHeader []byte
+
+	// the chunks that TablePackage doesn't parse, e.g. the shared library chunk, as they are in the file.
+	unknownChunks [][]byte
 }
 
 // ResTableType is a type of a table.
@@ -79,6 +106,34 @@ const (
 	LayoutDirRTL   ScreenLayout = 0x80
 )
 
+// ScreenLayout2 describes the extended screen layout.
+type ScreenLayout2 uint8
+
+// ScreenLayout2 bits
+const (
+	MaskScreenRound ScreenLayout2 = 0x03
+	ScreenRoundAny  ScreenLayout2 = 0x00
+	ScreenRoundNo   ScreenLayout2 = 0x01
+	ScreenRoundYes  ScreenLayout2 = 0x02
+)
+
+// ColorMode describes the color capabilities of the screen.
+type ColorMode uint8
+
+// ColorMode bits
+const (
+	MaskWideColorGamut ColorMode = 0x03
+	WideColorGamutAny  ColorMode = 0x00
+	WideColorGamutNo   ColorMode = 0x01
+	WideColorGamutYes  ColorMode = 0x02
+
+	MaskHDR  ColorMode = 0x0c
+	ShiftHDR           = 2
+	HDRAny   ColorMode = 0x00
+	HDRNo    ColorMode = 0x04
+	HDRYes   ColorMode = 0x08
+)
+
 // UIMode describes UI mode.
 type UIMode uint8
 
@@ -97,6 +152,20 @@ const (
 	UIModeNightYes   UIMode = 0x20
 )
 
+// Density values of ResTableConfig.
+const (
+	DensityDefault uint16 = 0
+	DensityLow     uint16 = 120
+	DensityMedium  uint16 = 160
+	DensityTV      uint16 = 213
+	DensityHigh    uint16 = 240
+	DensityXHigh   uint16 = 320
+	DensityXXHigh  uint16 = 480
+	DensityXXXHigh uint16 = 640
+	DensityAny     uint16 = 0xfffe
+	DensityNone    uint16 = 0xffff
+)
+
 // InputFlags are input flags.
 type InputFlags uint8
 
@@ -152,12 +221,24 @@ type ResTableConfig struct {
 	// screen size dp
 	ScreenWidthDp  uint16
 	ScreenHeightDp uint16
+
+	// extended locale
+	LocaleScript  [4]uint8
+	LocaleVariant [8]uint8
+
+	// extended screen config
+	ScreenLayout2    ScreenLayout2
+	ColorMode        ColorMode
+	ScreenConfigPad2 uint16
 }
 
 // TableType is a collection of resource entries for a particular resource data type.
 type TableType struct {
 	Header  *ResTableType
 	Entries []TableEntry
+
+	// the raw header, which may be longer or shorter than ResTableType.
+	rawHeader []byte
 }
 
 // ResTableEntry is the beginning of information about an entry in the resource table.
@@ -167,13 +248,68 @@ type ResTableEntry struct {
 	Key   ResStringPoolRef
 }
 
+// Flags of ResTableEntry.
+const (
+	// EntryFlagComplex is set if the entry is a bag of name/value pairs, e.g. styles and arrays.
+	EntryFlagComplex uint16 = 0x0001
+	// EntryFlagPublic is set if the entry is declared as public.
+	EntryFlagPublic uint16 = 0x0002
+	// EntryFlagWeak is set if the entry may be overridden by other entries of the same name.
+	EntryFlagWeak uint16 = 0x0004
+)
+
+// NoEntry is the offset of the entries that a type chunk doesn't have, as ResTable_type::NO_ENTRY.
+// The entries are read as empty TableEntry values, and the lookups fall through to the other configurations.
+const NoEntry uint32 = 0xFFFFFFFF
+
 // TableEntry is a entry in a resource table.
 type TableEntry struct {
 	Key   *ResTableEntry
 	Value *ResValue
 	Flags uint32
+
+	// the raw data of the complex entry following Key.
+	data []byte
+}
+
+// TableTypeSpec is a specification of the resources defined by a particular type.
+type TableTypeSpec struct {
+	Header *ResTableTypeSpec
+
+	// Flags are the flags of each entry: the configuration axes that the entry varies by
+	// and SpecFlagPublic.
+	Flags []uint32
 }
 
+// Flags of the entries in TableTypeSpec.
+const (
+	// SpecFlagPublic is set if the entry is declared as public.
+	SpecFlagPublic uint32 = 0x40000000
+	// SpecFlagStagedAPI is set if the entry is a public entry staged for a future API.
+	SpecFlagStagedAPI uint32 = 0x20000000
+)
+
+// The configuration axes in the flags of TableTypeSpec.
+const (
+	ConfigMCC                uint32 = 0x0001
+	ConfigMNC                uint32 = 0x0002
+	ConfigLocale             uint32 = 0x0004
+	ConfigTouchscreen        uint32 = 0x0008
+	ConfigKeyboard           uint32 = 0x0010
+	ConfigKeyboardHidden     uint32 = 0x0020
+	ConfigNavigation         uint32 = 0x0040
+	ConfigOrientation        uint32 = 0x0080
+	ConfigDensity            uint32 = 0x0100
+	ConfigScreenSize         uint32 = 0x0200
+	ConfigVersion            uint32 = 0x0400
+	ConfigScreenLayout       uint32 = 0x0800
+	ConfigUIMode             uint32 = 0x1000
+	ConfigSmallestScreenSize uint32 = 0x2000
+	ConfigLayoutDir          uint32 = 0x4000
+	ConfigScreenRound        uint32 = 0x8000
+	ConfigColorMode          uint32 = 0x10000
+)
+
 // ResTableTypeSpec is specification of the resources defined by a particular type.
 type ResTableTypeSpec struct {
 	Header     ResChunkHeader
@@ -183,6 +319,70 @@ type ResTableTypeSpec struct {
 	EntryCount uint32
 }
 
+// TableOverlayable is a set of the resources that runtime resource overlays may override,
+// declared by <overlayable> in res/values/overlayable.xml.
+type TableOverlayable struct {
+	Header   *ResTableOverlayable
+	Policies []*TableOverlayablePolicy
+}
+
+// ResTableOverlayable is the header of an overlayable chunk.
+type ResTableOverlayable struct {
+	Header ResChunkHeader
+	Name   [256]uint16
+	Actor  [256]uint16
+}
+
+// Name returns the name of the overlayable.
+func (o *TableOverlayable) Name() string {
+	return decodeUTF16Name(o.Header.Name[:])
+}
+
+// Actor returns the actor of the overlayable, e.g. "overlay://theme".
+func (o *TableOverlayable) Actor() string {
+	return decodeUTF16Name(o.Header.Actor[:])
+}
+
+// TableOverlayablePolicy is a list of the resources that the overlays fulfilling the policy may override.
+type TableOverlayablePolicy struct {
+	Header *ResTableOverlayablePolicy
+	IDs    []ResID
+}
+
+// ResTableOverlayablePolicy is the header of an overlayable policy chunk.
+type ResTableOverlayablePolicy struct {
+	Header     ResChunkHeader
+	Flags      uint32
+	EntryCount uint32
+}
+
+// Flags of ResTableOverlayablePolicy, which are the partitions and the signatures
+// that the overlays must have.
+const (
+	PolicyNone             uint32 = 0x00000000
+	PolicyPublic           uint32 = 0x00000001
+	PolicySystemPartition  uint32 = 0x00000002
+	PolicyVendorPartition  uint32 = 0x00000004
+	PolicyProductPartition uint32 = 0x00000008
+	PolicySignature        uint32 = 0x00000010
+	PolicyODMPartition     uint32 = 0x00000020
+	PolicyOEMPartition     uint32 = 0x00000040
+	PolicyActorSignature   uint32 = 0x00000080
+	PolicyConfigSignature  uint32 = 0x00000100
+)
+
+// TableStagedAlias maps the id of a resource staged for a future API to its finalized id.
+type TableStagedAlias struct {
+	StagedID    ResID
+	FinalizedID ResID
+}
+
+// ResTableStagedAlias is the header of a staged alias chunk.
+type ResTableStagedAlias struct {
+	Header ResChunkHeader
+	Count  uint32
+}
+
 // IsResID returns whether s is ResId.
 func IsResID(s string) bool {
 	return strings.HasPrefix(s, "@0x")
@@ -221,11 +421,18 @@ func (id ResID) Entry() int {
 
 // NewTableFile returns new TableFile.
 func NewTableFile(r io.ReaderAt) (*TableFile, error) {
-	f := new(TableFile)
+	return NewTableFileOptions(r, TableOptions{})
+}
+
+// NewTableFileOptions returns new TableFile parsed with opts.
+func NewTableFileOptions(r io.ReaderAt, opts TableOptions) (*TableFile, error) {
+	f := &TableFile{opts: opts}
 	sr := io.NewSectionReader(r, 0, 1<<63-1)
 
 	header := new(ResTableHeader)
-	binary.Read(sr, binary.LittleEndian, header)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
 	f.tablePackages = make(map[uint32]*TablePackage)
 
 	offset := int64(header.Header.HeaderSize)
@@ -239,6 +446,64 @@ func NewTableFile(r io.ReaderAt) (*TableFile, error) {
 	return f, nil
 }
 
+// Package returns the package whose id is id.
+// It returns nil if the table has no such package.
+func (f *TableFile) Package(id uint32) *TablePackage {
+	return f.findPackage(id)
+}
+
+// specFlags returns the flags of id in the type spec chunk.
+func (f *TableFile) specFlags(id ResID) (uint32, bool) {
+	p := f.findPackage(id.Package())
+	if p == nil {
+		return 0, false
+	}
+	for _, spec := range p.TypeSpecs {
+		if int(spec.Header.ID) == id.Type() && id.Entry() < len(spec.Flags) {
+			return spec.Flags[id.Entry()], true
+		}
+	}
+	return 0, false
+}
+
+// IsPublic reports whether the resource id is declared as public.
+// Tools that surface only the public API of a library can use it to filter the resources.
+func (f *TableFile) IsPublic(id ResID) bool {
+	flags, _ := f.specFlags(id)
+	return flags&SpecFlagPublic != 0
+}
+
+// ConfigAxes returns the configuration axes that the values of id vary by,
+// e.g. ConfigLocale|ConfigDensity. It returns 0 if id has only one value or the table has no id.
+func (f *TableFile) ConfigAxes(id ResID) uint32 {
+	flags, _ := f.specFlags(id)
+	return flags &^ (SpecFlagPublic | SpecFlagStagedAPI)
+}
+
+// PackageInfo describes a package of the resource table.
+type PackageInfo struct {
+	ID   uint32
+	Name string
+}
+
+// Packages returns the packages in ascending order of id.
+// Besides the application package(0x7F), the table of a split APK or a shared library
+// may have packages with other ids. Resources are looked up in the package
+// whose id is the highest byte of the resource id.
+func (f *TableFile) Packages() []PackageInfo {
+	if f == nil {
+		return nil
+	}
+	infos := make([]PackageInfo, 0, len(f.tablePackages))
+	for id, p := range f.tablePackages {
+		infos = append(infos, PackageInfo{ID: id, Name: p.Name()})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
 func (f *TableFile) findPackage(id uint32) *TablePackage {
 	if f == nil {
 		return nil
@@ -247,6 +512,15 @@ func (f *TableFile) findPackage(id uint32) *TablePackage {
 }
 
 func (p *TablePackage) findEntry(typeIndex, entryIndex int, config *ResTableConfig) TableEntry {
+	best := p.findBestType(typeIndex, entryIndex, config)
+	if best == nil {
+		return TableEntry{}
+	}
+	return best.Entries[entryIndex]
+}
+
+// findBestType returns the type chunk that has the best entry for config.
+func (p *TablePackage) findBestType(typeIndex, entryIndex int, config *ResTableConfig) *TableType {
 	var best *TableType
 	for _, t := range p.TableTypes {
 		switch {
@@ -262,27 +536,532 @@ func (p *TablePackage) findEntry(typeIndex, entryIndex int, config *ResTableConf
 			best = t
 		}
 	}
-	if best == nil || entryIndex >= len(best.Entries) {
-		return TableEntry{}
+	return best
+}
+
+// Name returns the name of the package.
+func (p *TablePackage) Name() string {
+	return decodeUTF16Name(p.Header.Name[:])
+}
+
+// decodeUTF16Name decodes a NUL-terminated name in a fixed-size array.
+func decodeUTF16Name(name []uint16) string {
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
 	}
-	return best.Entries[entryIndex]
+	return string(utf16.Decode(name))
 }
 
-// GetResource returns a resource referenced by id.
-func (f *TableFile) GetResource(id ResID, config *ResTableConfig) (interface{}, error) {
+// resourceName returns the package name, the type name and the entry name of id.
+func (f *TableFile) resourceName(id ResID) (pkg, typ, entry string, ok bool) {
 	p := f.findPackage(id.Package())
 	if p == nil {
-		return nil, fmt.Errorf("androidbinary: package 0x%02X not found", id.Package())
+		return "", "", "", false
+	}
+	typeIndex := ResStringPoolRef(id.Type() - 1)
+	if !p.TypeStrings.HasString(typeIndex) {
+		return "", "", "", false
+	}
+	for _, t := range p.TableTypes {
+		if int(t.Header.ID) != id.Type() || id.Entry() >= len(t.Entries) {
+			continue
+		}
+		key := t.Entries[id.Entry()].Key
+		if key == nil || !p.KeyStrings.HasString(key.Key) {
+			continue
+		}
+		return p.Name(), p.TypeStrings.GetString(typeIndex), p.KeyStrings.GetString(key.Key), true
+	}
+	return "", "", "", false
+}
+
+// TypeName returns the name of the type of the resource id, e.g. "string" for 0x7F0B0027 (@string/app_name).
+// It is looked up by the type id in the type strings of the package, so the entry of id needn't exist.
+// It returns false if the table doesn't have the package or the type.
+func (f *TableFile) TypeName(id ResID) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	p := f.findPackage(id.Package())
+	if p == nil {
+		return "", false
+	}
+	typeIndex := ResStringPoolRef(id.Type() - 1)
+	if !p.TypeStrings.HasString(typeIndex) {
+		return "", false
+	}
+	return p.TypeStrings.GetString(typeIndex), true
+}
+
+// ResourceName returns the name of the resource id in the "package:type/entry" format,
+// e.g. "com.example:string/app_name".
+// It is the reverse of GetResourceByName, and it returns false if the table doesn't have id.
+func (f *TableFile) ResourceName(id ResID) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	pkg, typ, entry, ok := f.resourceName(id)
+	if !ok {
+		return "", false
+	}
+	return pkg + ":" + typ + "/" + entry, true
+}
+
+// referenceName returns the name of the resource id in the "string/app_name" format.
+// The package is omitted if it is the default package, as GetResourceByName resolves it.
+func (f *TableFile) referenceName(id ResID) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	pkg, typ, entry, ok := f.resourceName(id)
+	if !ok {
+		return "", false
+	}
+	if p := f.defaultPackage(); p != nil && p.Name() == pkg {
+		return typ + "/" + entry, true
+	}
+	return pkg + ":" + typ + "/" + entry, true
+}
+
+// attributeName returns the name of the attribute id in the "attr/colorPrimary" format.
+// The attributes of the android framework are prefixed with "android:".
+func (f *TableFile) attributeName(id ResID) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	if id.Package() == 0x01 {
+		if name := getAttributteName(ResStringPoolRef(id)); name != "" {
+			return "android:attr/" + name, true
+		}
+		return "", false
+	}
+	_, typ, entry, ok := f.resourceName(id)
+	if !ok || typ != "attr" {
+		return "", false
+	}
+	return typ + "/" + entry, true
+}
+
+// defaultPackage returns the package that the names without package refer to.
+// It is the application package(0x7F) if the table has it, otherwise the package with the smallest id.
+func (f *TableFile) defaultPackage() *TablePackage {
+	if f == nil {
+		return nil
+	}
+	if p, ok := f.tablePackages[0x7F]; ok {
+		return p
+	}
+	var ret *TablePackage
+	for id, p := range f.tablePackages {
+		if ret == nil || id < ret.Header.ID {
+			ret = p
+		}
+	}
+	return ret
+}
+
+// findResID returns the resource id of the entry named typ/entry in the package named pkg.
+// If pkg is empty, the default package is used.
+func (f *TableFile) findResID(pkg, typ, entry string) (ResID, bool) {
+	var p *TablePackage
+	if pkg == "" {
+		p = f.defaultPackage()
+	} else if f != nil {
+		for _, tp := range f.tablePackages {
+			if tp.Name() == pkg {
+				p = tp
+				break
+			}
+		}
+	}
+	if p == nil || p.TypeStrings == nil || p.KeyStrings == nil {
+		return 0, false
+	}
+
+	typeRef, ok := p.TypeStrings.indexOf(typ)
+	if !ok {
+		return 0, false
+	}
+	typeID := int(typeRef) + 1
+
+	// the keys are compared by the strings, as the key pool may have duplicated strings.
+	for _, t := range p.TableTypes {
+		if int(t.Header.ID) != typeID {
+			continue
+		}
+		for i, e := range t.Entries {
+			if e.Key != nil && p.HasKeyString(e.Key.Key) && p.GetKeyString(e.Key.Key) == entry {
+				return ResID(p.Header.ID<<24 | uint32(typeID)<<16 | uint32(i)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseResourceName parses the resource name in the "@package:type/entry" format.
+// The leading "@" and the package are optional.
+func parseResourceName(name string) (pkg, typ, entry string, err error) {
+	s := strings.TrimPrefix(name, "@")
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		pkg = s[:i]
+		s = s[i+1:]
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return "", "", "", fmt.Errorf("androidbinary: invalid resource name: %q", name)
+	}
+	typ, entry = s[:i], s[i+1:]
+	if typ == "" || entry == "" {
+		return "", "", "", fmt.Errorf("androidbinary: invalid resource name: %q", name)
+	}
+	return pkg, typ, entry, nil
+}
+
+// GetResourceByName returns the value of the resource named name for config.
+// name is in the "@package:type/entry" format, e.g. "@string/app_name" or "@com.example:string/app_name".
+// If the package is omitted, the application's own package is used.
+func (f *TableFile) GetResourceByName(name string, config *ResTableConfig) (ResValue, error) {
+	pkg, typ, entry, err := parseResourceName(name)
+	if err != nil {
+		return ResValue{}, err
+	}
+	id, ok := f.findResID(pkg, typ, entry)
+	if !ok {
+		return ResValue{}, fmt.Errorf("androidbinary: resource %q not found", name)
+	}
+	v, err := f.getResValue(id, config)
+	if err != nil {
+		return ResValue{}, err
+	}
+	return *v, nil
+}
+
+func (f *TableFile) getResValue(id ResID, config *ResTableConfig) (*ResValue, error) {
+	e, err := f.getEntry(id, config)
+	if err != nil {
+		return nil, err
+	}
+	return e.Value, nil
+}
+
+// getEntry returns the best entry of id for config.
+func (f *TableFile) getEntry(id ResID, config *ResTableConfig) (TableEntry, error) {
+	p := f.findPackage(id.Package())
+	if p == nil {
+		return TableEntry{}, fmt.Errorf("androidbinary: package 0x%02X not found", id.Package())
 	}
 	e := p.findEntry(id.Type(), id.Entry(), config)
-	v := e.Value
-	if v == nil {
-		return nil, fmt.Errorf("androidbinary: entry 0x%04X not found", id.Entry())
+	if e.Value == nil {
+		return TableEntry{}, fmt.Errorf("androidbinary: entry 0x%04X not found", id.Entry())
+	}
+	return e, nil
+}
+
+// FindBestConfig returns the configuration and the value of the best entry of id for the device configuration want.
+// It selects the entry in the same way as Android does:
+// the configurations that don't match want are dropped, and then the best one is chosen by the precedence of
+// the qualifiers (MCC/MNC, locale, layout direction, screen size, ..., density, ..., SDK version).
+// If want is nil, the most specific configuration is selected.
+func (f *TableFile) FindBestConfig(id ResID, want *ResTableConfig) (*ResTableConfig, ResValue, error) {
+	p := f.findPackage(id.Package())
+	if p == nil {
+		return nil, ResValue{}, fmt.Errorf("androidbinary: package 0x%02X not found", id.Package())
+	}
+	t := p.findBestType(id.Type(), id.Entry(), want)
+	if t == nil {
+		return nil, ResValue{}, fmt.Errorf("androidbinary: entry 0x%04X not found", id.Entry())
+	}
+	config := t.Header.Config
+	return &config, *t.Entries[id.Entry()].Value, nil
+}
+
+// DefaultConfig returns the default configuration, i.e. the configuration without qualifiers
+// that the entries in the res/values, res/drawable, ... directories have.
+// It is the configuration for the unqualified lookups: it selects the default entries,
+// but also the entries qualified only by the density or the SDK version, as they match every device.
+// It is different from nil, which selects the most specific entry, e.g. the xxxhdpi icon or a translated string.
+//
+// The lookups with DefaultConfig and with a zero-value ResTableConfig are the same.
+// The only difference is that the Size of DefaultConfig is the one of the configurations in the table,
+// so it can be compared with the result of ConfigsForID and FindBestConfig.
+func (f *TableFile) DefaultConfig() *ResTableConfig {
+	config := new(ResTableConfig)
+	p := f.defaultPackage()
+	if p == nil {
+		return config
+	}
+	for _, t := range p.TableTypes {
+		c := t.Header.Config
+		c.Size = 0
+		if c == (ResTableConfig{}) {
+			config.Size = t.Header.Config.Size
+			break
+		}
+	}
+	return config
+}
+
+// ConfigsForID returns all configurations in which the resource id is defined, in table order.
+func (f *TableFile) ConfigsForID(id ResID) []*ResTableConfig {
+	p := f.findPackage(id.Package())
+	if p == nil {
+		return nil
+	}
+	var configs []*ResTableConfig
+	for _, t := range p.TableTypes {
+		if int(t.Header.ID) != id.Type() || id.Entry() >= len(t.Entries) {
+			continue
+		}
+		if t.Entries[id.Entry()].Value == nil {
+			continue
+		}
+		config := t.Header.Config
+		configs = append(configs, &config)
+	}
+	return configs
+}
+
+// IDForPath returns the id of the file-based resource stored at path in the APK, e.g. "res/layout/main.xml".
+// The path may be the one of any configuration, e.g. "res/layout-land/main.xml" returns the id of @layout/main.
+// It returns false if no entry refers to path.
+func (f *TableFile) IDForPath(path string) (ResID, bool) {
+	if f == nil {
+		return 0, false
+	}
+	ref, ok := f.stringPool.indexOf(path)
+	if !ok {
+		return 0, false
+	}
+	for _, info := range f.Packages() {
+		p := f.tablePackages[info.ID]
+		for _, t := range p.TableTypes {
+			for i, e := range t.Entries {
+				if e.Value == nil || e.Value.DataType != TypeString || ResStringPoolRef(e.Value.Data) != ref {
+					continue
+				}
+				return ResID(info.ID<<24 | uint32(t.Header.ID)<<16 | uint32(i)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// PathForID returns the path in the APK of the file-based resource id for config, e.g. "res/layout/main.xml".
+// It returns false if id is not found or it is not a file-based resource, i.e. its value is not a path under "res/".
+func (f *TableFile) PathForID(id ResID, config *ResTableConfig) (string, bool) {
+	e, err := f.getEntry(id, config)
+	if err != nil {
+		return "", false
+	}
+	return f.filePath(e.Value)
+}
+
+// Files returns the paths of all the file-based resources for config and their ids,
+// e.g. "res/layout/main.xml" for @layout/main. It is an index of the files under res/ in the APK.
+// The resources whose values are not paths under "res/", e.g. strings and colors, are skipped.
+// The other configurations of the files are not included; see IDForPath.
+func (f *TableFile) Files(config *ResTableConfig) map[string]ResID {
+	files := make(map[string]ResID)
+	f.Entries(config)(func(id ResID, name string, value ResValue) bool {
+		if path, ok := f.filePath(&value); ok {
+			files[path] = id
+		}
+		return true
+	})
+	return files
+}
+
+// filePath returns the path that v refers to if v is the value of a file-based resource.
+func (f *TableFile) filePath(v *ResValue) (string, bool) {
+	if v == nil || v.DataType != TypeString || !f.HasString(ResStringPoolRef(v.Data)) {
+		return "", false
+	}
+	path := f.GetString(ResStringPoolRef(v.Data))
+	if !strings.HasPrefix(path, "res/") {
+		return "", false
+	}
+	return path, true
+}
+
+// Entries returns an iterator over all entries in the table for config.
+// The entries are visited in the order of the resource ids,
+// and the entries that are absent for config are skipped.
+// The name is in the "package:type/entry" format, or empty if the table has no name for the entry.
+// Iteration stops when yield returns false.
+//
+// With Go 1.23 or later, the iterator can be used with a range statement:
+//
+//	for id, name, value := range table.Entries(config) { ... }
+func (f *TableFile) Entries(config *ResTableConfig) func(yield func(id ResID, name string, value ResValue) bool) {
+	return func(yield func(id ResID, name string, value ResValue) bool) {
+		if f == nil {
+			return
+		}
+		pkgIDs := make([]uint32, 0, len(f.tablePackages))
+		for id := range f.tablePackages {
+			pkgIDs = append(pkgIDs, id)
+		}
+		sort.Slice(pkgIDs, func(i, j int) bool { return pkgIDs[i] < pkgIDs[j] })
+
+		for _, pkgID := range pkgIDs {
+			p := f.tablePackages[pkgID]
+
+			// the number of entries of each type
+			counts := make(map[int]int)
+			for _, t := range p.TableTypes {
+				if n := len(t.Entries); n > counts[int(t.Header.ID)] {
+					counts[int(t.Header.ID)] = n
+				}
+			}
+			typeIDs := make([]int, 0, len(counts))
+			for id := range counts {
+				typeIDs = append(typeIDs, id)
+			}
+			sort.Ints(typeIDs)
+
+			for _, typeID := range typeIDs {
+				for entryID := 0; entryID < counts[typeID]; entryID++ {
+					best := p.findBestType(typeID, entryID, config)
+					if best == nil {
+						continue
+					}
+					id := ResID(pkgID<<24 | uint32(typeID)<<16 | uint32(entryID))
+					var name string
+					if pkg, typ, entry, ok := f.resourceName(id); ok {
+						name = pkg + ":" + typ + "/" + entry
+					}
+					if !yield(id, name, *best.Entries[entryID].Value) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// maxReferenceDepth is the maximum length of the reference chains that ResolveReference follows.
+const maxReferenceDepth = 32
+
+// ResolveReference returns the value of the resource referenced by id for config.
+// If the value is a reference to another resource, it follows the references until it reaches a concrete value.
+// It returns an error if the references are cyclic or the chain is too long.
+func (f *TableFile) ResolveReference(id ResID, config *ResTableConfig) (ResValue, error) {
+	_, e, err := f.resolveReference(id, config)
+	if err != nil {
+		return ResValue{}, err
+	}
+	return *e.Value, nil
+}
+
+// resolveReference returns the id and the entry at the end of the reference chain from id.
+func (f *TableFile) resolveReference(id ResID, config *ResTableConfig) (ResID, TableEntry, error) {
+	visited := make(map[ResID]bool)
+	for depth := 0; depth < maxReferenceDepth; depth++ {
+		if visited[id] {
+			return 0, TableEntry{}, fmt.Errorf("androidbinary: cyclic reference: %s", id)
+		}
+		visited[id] = true
+
+		e, err := f.getEntry(id, config)
+		if err != nil {
+			return 0, TableEntry{}, err
+		}
+		if e.Value.DataType != TypeReference {
+			return id, e, nil
+		}
+		id = ResID(e.Value.Data)
+	}
+	return 0, TableEntry{}, fmt.Errorf("androidbinary: too deep reference: %s", id)
+}
+
+// ResTableMap is a name/value pair in a complex entry.
+type ResTableMap struct {
+	Name  ResID
+	Value ResValue
+}
+
+// bag returns the parent and the name/value pairs of the complex entry.
+func (e *TableEntry) bag() (ResID, []ResTableMap, error) {
+	if e.Key == nil || e.Key.Flags&EntryFlagComplex == 0 {
+		return 0, nil, fmt.Errorf("androidbinary: not a complex entry")
+	}
+	// data is the rest of ResTable_map_entry: the parent reference and the count, followed by the maps.
+	keySize := binary.Size(ResTableEntry{})
+	start := int(e.Key.Size) - keySize
+	if len(e.data) < 8 || start < 8 || start > len(e.data) {
+		return 0, nil, fmt.Errorf("androidbinary: invalid entry size: %d", e.Key.Size)
+	}
+	parent := ResID(binary.LittleEndian.Uint32(e.data[0:]))
+	count := int(binary.LittleEndian.Uint32(e.data[4:]))
+	mapSize := binary.Size(ResTableMap{})
+	if count > (len(e.data)-start)/mapSize {
+		return 0, nil, fmt.Errorf("androidbinary: invalid map count: %d", count)
+	}
+	maps := make([]ResTableMap, count)
+	if err := binary.Read(bytes.NewReader(e.data[start:]), binary.LittleEndian, maps); err != nil {
+		return 0, nil, err
+	}
+	return parent, maps, nil
+}
+
+// GetBag returns the name/value pairs of the complex entry referenced by id, e.g. styles, arrays and plurals.
+// The names are the ids of the attributes for styles, and the indexes from 0x02000000 for arrays.
+// The pairs of the parent styles are included unless the child overrides them.
+// The parents in the packages that the table doesn't have, e.g. the android framework, are ignored.
+func (f *TableFile) GetBag(id ResID, config *ResTableConfig) (map[ResID]ResValue, error) {
+	bag := make(map[ResID]ResValue)
+	visited := make(map[ResID]bool)
+	for depth := 0; depth < maxReferenceDepth; depth++ {
+		if visited[id] {
+			return nil, fmt.Errorf("androidbinary: cyclic parent: %s", id)
+		}
+		visited[id] = true
+
+		p := f.findPackage(id.Package())
+		if p == nil {
+			if depth > 0 {
+				// the parent is out of the table, e.g. in the android framework.
+				return bag, nil
+			}
+			return nil, fmt.Errorf("androidbinary: package 0x%02X not found", id.Package())
+		}
+		e := p.findEntry(id.Type(), id.Entry(), config)
+		if e.Value == nil {
+			return nil, fmt.Errorf("androidbinary: entry 0x%04X not found", id.Entry())
+		}
+		parent, maps, err := e.bag()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range maps {
+			if _, ok := bag[m.Name]; !ok {
+				bag[m.Name] = m.Value
+			}
+		}
+		if parent == 0 {
+			return bag, nil
+		}
+		id = parent
+	}
+	return nil, fmt.Errorf("androidbinary: too deep parent: %s", id)
+}
+
+// GetResource returns a resource referenced by id.
+func (f *TableFile) GetResource(id ResID, config *ResTableConfig) (interface{}, error) {
+	v, err := f.getResValue(id, config)
+	if err != nil {
+		return nil, err
 	}
 	switch v.DataType {
 	case TypeNull:
 		return nil, nil
 	case TypeString:
+		if !f.HasString(ResStringPoolRef(v.Data)) {
+			return nil, &InvalidReferenceError{Ref: ResStringPoolRef(v.Data)}
+		}
 		return f.GetString(ResStringPoolRef(v.Data)), nil
 	case TypeIntDec:
 		return v.Data, nil
@@ -294,11 +1073,51 @@ func (f *TableFile) GetResource(id ResID, config *ResTableConfig) (interface{},
 	return v.Data, nil
 }
 
-// GetString returns a string referenced by ref.
+// GetString returns a string referenced by ref in the global string pool,
+// which holds the values of the string resources.
+// It panics if the pool doesn't contain ref.
 func (f *TableFile) GetString(ref ResStringPoolRef) string {
 	return f.stringPool.GetString(ref)
 }
 
+// GetStyledString returns a string referenced by ref in the global string pool
+// with its style spans reconstructed as HTML-like tags, e.g. "Hello <b>world</b>"
+// for <string name="hello">Hello <b>world</b></string>.
+// It panics if the pool doesn't contain ref.
+func (f *TableFile) GetStyledString(ref ResStringPoolRef) string {
+	return f.stringPool.GetStyledString(ref)
+}
+
+// HasString returns whether the global string pool contains ref.
+func (f *TableFile) HasString(ref ResStringPoolRef) bool {
+	return f.stringPool.HasString(ref)
+}
+
+// GetTypeString returns a string referenced by ref in the type string pool of the package,
+// which holds the names of the resource types, e.g. "string".
+// Note that type ids start at 1, so the name of the type id is at the index id-1.
+// It panics if the pool doesn't contain ref.
+func (p *TablePackage) GetTypeString(ref ResStringPoolRef) string {
+	return p.TypeStrings.GetString(ref)
+}
+
+// HasTypeString returns whether the type string pool of the package contains ref.
+func (p *TablePackage) HasTypeString(ref ResStringPoolRef) bool {
+	return p.TypeStrings.HasString(ref)
+}
+
+// GetKeyString returns a string referenced by ref in the key string pool of the package,
+// which holds the names of the resource entries, e.g. "app_name".
+// It panics if the pool doesn't contain ref.
+func (p *TablePackage) GetKeyString(ref ResStringPoolRef) string {
+	return p.KeyStrings.GetString(ref)
+}
+
+// HasKeyString returns whether the key string pool of the package contains ref.
+func (p *TablePackage) HasKeyString(ref ResStringPoolRef) bool {
+	return p.KeyStrings.HasString(ref)
+}
+
 func (f *TableFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error) {
 	sr := io.NewSectionReader(r, offset, 1<<63-1-offset)
 	chunkHeader := &ResChunkHeader{}
@@ -308,6 +1127,9 @@ func (f *TableFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, err
 	if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
 		return nil, err
 	}
+	if err := validateChunkHeader(chunkHeader); err != nil {
+		return nil, err
+	}
 
 	var err error
 	if _, err := sr.Seek(0, io.SeekStart); err != nil {
@@ -315,11 +1137,17 @@ func (f *TableFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, err
 	}
 	switch chunkHeader.Type {
 	case ResStringPoolChunkType:
-		f.stringPool, err = readStringPool(sr)
+		if f.opts.LazyStrings {
+			f.stringPool, err = readLazyStringPool(io.NewSectionReader(sr, 0, int64(chunkHeader.Size)))
+		} else {
+			f.stringPool, err = readStringPool(sr)
+		}
 	case ResTablePackageType:
 		var tablePackage *TablePackage
 		tablePackage, err = readTablePackage(sr)
-		f.tablePackages[tablePackage.Header.ID] = tablePackage
+		if err == nil {
+			f.tablePackages[tablePackage.Header.ID] = tablePackage
+		}
 	}
 	if err != nil {
 		return nil, err
@@ -335,6 +1163,10 @@ func readTablePackage(sr *io.SectionReader) (*TablePackage, error) {
 		return nil, err
 	}
 	tablePackage.Header = *header
+	tablePackage.rawHeader = make([]byte, header.Header.HeaderSize)
+	if _, err := sr.ReadAt(tablePackage.rawHeader, 0); err != nil {
+		return nil, err
+	}
 
 	srTypes := io.NewSectionReader(sr, int64(header.TypeStrings), int64(header.Header.Size-header.TypeStrings))
 	if typeStrings, err := readStringPool(srTypes); err == nil {
@@ -359,6 +1191,9 @@ func readTablePackage(sr *io.SectionReader) (*TablePackage, error) {
 		if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
 			return nil, err
 		}
+		if err := validateChunkHeader(chunkHeader); err != nil {
+			return nil, err
+		}
 
 		var err error
 		chunkReader := io.NewSectionReader(sr, offset, int64(chunkHeader.Size))
@@ -371,7 +1206,25 @@ func readTablePackage(sr *io.SectionReader) (*TablePackage, error) {
 			tableType, err = readTableType(chunkHeader, chunkReader)
 			tablePackage.TableTypes = append(tablePackage.TableTypes, tableType)
 		case ResTableTypeSpecType:
-			_, err = readTableTypeSpec(chunkReader)
+			var typeSpec *TableTypeSpec
+			typeSpec, err = readTableTypeSpec(chunkReader)
+			tablePackage.TypeSpecs = append(tablePackage.TypeSpecs, typeSpec)
+		case ResTableOverlayableType:
+			var overlayable *TableOverlayable
+			overlayable, err = readTableOverlayable(chunkReader)
+			tablePackage.Overlayables = append(tablePackage.Overlayables, overlayable)
+		case ResTableStagedAliasType:
+			var aliases []TableStagedAlias
+			aliases, err = readTableStagedAlias(chunkReader)
+			tablePackage.StagedAliases = append(tablePackage.StagedAliases, aliases...)
+		case ResStringPoolChunkType:
+			// the type strings and the key strings, which are read above.
+		default:
+			// the chunks unknown to this package, e.g. the ones added by newer versions of aapt2, are kept for Encode.
+			chunk := make([]byte, chunkHeader.Size)
+			if _, err = chunkReader.ReadAt(chunk, 0); err == nil {
+				tablePackage.unknownChunks = append(tablePackage.unknownChunks, chunk)
+			}
 		}
 		if err != nil {
 			return nil, err
@@ -382,64 +1235,198 @@ func readTablePackage(sr *io.SectionReader) (*TablePackage, error) {
 	return tablePackage, nil
 }
 
+// readResTableConfig reads the configuration from data.
+// The configuration is prefixed with its size, which depends on the version of aapt,
+// so the fields out of the size are zero-filled and the extra bytes are ignored.
+func readResTableConfig(config *ResTableConfig, data []byte) error {
+	if len(data) < 4 {
+		*config = ResTableConfig{}
+		return nil
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < len(data) {
+		data = data[:size]
+	}
+	buf := make([]byte, binary.Size(config))
+	copy(buf, data)
+	return binary.Read(bytes.NewReader(buf), binary.LittleEndian, config)
+}
+
 func readTableType(chunkHeader *ResChunkHeader, sr *io.SectionReader) (*TableType, error) {
 	// TableType header may be omitted
 	header := new(ResTableType)
 	if _, err := sr.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	buf, err := newZeroFilledReader(sr, int64(chunkHeader.HeaderSize), int64(unsafe.Sizeof(*header)))
+	buf, err := newZeroFilledReader(sr, int64(chunkHeader.HeaderSize), int64(binary.Size(header)))
 	if err != nil {
 		return nil, err
 	}
 	if err := binary.Read(buf, binary.LittleEndian, header); err != nil {
 		return nil, err
 	}
+	rawHeader := make([]byte, chunkHeader.HeaderSize)
+	if _, err := sr.ReadAt(rawHeader, 0); err != nil {
+		return nil, err
+	}
+	var rawConfig []byte
+	if configStart := binary.Size(header) - binary.Size(header.Config); len(rawHeader) > configStart {
+		rawConfig = rawHeader[configStart:]
+	}
+	if err := readResTableConfig(&header.Config, rawConfig); err != nil {
+		return nil, err
+	}
 
-	entryIndexes := make([]uint32, header.EntryCount)
 	if _, err := sr.Seek(int64(header.Header.HeaderSize), io.SeekStart); err != nil {
 		return nil, err
 	}
-	if err := binary.Read(sr, binary.LittleEndian, entryIndexes); err != nil {
+	entryIndexes, err := readUint32s(sr, header.EntryCount)
+	if err != nil {
 		return nil, err
 	}
 
 	entries := make([]TableEntry, header.EntryCount)
 	for i, index := range entryIndexes {
-		if index == 0xFFFFFFFF {
+		if index == NoEntry {
 			continue
 		}
 		if _, err := sr.Seek(int64(header.EntriesStart+index), io.SeekStart); err != nil {
 			return nil, err
 		}
 		var key ResTableEntry
-		binary.Read(sr, binary.LittleEndian, &key)
+		if err := binary.Read(sr, binary.LittleEndian, &key); err != nil {
+			return nil, err
+		}
 		entries[i].Key = &key
 
 		var val ResValue
-		binary.Read(sr, binary.LittleEndian, &val)
+		if err := binary.Read(sr, binary.LittleEndian, &val); err != nil {
+			return nil, err
+		}
 		entries[i].Value = &val
+
+		if key.Flags&EntryFlagComplex != 0 {
+			// the count of the name/value pairs follows the parent reference.
+			count := val.Data
+			size := int64(key.Size) - int64(binary.Size(key)) + int64(count)*12
+			if size < 0 {
+				return nil, fmt.Errorf("androidbinary: invalid entry size: %d", key.Size)
+			}
+			if _, err := sr.Seek(int64(header.EntriesStart+index)+int64(binary.Size(key)), io.SeekStart); err != nil {
+				return nil, err
+			}
+			data, err := readBytes(sr, size)
+			if err != nil {
+				return nil, err
+			}
+			entries[i].data = data
+		}
 	}
 	return &TableType{
-		header,
-		entries,
+		Header:    header,
+		Entries:   entries,
+		rawHeader: rawHeader,
 	}, nil
 }
 
-func readTableTypeSpec(sr *io.SectionReader) ([]uint32, error) {
+func readTableTypeSpec(sr *io.SectionReader) (*TableTypeSpec, error) {
 	header := new(ResTableTypeSpec)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
 		return nil, err
 	}
 
-	flags := make([]uint32, header.EntryCount)
 	if _, err := sr.Seek(int64(header.Header.HeaderSize), io.SeekStart); err != nil {
 		return nil, err
 	}
-	if err := binary.Read(sr, binary.LittleEndian, flags); err != nil {
+	flags, err := readUint32s(sr, header.EntryCount)
+	if err != nil {
+		return nil, err
+	}
+	return &TableTypeSpec{
+		Header: header,
+		Flags:  flags,
+	}, nil
+}
+
+func readTableOverlayable(sr *io.SectionReader) (*TableOverlayable, error) {
+	header := new(ResTableOverlayable)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	overlayable := &TableOverlayable{Header: header}
+
+	offset := int64(header.Header.HeaderSize)
+	for offset < int64(header.Header.Size) {
+		chunkHeader := &ResChunkHeader{}
+		if _, err := sr.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
+			return nil, err
+		}
+		if err := validateChunkHeader(chunkHeader); err != nil {
+			return nil, err
+		}
+		if chunkHeader.Type == ResTableOverlayablePolicyType {
+			policy, err := readTableOverlayablePolicy(io.NewSectionReader(sr, offset, int64(chunkHeader.Size)))
+			if err != nil {
+				return nil, err
+			}
+			overlayable.Policies = append(overlayable.Policies, policy)
+		}
+		offset += int64(chunkHeader.Size)
+	}
+	return overlayable, nil
+}
+
+func readTableOverlayablePolicy(sr *io.SectionReader) (*TableOverlayablePolicy, error) {
+	header := new(ResTableOverlayablePolicy)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if int64(header.EntryCount) > (sr.Size()-int64(header.Header.HeaderSize))/4 {
+		return nil, fmt.Errorf("androidbinary: too many overlayable entries: %d", header.EntryCount)
+	}
+	if _, err := sr.Seek(int64(header.Header.HeaderSize), io.SeekStart); err != nil {
+		return nil, err
+	}
+	refs, err := readUint32s(sr, header.EntryCount)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]ResID, len(refs))
+	for i, ref := range refs {
+		ids[i] = ResID(ref)
+	}
+	return &TableOverlayablePolicy{
+		Header: header,
+		IDs:    ids,
+	}, nil
+}
+
+func readTableStagedAlias(sr *io.SectionReader) ([]TableStagedAlias, error) {
+	header := new(ResTableStagedAlias)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if int64(header.Count) > (sr.Size()-int64(header.Header.HeaderSize))/8 {
+		return nil, fmt.Errorf("androidbinary: too many staged aliases: %d", header.Count)
+	}
+	if _, err := sr.Seek(int64(header.Header.HeaderSize), io.SeekStart); err != nil {
+		return nil, err
+	}
+	ids, err := readUint32s(sr, 2*header.Count)
+	if err != nil {
 		return nil, err
 	}
-	return flags, nil
+	aliases := make([]TableStagedAlias, header.Count)
+	for i := range aliases {
+		aliases[i] = TableStagedAlias{
+			StagedID:    ResID(ids[2*i]),
+			FinalizedID: ResID(ids[2*i+1]),
+		}
+	}
+	return aliases, nil
 }
 
 // IsMoreSpecificThan returns true if c is more specific than o.
@@ -542,6 +1529,36 @@ func (c *ResTableConfig) IsMoreSpecificThan(o *ResTableConfig) bool {
 		}
 	}
 
+	// screen round
+	if ((c.ScreenLayout2 ^ o.ScreenLayout2) & MaskScreenRound) != 0 {
+		if (c.ScreenLayout2 & MaskScreenRound) == 0 {
+			return false
+		}
+		if (o.ScreenLayout2 & MaskScreenRound) == 0 {
+			return true
+		}
+	}
+
+	// color mode
+	if c.ColorMode != 0 || o.ColorMode != 0 {
+		if ((c.ColorMode ^ o.ColorMode) & MaskHDR) != 0 {
+			if (c.ColorMode & MaskHDR) == 0 {
+				return false
+			}
+			if (o.ColorMode & MaskHDR) == 0 {
+				return true
+			}
+		}
+		if ((c.ColorMode ^ o.ColorMode) & MaskWideColorGamut) != 0 {
+			if (c.ColorMode & MaskWideColorGamut) == 0 {
+				return false
+			}
+			if (o.ColorMode & MaskWideColorGamut) == 0 {
+				return true
+			}
+		}
+	}
+
 	// orientation
 	if c.Orientation != o.Orientation {
 		if c.Orientation == 0 {
@@ -668,6 +1685,14 @@ func (c *ResTableConfig) IsMoreSpecificThan(o *ResTableConfig) bool {
 }
 
 // IsBetterThan returns true if c is better than o for the r configuration.
+// Both c and o are expected to match r (see Match).
+// The qualifiers are compared in the order of precedence Android uses:
+// MCC, MNC, locale, layout direction, smallest width, available width and height, screen size,
+// screen aspect, round screen, color mode, orientation, UI mode, night mode, density, touchscreen,
+// keyboard availability, keyboard, navigation, screen dimensions and version.
+// The first qualifier that differs decides; e.g. for an en-US xxhdpi device,
+// en-US is better than en, and xhdpi is better than hdpi.
+// If r is nil, it is same as IsMoreSpecificThan.
 func (c *ResTableConfig) IsBetterThan(o *ResTableConfig, r *ResTableConfig) bool {
 	if r == nil {
 		return c.IsMoreSpecificThan(o)
@@ -694,6 +1719,8 @@ func (c *ResTableConfig) IsBetterThan(o *ResTableConfig, r *ResTableConfig) bool
 	// locale
 	if c.IsLocaleBetterThan(o, r) {
 		return true
+	} else if o.IsLocaleBetterThan(c, r) {
+		return false
 	}
 
 	// screen layout
@@ -756,6 +1783,24 @@ func (c *ResTableConfig) IsBetterThan(o *ResTableConfig, r *ResTableConfig) bool
 		}
 	}
 
+	// screen round
+	if ((c.ScreenLayout2^o.ScreenLayout2)&MaskScreenRound) != 0 &&
+		(r.ScreenLayout2&MaskScreenRound) != 0 {
+		return (c.ScreenLayout2 & MaskScreenRound) != 0
+	}
+
+	// color mode
+	if c.ColorMode != 0 || o.ColorMode != 0 {
+		if ((c.ColorMode^o.ColorMode)&MaskWideColorGamut) != 0 &&
+			(r.ColorMode&MaskWideColorGamut) != 0 {
+			return (c.ColorMode & MaskWideColorGamut) != 0
+		}
+		if ((c.ColorMode^o.ColorMode)&MaskHDR) != 0 &&
+			(r.ColorMode&MaskHDR) != 0 {
+			return (c.ColorMode & MaskHDR) != 0
+		}
+	}
+
 	// orientation
 	if c.Orientation != o.Orientation && r.Orientation != 0 {
 		return c.Orientation != 0
@@ -936,6 +1981,11 @@ func (c *ResTableConfig) IsLocaleBetterThan(o *ResTableConfig, r *ResTableConfig
 }
 
 // Match returns true if c can be considered a match for the parameters in settings.
+// It is the compatibility check Android does before choosing the best resource:
+// the qualifiers of c must be unspecified or compatible with settings,
+// e.g. "en" matches an en-US device, and "sw600dp" matches a device whose smallest width is 720dp.
+// The density never excludes resources, because Android scales them.
+// Use IsBetterThan to choose one of the matching configurations.
 func (c *ResTableConfig) Match(settings *ResTableConfig) bool {
 	// nil ResTableConfig always matches.
 	if settings == nil {
@@ -999,6 +2049,25 @@ func (c *ResTableConfig) Match(settings *ResTableConfig) bool {
 		return false
 	}
 
+	// screen round
+	screenRound := c.ScreenLayout2 & MaskScreenRound
+	setScreenRound := settings.ScreenLayout2 & MaskScreenRound
+	if screenRound != 0 && screenRound != setScreenRound {
+		return false
+	}
+
+	// color mode
+	wideColorGamut := c.ColorMode & MaskWideColorGamut
+	setWideColorGamut := settings.ColorMode & MaskWideColorGamut
+	if wideColorGamut != 0 && wideColorGamut != setWideColorGamut {
+		return false
+	}
+	hdr := c.ColorMode & MaskHDR
+	setHDR := settings.ColorMode & MaskHDR
+	if hdr != 0 && hdr != setHDR {
+		return false
+	}
+
 	// ui mode
 	uiModeType := c.UIMode & MaskUIModeType
 	setUIModeType := settings.UIMode & MaskUIModeType
@@ -1081,13 +2150,303 @@ func (c *ResTableConfig) Match(settings *ResTableConfig) bool {
 	return true
 }
 
-// Locale returns the locale of the configuration.
+// Locale returns the locale of the configuration as a BCP-47 language tag, e.g. "en-US" or "zh-Hans-CN".
+// It returns an empty string if the configuration has no locale.
 func (c *ResTableConfig) Locale() string {
-	if c.Language[0] == 0 {
+	var tags []string
+	if c.Language[0] != 0 {
+		tags = append(tags, unpackLanguageOrRegion(c.Language, 'a'))
+	}
+	if c.LocaleScript[0] != 0 {
+		tags = append(tags, trimNUL(c.LocaleScript[:]))
+	}
+	if c.Country[0] != 0 {
+		tags = append(tags, unpackLanguageOrRegion(c.Country, '0'))
+	}
+	if c.LocaleVariant[0] != 0 {
+		tags = append(tags, trimNUL(c.LocaleVariant[:]))
+	}
+	return strings.Join(tags, "-")
+}
+
+// String returns the qualifiers of the configuration in the resource directory form,
+// ordered as Android's ResTable_config::toString, e.g. "en-rUS-xhdpi-v21".
+// It returns an empty string for the default configuration.
+// Use "values-" + c.String() to build the name of the directory of value resources.
+func (c *ResTableConfig) String() string {
+	var res []string
+	if c.Mcc != 0 {
+		res = append(res, fmt.Sprintf("mcc%d", c.Mcc))
+	}
+	if c.Mnc != 0 {
+		if c.Mnc == 0xffff {
+			res = append(res, "mnc00")
+		} else {
+			res = append(res, fmt.Sprintf("mnc%d", c.Mnc))
+		}
+	}
+	if c.Language[0] != 0 {
+		res = append(res, c.dirLocale())
+	}
+
+	switch c.ScreenLayout & MaskLayoutDir {
+	case LayoutDirAny:
+	case LayoutDirLTR:
+		res = append(res, "ldltr")
+	case LayoutDirRTL:
+		res = append(res, "ldrtl")
+	default:
+		res = append(res, fmt.Sprintf("layoutDir=%d", (c.ScreenLayout&MaskLayoutDir)>>ShiftLayoutDir))
+	}
+	if c.SmallestScreenWidthDp != 0 {
+		res = append(res, fmt.Sprintf("sw%ddp", c.SmallestScreenWidthDp))
+	}
+	if c.ScreenWidthDp != 0 {
+		res = append(res, fmt.Sprintf("w%ddp", c.ScreenWidthDp))
+	}
+	if c.ScreenHeightDp != 0 {
+		res = append(res, fmt.Sprintf("h%ddp", c.ScreenHeightDp))
+	}
+
+	// the raw values of the configuration are used here, same as aapt.
+	switch v := c.ScreenLayout & MaskScreenSize; v {
+	case 0:
+	case 1:
+		res = append(res, "small")
+	case 2:
+		res = append(res, "normal")
+	case 3:
+		res = append(res, "large")
+	case 4:
+		res = append(res, "xlarge")
+	default:
+		res = append(res, fmt.Sprintf("screenLayoutSize=%d", v))
+	}
+	switch c.ScreenLayout & MaskScreenLong {
+	case ScreenLongAny:
+	case ScreenLongNo:
+		res = append(res, "notlong")
+	case ScreenLongYes:
+		res = append(res, "long")
+	default:
+		res = append(res, fmt.Sprintf("screenLayoutLong=%d", (c.ScreenLayout&MaskScreenLong)>>ShiftScreenLong))
+	}
+	switch c.ScreenLayout2 & MaskScreenRound {
+	case ScreenRoundAny:
+	case ScreenRoundNo:
+		res = append(res, "notround")
+	case ScreenRoundYes:
+		res = append(res, "round")
+	default:
+		res = append(res, fmt.Sprintf("screenRound=%d", c.ScreenLayout2&MaskScreenRound))
+	}
+	switch c.ColorMode & MaskWideColorGamut {
+	case WideColorGamutAny:
+	case WideColorGamutNo:
+		res = append(res, "nowidecg")
+	case WideColorGamutYes:
+		res = append(res, "widecg")
+	default:
+		res = append(res, fmt.Sprintf("wideColorGamut=%d", c.ColorMode&MaskWideColorGamut))
+	}
+	switch c.ColorMode & MaskHDR {
+	case HDRAny:
+	case HDRNo:
+		res = append(res, "lowdr")
+	case HDRYes:
+		res = append(res, "highdr")
+	default:
+		res = append(res, fmt.Sprintf("hdr=%d", (c.ColorMode&MaskHDR)>>ShiftHDR))
+	}
+
+	switch c.Orientation {
+	case 0:
+	case 1:
+		res = append(res, "port")
+	case 2:
+		res = append(res, "land")
+	case 3:
+		res = append(res, "square")
+	default:
+		res = append(res, fmt.Sprintf("orientation=%d", c.Orientation))
+	}
+
+	switch v := c.UIMode & MaskUIModeType; v {
+	case 0, 1:
+	case 2:
+		res = append(res, "desk")
+	case 3:
+		res = append(res, "car")
+	case 4:
+		res = append(res, "television")
+	case 5:
+		res = append(res, "appliance")
+	case 6:
+		res = append(res, "watch")
+	case 7:
+		res = append(res, "vrheadset")
+	default:
+		res = append(res, fmt.Sprintf("uiModeType=%d", v))
+	}
+	switch c.UIMode & MaskUIModeNight {
+	case UIModeNightAny:
+	case UIModeNightNo:
+		res = append(res, "notnight")
+	case UIModeNightYes:
+		res = append(res, "night")
+	default:
+		res = append(res, fmt.Sprintf("uiModeNight=%d", (c.UIMode&MaskUIModeNight)>>ShiftUIModeNight))
+	}
+
+	if d := c.DensityBucket(); d != "" {
+		res = append(res, d)
+	}
+
+	switch c.Touchscreen {
+	case 0:
+	case 1:
+		res = append(res, "notouch")
+	case 2:
+		res = append(res, "stylus")
+	case 3:
+		res = append(res, "finger")
+	default:
+		res = append(res, fmt.Sprintf("touchscreen=%d", c.Touchscreen))
+	}
+
+	switch c.InputFlags & MaskKeysHidden {
+	case KeysHiddenAny:
+	case KeysHiddenNo:
+		res = append(res, "keysexposed")
+	case KeysHiddenYes:
+		res = append(res, "keyshidden")
+	case KeysHiddenSoft:
+		res = append(res, "keyssoft")
+	}
+	switch c.Keyboard {
+	case 0:
+	case 1:
+		res = append(res, "nokeys")
+	case 2:
+		res = append(res, "qwerty")
+	case 3:
+		res = append(res, "12key")
+	default:
+		res = append(res, fmt.Sprintf("keyboard=%d", c.Keyboard))
+	}
+	switch c.InputFlags & MaskNavHidden {
+	case NavHiddenAny:
+	case NavHiddenNo:
+		res = append(res, "navexposed")
+	case NavHiddenYes:
+		res = append(res, "navhidden")
+	default:
+		res = append(res, fmt.Sprintf("inputFlagsNavHidden=%d", (c.InputFlags&MaskNavHidden)>>2))
+	}
+	switch c.Navigation {
+	case 0:
+	case 1:
+		res = append(res, "nonav")
+	case 2:
+		res = append(res, "dpad")
+	case 3:
+		res = append(res, "trackball")
+	case 4:
+		res = append(res, "wheel")
+	default:
+		res = append(res, fmt.Sprintf("navigation=%d", c.Navigation))
+	}
+
+	if c.ScreenWidth != 0 || c.ScreenHeight != 0 {
+		res = append(res, fmt.Sprintf("%dx%d", c.ScreenWidth, c.ScreenHeight))
+	}
+	if c.SDKVersion != 0 || c.MinorVersion != 0 {
+		v := fmt.Sprintf("v%d", c.SDKVersion)
+		if c.MinorVersion != 0 {
+			v += fmt.Sprintf(".%d", c.MinorVersion)
+		}
+		res = append(res, v)
+	}
+	return strings.Join(res, "-")
+}
+
+// Key returns a string that identifies the qualifiers of c, to be used as a map key,
+// e.g. for caching the values resolved for each configuration.
+// The configurations that differ only in Size and the padding fields have the same key,
+// which a comparison of the structs doesn't ensure: Size depends on the version of aapt.
+// Unlike String, it keeps all the fields, so the different configurations never have the same key.
+// The key of nil is empty, and it differs from the key of a zero-value ResTableConfig.
+func (c *ResTableConfig) Key() string {
+	if c == nil {
 		return ""
 	}
-	if c.Country[0] == 0 {
-		return fmt.Sprintf("%c%c", c.Language[0], c.Language[1])
+	k := *c
+	k.Size = 0
+	k.InputPad0 = 0
+	k.ScreenConfigPad2 = 0
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &k)
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// dirLocale returns the locale qualifier, e.g. "en-rUS" or "b+sr+Latn".
+func (c *ResTableConfig) dirLocale() string {
+	if c.LocaleScript[0] == 0 && c.LocaleVariant[0] == 0 {
+		l := unpackLanguageOrRegion(c.Language, 'a')
+		if c.Country[0] != 0 {
+			l += "-r" + unpackLanguageOrRegion(c.Country, '0')
+		}
+		return l
+	}
+	return "b+" + strings.Replace(c.Locale(), "-", "+", -1)
+}
+
+// DensityBucket returns the density qualifier of the configuration, e.g. "hdpi" or "xxhdpi".
+// Nonstandard densities are returned in the "NNNdpi" form, and it returns an empty string for the default density.
+func (c *ResTableConfig) DensityBucket() string {
+	switch c.Density {
+	case DensityDefault:
+		return ""
+	case DensityLow:
+		return "ldpi"
+	case DensityMedium:
+		return "mdpi"
+	case DensityTV:
+		return "tvdpi"
+	case DensityHigh:
+		return "hdpi"
+	case DensityXHigh:
+		return "xhdpi"
+	case DensityXXHigh:
+		return "xxhdpi"
+	case DensityXXXHigh:
+		return "xxxhdpi"
+	case DensityAny:
+		return "anydpi"
+	case DensityNone:
+		return "nodpi"
+	}
+	return fmt.Sprintf("%ddpi", c.Density)
+}
+
+// unpackLanguageOrRegion decodes the language or the region packed into two bytes.
+// Two-letter codes are stored as is, and three-letter codes are packed in base 32
+// with the high bit set, starting from base ('a' for languages and '0' for regions).
+func unpackLanguageOrRegion(in [2]uint8, base uint8) string {
+	if in[0]&0x80 == 0 {
+		return trimNUL(in[:])
+	}
+	first := in[1] & 0x1f
+	second := ((in[1] & 0xe0) >> 5) + ((in[0] & 0x03) << 3)
+	third := (in[0] & 0x7c) >> 2
+	return string([]byte{first + base, second + base, third + base})
+}
+
+func trimNUL(b []uint8) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
 	}
-	return fmt.Sprintf("%c%c-%c%c", c.Language[0], c.Language[1], c.Country[0], c.Country[1])
+	return string(b)
 }
@@ -1,8 +1,16 @@
 package androidbinary
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"unicode/utf16"
 )
 
 func TestIsResId(t *testing.T) {
@@ -437,6 +445,49 @@ var isBetterThanTests = []struct {
 		require:  &ResTableConfig{SDKVersion: 1, MinorVersion: 1},
 		expected: true,
 	},
+
+	// locale
+	{
+		me:       &ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'U', 'S'}},
+		other:    &ResTableConfig{Language: [2]uint8{'e', 'n'}},
+		require:  &ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'U', 'S'}},
+		expected: true,
+	},
+	{
+		me:       &ResTableConfig{Language: [2]uint8{'e', 'n'}},
+		other:    &ResTableConfig{},
+		require:  &ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'U', 'S'}},
+		expected: true,
+	},
+
+	// density
+	{
+		me:       &ResTableConfig{Density: DensityXHigh},
+		other:    &ResTableConfig{Density: DensityHigh},
+		require:  &ResTableConfig{Density: DensityXXHigh},
+		expected: true,
+	},
+	{
+		// scaling down is preferred to scaling up
+		me:       &ResTableConfig{Density: DensityXHigh},
+		other:    &ResTableConfig{Density: DensityMedium},
+		require:  &ResTableConfig{Density: DensityHigh},
+		expected: true,
+	},
+	{
+		me:       &ResTableConfig{Density: DensityXXHigh},
+		other:    &ResTableConfig{Density: DensityXXXHigh},
+		require:  &ResTableConfig{Density: DensityXXHigh},
+		expected: true,
+	},
+
+	// locale has priority over density
+	{
+		me:       &ResTableConfig{Language: [2]uint8{'e', 'n'}, Density: DensityHigh},
+		other:    &ResTableConfig{Density: DensityXXHigh},
+		require:  &ResTableConfig{Language: [2]uint8{'e', 'n'}, Density: DensityXXHigh},
+		expected: true,
+	},
 }
 
 func TestIsBetterThan(t *testing.T) {
@@ -458,3 +509,1274 @@ func TestIsBetterThan(t *testing.T) {
 		}
 	}
 }
+
+func loadMyApplicationTestData(t *testing.T) *TableFile {
+	t.Helper()
+	f, err := os.Open("testdata/MyApplication/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tableFile, err := NewTableFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tableFile
+}
+
+func TestNewTableFileLazyStrings(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eager, err := NewTableFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := NewTableFileOptions(bytes.NewReader(data), TableOptions{LazyStrings: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; eager.HasString(ResStringPoolRef(i)); i++ {
+		ref := ResStringPoolRef(i)
+		if got, want := lazy.GetString(ref), eager.GetString(ref); got != want {
+			t.Errorf("%d: got %q want %q", i, got, want)
+		}
+	}
+	s, err := lazy.GetResource(0x7F0B0027, &ResTableConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "My Application" {
+		t.Errorf("got %v want My Application", s)
+	}
+
+	// the lazy table is encoded in the same way.
+	var want, got bytes.Buffer
+	if err := eager.Encode(&want); err != nil {
+		t.Fatal(err)
+	}
+	if err := lazy.Encode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("the encoded tables differ")
+	}
+}
+
+// newTestLargeTableData returns resources.arsc of MyApplication with many unused strings.
+func newTestLargeTableData(b *testing.B) []byte {
+	b.Helper()
+	f, err := os.Open("testdata/MyApplication/resources.arsc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	tableFile, err := NewTableFile(f)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 50000; i++ {
+		tableFile.stringPool.Strings = append(tableFile.stringPool.Strings, fmt.Sprintf("unused string resource %d", i))
+	}
+	var buf bytes.Buffer
+	if err := tableFile.Encode(&buf); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func benchmarkNewTableFileOptions(b *testing.B, opts TableOptions) {
+	data := newTestLargeTableData(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tableFile, err := NewTableFileOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := tableFile.GetResource(0x7F0B0027, &ResTableConfig{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewTableFileLargeStringPool(b *testing.B) {
+	benchmarkNewTableFileOptions(b, TableOptions{})
+}
+
+func BenchmarkNewTableFileLargeStringPoolLazy(b *testing.B) {
+	benchmarkNewTableFileOptions(b, TableOptions{LazyStrings: true})
+}
+
+func TestGetResourceByName(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	cases := []struct {
+		name string
+		want ResValue
+	}{
+		{
+			name: "@bool/test_true",
+			want: ResValue{Size: 8, DataType: TypeIntBoolean, Data: 0xFFFFFFFF},
+		},
+		{
+			name: "@com.shogo82148.androidbinary.myapplication:bool/test_false",
+			want: ResValue{Size: 8, DataType: TypeIntBoolean, Data: 0},
+		},
+		{
+			name: "integer/test",
+			want: ResValue{Size: 8, DataType: TypeIntDec, Data: 0xFFFFFFD6}, // -42
+		},
+	}
+	for _, c := range cases {
+		got, err := tableFile.GetResourceByName(c.name, &ResTableConfig{})
+		if err != nil {
+			t.Errorf("%s: got %v want no error", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %+v want %+v", c.name, got, c.want)
+		}
+	}
+
+	// string values
+	for _, name := range []string{"@string/app_name", "@com.shogo82148.androidbinary.myapplication:string/app_name"} {
+		v, err := tableFile.GetResourceByName(name, &ResTableConfig{})
+		if err != nil {
+			t.Errorf("%s: got %v want no error", name, err)
+			continue
+		}
+		if v.DataType != TypeString {
+			t.Errorf("%s: got type 0x%02X want TypeString", name, v.DataType)
+			continue
+		}
+		if s := tableFile.GetString(ResStringPoolRef(v.Data)); s != "My Application" {
+			t.Errorf("%s: got %q want %q", name, s, "My Application")
+		}
+	}
+
+	// errors
+	for _, name := range []string{"@string/not_found", "@unknown.package:string/app_name", "@unknown/app_name", "@string", "@string/"} {
+		if _, err := tableFile.GetResourceByName(name, &ResTableConfig{}); err == nil {
+			t.Errorf("%s: want error, got nil", name)
+		}
+	}
+}
+
+func TestGetResourceByNameDuplicatedKeys(t *testing.T) {
+	// the key pool has "app_name" twice, and the entry uses the second one.
+	tableFile := newTestTableFile(
+		ResValue{DataType: TypeIntDec, Data: 1},
+		ResValue{DataType: TypeIntDec, Data: 2},
+	)
+	p := tableFile.tablePackages[0x7F]
+	p.TypeStrings = &ResStringPool{Strings: []string{"integer"}}
+	p.KeyStrings = &ResStringPool{Strings: []string{"app_name", "title", "app_name"}}
+	p.TableTypes[0].Entries[0].Key.Key = 1
+	p.TableTypes[0].Entries[1].Key.Key = 2
+
+	got, err := tableFile.GetResourceByName("@integer/app_name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Data != 2 {
+		t.Errorf("got %d want 2", got.Data)
+	}
+}
+
+func TestGetStyledString(t *testing.T) {
+	// <string name="styled">Hello <u><b>bold</b> <font color="#ff0000">red</font></u> world</string>
+	// <string name="plain">plain <text></string>
+	spans := []uint32{
+		2, 6, 13, // u
+		3, 6, 9, // b
+		4, 11, 13, // font
+		styleSpanEnd,
+		styleSpanEnd, styleSpanEnd,
+	}
+	styleData := new(bytes.Buffer)
+	binary.Write(styleData, binary.LittleEndian, spans)
+	pool := &ResStringPool{
+		Header:      ResStringPoolHeader{Flags: UTF8Flag},
+		Strings:     []string{"Hello bold red world", "plain <text>", "u", "b", "font;color=#ff0000"},
+		styleStarts: []uint32{0},
+		styleData:   styleData.Bytes(),
+	}
+	data, err := pool.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err = readStringPool(io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableFile := &TableFile{stringPool: pool}
+
+	cases := []struct {
+		ref  ResStringPoolRef
+		want string
+	}{
+		{0, `Hello <u><b>bold</b> <font color="#ff0000">red</font></u> world`},
+		{1, "plain &lt;text&gt;"},
+		{2, "u"},
+	}
+	for _, c := range cases {
+		if got := tableFile.GetStyledString(c.ref); got != c.want {
+			t.Errorf("%d: got %q want %q", c.ref, got, c.want)
+		}
+	}
+}
+
+func TestNoEntry(t *testing.T) {
+	var name [128]uint16
+	copy(name[:], utf16.Encode([]rune("com.example")))
+	key := func(ref ResStringPoolRef) *ResTableEntry {
+		return &ResTableEntry{Size: 8, Key: ref}
+	}
+	fr := ResTableConfig{Size: 64, Language: [2]uint8{'f', 'r'}}
+	tableFile := &TableFile{
+		stringPool: &ResStringPool{},
+		tablePackages: map[uint32]*TablePackage{
+			0x7F: {
+				Header:      ResTablePackage{ID: 0x7F, Name: name},
+				TypeStrings: &ResStringPool{Strings: []string{"integer"}},
+				KeyStrings:  &ResStringPool{Strings: []string{"only_default", "translated"}},
+				TableTypes: []*TableType{
+					{
+						Header: &ResTableType{ID: 0x01, Config: ResTableConfig{Size: 64}},
+						Entries: []TableEntry{
+							{Key: key(0), Value: &ResValue{Size: 8, DataType: TypeIntDec, Data: 1}},
+							{Key: key(1), Value: &ResValue{Size: 8, DataType: TypeIntDec, Data: 2}},
+						},
+					},
+					{
+						// the first entry is NO_ENTRY in the fr configuration.
+						Header: &ResTableType{ID: 0x01, Config: fr},
+						Entries: []TableEntry{
+							{},
+							{Key: key(1), Value: &ResValue{Size: 8, DataType: TypeIntDec, Data: 3}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// read the table from the binary format, in which the missing entry is NO_ENTRY.
+	var buf bytes.Buffer
+	if err := tableFile.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte{0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Fatal("NO_ENTRY is not encoded")
+	}
+	tableFile, err := NewTableFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		id   ResID
+		want uint32
+	}{
+		{0x7F010000, 1}, // falls through to the default configuration
+		{0x7F010001, 3},
+	}
+	for _, c := range cases {
+		v, err := tableFile.GetResource(c.id, &fr)
+		if err != nil {
+			t.Errorf("%s: got %v want no error", c.id, err)
+			continue
+		}
+		if v != c.want {
+			t.Errorf("%s: got %v want %d", c.id, v, c.want)
+		}
+		config, _, err := tableFile.FindBestConfig(c.id, &fr)
+		if err != nil {
+			t.Errorf("%s: got %v want no error", c.id, err)
+			continue
+		}
+		if c.want == 1 && config.Locale() != "" {
+			t.Errorf("%s: got config %s want the default", c.id, config)
+		}
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	name, ok := tableFile.ResourceName(0x7F0B0027)
+	if !ok {
+		t.Fatal("want ok")
+	}
+	if want := "com.shogo82148.androidbinary.myapplication:string/app_name"; name != want {
+		t.Errorf("got %q want %q", name, want)
+	}
+
+	// the name is resolved to the same id.
+	got, err := tableFile.GetResourceByName("@"+name, &ResTableConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := tableFile.getResValue(0x7F0B0027, &ResTableConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != *want {
+		t.Errorf("got %+v want %+v", got, *want)
+	}
+
+	for _, id := range []ResID{0x7F0BFFFF, 0x7FFF0000, 0x01010000} {
+		if _, ok := tableFile.ResourceName(id); ok {
+			t.Errorf("%s: want not ok", id)
+		}
+	}
+}
+
+// newTestTableFile returns a TableFile that has the package 0x7F with one type(0x01).
+func newTestTableFile(values ...ResValue) *TableFile {
+	entries := make([]TableEntry, len(values))
+	for i := range values {
+		entries[i] = TableEntry{
+			Key:   &ResTableEntry{Size: 8, Key: ResStringPoolRef(i)},
+			Value: &values[i],
+		}
+	}
+	return &TableFile{
+		tablePackages: map[uint32]*TablePackage{
+			0x7F: {
+				Header: ResTablePackage{ID: 0x7F},
+				TableTypes: []*TableType{
+					{
+						Header:  &ResTableType{ID: 0x01, EntryCount: uint32(len(entries))},
+						Entries: entries,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestIDForPath(t *testing.T) {
+	tableFile := loadTestData()
+	id, ok := tableFile.IDForPath("res/layout/map.xml")
+	if !ok || id != 0x7F030000 {
+		t.Errorf("got %s, %v want 0x7F030000", id, ok)
+	}
+	if path, ok := tableFile.PathForID(0x7F030000, nil); !ok || path != "res/layout/map.xml" {
+		t.Errorf("got %q, %v want res/layout/map.xml", path, ok)
+	}
+	if _, ok := tableFile.IDForPath("res/layout/not_found.xml"); ok {
+		t.Error("want not found")
+	}
+	// @string/app_name is not a file.
+	if path, ok := tableFile.PathForID(0x7F040000, nil); ok {
+		t.Errorf("got %q want not found", path)
+	}
+
+	// the paths of the other configurations refer to the same id.
+	tableFile = loadMyApplicationTestData(t)
+	id, ok = tableFile.IDForPath("res/drawable-mdpi-v4/abc_btn_check_to_on_mtrl_000.png")
+	if !ok {
+		t.Fatal("want found")
+	}
+	if id2, ok := tableFile.IDForPath("res/drawable-xxhdpi-v4/abc_btn_check_to_on_mtrl_000.png"); !ok || id2 != id {
+		t.Errorf("got %s, %v want %s", id2, ok, id)
+	}
+	cases := []struct {
+		density uint16
+		want    string
+	}{
+		{160, "res/drawable-mdpi-v4/abc_btn_check_to_on_mtrl_000.png"},
+		{480, "res/drawable-xxhdpi-v4/abc_btn_check_to_on_mtrl_000.png"},
+	}
+	for _, c := range cases {
+		path, ok := tableFile.PathForID(id, &ResTableConfig{Density: c.density, SDKVersion: 28})
+		if !ok || path != c.want {
+			t.Errorf("%d: got %q, %v want %s", c.density, path, ok, c.want)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	got := loadTestData().Files(nil)
+	want := map[string]ResID{
+		"res/drawable/fireworks.png": 0x7F020000,
+		"res/drawable/flag.png":      0x7F020001,
+		"res/layout/map.xml":         0x7F030000,
+		"res/layout/setting.xml":     0x7F030001,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	// count the files by their directories.
+	tableFile := loadMyApplicationTestData(t)
+	config := &ResTableConfig{Density: 480, SDKVersion: 28}
+	files := tableFile.Files(config)
+	counts := make(map[string]int)
+	for path := range files {
+		dir := path[:strings.LastIndexByte(path, '/')]
+		counts[dir]++
+	}
+	if counts["res/layout"] == 0 {
+		t.Errorf("no layouts: %v", counts)
+	}
+	if counts["res/drawable-xxhdpi-v4"] == 0 {
+		t.Errorf("no drawables for xxhdpi: %v", counts)
+	}
+	if counts["res/drawable-mdpi-v4"] != 0 {
+		t.Errorf("got %d drawables for mdpi want none", counts["res/drawable-mdpi-v4"])
+	}
+	for path, id := range files {
+		if got, ok := tableFile.PathForID(id, config); !ok || got != path {
+			t.Errorf("%s: got %q, %v want %q", id, got, ok, path)
+			break
+		}
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	tableFile := newTestTableFile(
+		ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010001},
+		ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010002},
+		ResValue{Size: 8, DataType: TypeIntDec, Data: 42},
+
+		// cyclic references
+		ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010004},
+		ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010003},
+
+		// self reference
+		ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010005},
+
+		// dangling reference
+		ResValue{Size: 8, DataType: TypeReference, Data: 0x7F0100FF},
+	)
+
+	for _, id := range []ResID{0x7F010000, 0x7F010001, 0x7F010002} {
+		v, err := tableFile.ResolveReference(id, nil)
+		if err != nil {
+			t.Errorf("%s: got %v want no error", id, err)
+			continue
+		}
+		if want := (ResValue{Size: 8, DataType: TypeIntDec, Data: 42}); v != want {
+			t.Errorf("%s: got %+v want %+v", id, v, want)
+		}
+	}
+
+	for _, id := range []ResID{0x7F010003, 0x7F010004, 0x7F010005, 0x7F010006} {
+		if _, err := tableFile.ResolveReference(id, nil); err == nil {
+			t.Errorf("%s: want error, got nil", id)
+		}
+	}
+}
+
+func TestResolveReferenceTooDeep(t *testing.T) {
+	values := make([]ResValue, maxReferenceDepth+1)
+	for i := range values {
+		values[i] = ResValue{Size: 8, DataType: TypeReference, Data: 0x7F010000 + uint32(i) + 1}
+	}
+	values[len(values)-1] = ResValue{Size: 8, DataType: TypeIntDec, Data: 42}
+	tableFile := newTestTableFile(values...)
+
+	if _, err := tableFile.ResolveReference(0x7F010000, nil); err == nil {
+		t.Error("want error, got nil")
+	}
+	if _, err := tableFile.ResolveReference(0x7F010001, nil); err != nil {
+		t.Errorf("got %v want no error", err)
+	}
+}
+
+func TestFindBestConfig(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	const id = ResID(0x7F0B0000) // @string/abc_action_bar_home_description
+
+	cases := []struct {
+		language, country string
+		wantLanguage      [2]uint8
+		wantCountry       [2]uint8
+		want              string
+	}{
+		{"ja", "JP", [2]uint8{'j', 'a'}, [2]uint8{}, "ホームへ移動"},
+		{"fr", "CA", [2]uint8{'f', 'r'}, [2]uint8{'C', 'A'}, "Revenir à l'accueil"},
+		{"fr", "FR", [2]uint8{'f', 'r'}, [2]uint8{}, "Revenir à l'accueil"},
+		{"es", "US", [2]uint8{'e', 's'}, [2]uint8{'U', 'S'}, "Navegar a la página principal"},
+		{"es", "MX", [2]uint8{'e', 's'}, [2]uint8{}, "Ir a la pantalla de inicio"},
+		{"zh", "TW", [2]uint8{'z', 'h'}, [2]uint8{'T', 'W'}, "瀏覽首頁"},
+		{"xx", "", [2]uint8{}, [2]uint8{}, "Navigate home"},
+	}
+	for _, c := range cases {
+		want := &ResTableConfig{}
+		copy(want.Language[:], c.language)
+		copy(want.Country[:], c.country)
+		config, v, err := tableFile.FindBestConfig(id, want)
+		if err != nil {
+			t.Errorf("%s-%s: got %v want no error", c.language, c.country, err)
+			continue
+		}
+		if config.Language != c.wantLanguage || config.Country != c.wantCountry {
+			t.Errorf("%s-%s: got %q-%q want %q-%q", c.language, c.country, config.Language, config.Country, c.wantLanguage, c.wantCountry)
+		}
+		if got := tableFile.GetString(ResStringPoolRef(v.Data)); got != c.want {
+			t.Errorf("%s-%s: got %q want %q", c.language, c.country, got, c.want)
+		}
+	}
+
+	if _, _, err := tableFile.FindBestConfig(0x7F0BFFFF, &ResTableConfig{}); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestConfigsForID(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+
+	// @string/abc_action_bar_home_description is translated into many languages.
+	configs := tableFile.ConfigsForID(0x7F0B0000)
+	locales := make(map[string]bool)
+	for _, config := range configs {
+		locales[config.Locale()] = true
+	}
+	for _, locale := range []string{"", "ja", "fr", "fr-CA"} {
+		if !locales[locale] {
+			t.Errorf("locale %q is not found in %v", locale, locales)
+		}
+	}
+	if locales["xx"] {
+		t.Error("unexpected locale xx")
+	}
+
+	// @string/app_name is defined only in values/.
+	configs = tableFile.ConfigsForID(0x7F0B0027)
+	if len(configs) != 1 {
+		t.Fatalf("got %d configs want 1", len(configs))
+	}
+	if configs[0].Locale() != "" {
+		t.Errorf("got %q want default locale", configs[0].Locale())
+	}
+
+	if configs := tableFile.ConfigsForID(0x7F0BFFFF); len(configs) != 0 {
+		t.Errorf("got %v want empty", configs)
+	}
+	if configs := tableFile.ConfigsForID(0x10000000); len(configs) != 0 {
+		t.Errorf("got %v want empty", configs)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	cases := []struct {
+		config *ResTableConfig
+		want   string
+	}{
+		{&ResTableConfig{}, ""},
+		{&ResTableConfig{Language: [2]uint8{'j', 'a'}}, "ja"},
+		{&ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'U', 'S'}}, "en-US"},
+
+		// three-letter language "fil", packed in base 32.
+		{&ResTableConfig{Language: [2]uint8{0xAD, 0x05}}, "fil"},
+		{&ResTableConfig{Language: [2]uint8{0xAD, 0x05}, Country: [2]uint8{'P', 'H'}}, "fil-PH"},
+
+		// three-digit region "419", packed in base 32.
+		{&ResTableConfig{Language: [2]uint8{'e', 's'}, Country: [2]uint8{0xA4, 0x24}}, "es-419"},
+
+		// scripts and variants
+		{&ResTableConfig{Language: [2]uint8{'s', 'r'}, LocaleScript: [4]uint8{'L', 'a', 't', 'n'}}, "sr-Latn"},
+		{
+			&ResTableConfig{
+				Language:     [2]uint8{'z', 'h'},
+				Country:      [2]uint8{'C', 'N'},
+				LocaleScript: [4]uint8{'H', 'a', 'n', 's'},
+			},
+			"zh-Hans-CN",
+		},
+		{
+			&ResTableConfig{
+				Language:      [2]uint8{'c', 'a'},
+				Country:       [2]uint8{'E', 'S'},
+				LocaleVariant: [8]uint8{'v', 'a', 'l', 'e', 'n', 'c', 'i', 'a'},
+			},
+			"ca-ES-valencia",
+		},
+	}
+	for _, c := range cases {
+		if got := c.config.Locale(); got != c.want {
+			t.Errorf("got %q want %q", got, c.want)
+		}
+	}
+}
+
+func TestLocaleWithScript(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+
+	// values-b+sr+Latn
+	found := false
+	for _, config := range tableFile.ConfigsForID(0x7F0B0000) {
+		if config.Locale() == "sr-Latn" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("sr-Latn is not found")
+	}
+}
+
+func TestDensityBucket(t *testing.T) {
+	cases := []struct {
+		density uint16
+		want    string
+	}{
+		{DensityDefault, ""},
+		{DensityLow, "ldpi"},
+		{DensityMedium, "mdpi"},
+		{DensityTV, "tvdpi"},
+		{DensityHigh, "hdpi"},
+		{DensityXHigh, "xhdpi"},
+		{DensityXXHigh, "xxhdpi"},
+		{DensityXXXHigh, "xxxhdpi"},
+		{DensityAny, "anydpi"},
+		{DensityNone, "nodpi"},
+		{280, "280dpi"},
+	}
+	for _, c := range cases {
+		config := &ResTableConfig{Density: c.density}
+		if got := config.DensityBucket(); got != c.want {
+			t.Errorf("%d: got %q want %q", c.density, got, c.want)
+		}
+	}
+}
+
+func TestResTableConfigString(t *testing.T) {
+	cases := []struct {
+		config *ResTableConfig
+		want   string
+	}{
+		{
+			config: &ResTableConfig{},
+			want:   "",
+		},
+		{
+			config: &ResTableConfig{
+				Language:   [2]uint8{'e', 'n'},
+				Country:    [2]uint8{'U', 'S'},
+				Density:    DensityXHigh,
+				SDKVersion: 21,
+			},
+			want: "en-rUS-xhdpi-v21",
+		},
+		{
+			config: &ResTableConfig{
+				Mcc:         310,
+				Mnc:         260,
+				Language:    [2]uint8{'f', 'r'},
+				Orientation: 2,
+				Density:     DensityAny,
+				SDKVersion:  26,
+			},
+			want: "mcc310-mnc260-fr-land-anydpi-v26",
+		},
+		{
+			config: &ResTableConfig{
+				Language:              [2]uint8{'s', 'r'},
+				LocaleScript:          [4]uint8{'L', 'a', 't', 'n'},
+				SmallestScreenWidthDp: 600,
+				Orientation:           1,
+				UIMode:                UIModeNightYes,
+				Density:               280,
+			},
+			want: "b+sr+Latn-sw600dp-port-night-280dpi",
+		},
+	}
+	for _, c := range cases {
+		if got := c.config.String(); got != c.want {
+			t.Errorf("got %q want %q", got, c.want)
+		}
+	}
+}
+
+func TestTableFileStrings(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+
+	// @string/app_name
+	val, err := tableFile.GetResourceByName("@string/app_name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := ResStringPoolRef(val.Data)
+	if !tableFile.HasString(ref) {
+		t.Fatalf("HasString(%d): got false want true", ref)
+	}
+	if got := tableFile.GetString(ref); got != "My Application" {
+		t.Errorf("GetString(%d): got %q want My Application", ref, got)
+	}
+	if tableFile.HasString(0xFFFFFFFF) {
+		t.Error("HasString(0xFFFFFFFF): got true want false")
+	}
+
+	p := tableFile.Package(0x7F)
+	if p == nil {
+		t.Fatal("Package(0x7F): got nil")
+	}
+	if tableFile.Package(0x02) != nil {
+		t.Error("Package(0x02): got a package want nil")
+	}
+
+	// the type id of string is 0x0B
+	if got := p.GetTypeString(0x0B - 1); got != "string" {
+		t.Errorf("GetTypeString: got %q want string", got)
+	}
+	if p.HasTypeString(0xFFFF) {
+		t.Error("HasTypeString(0xFFFF): got true want false")
+	}
+
+	key := p.findEntry(0x0B, 0x0027, nil).Key.Key
+	if !p.HasKeyString(key) {
+		t.Fatalf("HasKeyString(%d): got false want true", key)
+	}
+	if got := p.GetKeyString(key); got != "app_name" {
+		t.Errorf("GetKeyString(%d): got %q want app_name", key, got)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	tableFile := newTestTableFile(
+		ResValue{Size: 8, DataType: TypeIntDec, Data: 0},
+		ResValue{Size: 8, DataType: TypeIntDec, Data: 1},
+		ResValue{Size: 8, DataType: TypeIntDec, Data: 2},
+	)
+	p := tableFile.tablePackages[0x7F]
+	p.TableTypes[0].Entries[1] = TableEntry{}
+
+	// 0x7F020000 is defined only in ja.
+	ja := ResTableConfig{Language: [2]uint8{'j', 'a'}}
+	p.TableTypes = append(p.TableTypes, &TableType{
+		Header: &ResTableType{ID: 0x02, EntryCount: 1, Config: ja},
+		Entries: []TableEntry{
+			{
+				Key:   &ResTableEntry{Size: 8},
+				Value: &ResValue{Size: 8, DataType: TypeIntDec, Data: 3},
+			},
+		},
+	})
+
+	cases := []struct {
+		config *ResTableConfig
+		ids    []ResID
+	}{
+		{&ResTableConfig{}, []ResID{0x7F010000, 0x7F010002}},
+		{&ja, []ResID{0x7F010000, 0x7F010002, 0x7F020000}},
+	}
+	for _, c := range cases {
+		var ids []ResID
+		tableFile.Entries(c.config)(func(id ResID, name string, value ResValue) bool {
+			ids = append(ids, id)
+			return true
+		})
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("%s: got %v want %v", c.config, ids, c.ids)
+		}
+	}
+
+	// stop the iteration
+	count := 0
+	tableFile.Entries(&ja)(func(id ResID, name string, value ResValue) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("got %d entries want 1", count)
+	}
+}
+
+func TestEntriesNames(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	found := false
+	tableFile.Entries(nil)(func(id ResID, name string, value ResValue) bool {
+		if id != 0x7F0B0027 {
+			return true
+		}
+		found = true
+		if name != "com.shogo82148.androidbinary.myapplication:string/app_name" {
+			t.Errorf("unexpected name: %q", name)
+		}
+		if value.DataType != TypeString {
+			t.Errorf("unexpected data type: %v", value.DataType)
+		}
+		return false
+	})
+	if !found {
+		t.Error("app_name is not found")
+	}
+}
+
+func TestGetBag(t *testing.T) {
+	t.Run("string-array", func(t *testing.T) {
+		f, err := os.Open("testdata/resources.arsc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		tableFile, err := NewTableFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// @array/compus_names
+		cases := []struct {
+			config *ResTableConfig
+			first  string
+			second string
+		}{
+			{&ResTableConfig{}, "S", "SSW"},
+			{&ResTableConfig{Language: [2]uint8{'j', 'a'}}, "南", "南南西"},
+		}
+		for _, c := range cases {
+			bag, err := tableFile.GetBag(0x7F050000, c.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bag) != 16 {
+				t.Errorf("got %d items want 16", len(bag))
+			}
+			if got := tableFile.GetString(ResStringPoolRef(bag[0x02000000].Data)); got != c.first {
+				t.Errorf("got %q want %q", got, c.first)
+			}
+			if got := tableFile.GetString(ResStringPoolRef(bag[0x02000001].Data)); got != c.second {
+				t.Errorf("got %q want %q", got, c.second)
+			}
+		}
+	})
+
+	t.Run("style", func(t *testing.T) {
+		tableFile := loadMyApplicationTestData(t)
+
+		// @style/AppTheme
+		bag, err := tableFile.GetBag(0x7F0C0005, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[ResID]ResValue{
+			0x7F02004B: {Size: 8, DataType: TypeReference, Data: 0x7F040026}, // colorAccent
+			0x7F020052: {Size: 8, DataType: TypeReference, Data: 0x7F040027}, // colorPrimary
+			0x7F020053: {Size: 8, DataType: TypeReference, Data: 0x7F040028}, // colorPrimaryDark
+		}
+		for name, v := range want {
+			if bag[name] != v {
+				t.Errorf("%s: got %v want %v", name, bag[name], v)
+			}
+		}
+		// includes the items of the parents
+		if len(bag) <= len(want) {
+			t.Errorf("got %d items want more than %d", len(bag), len(want))
+		}
+	})
+
+	t.Run("not a bag", func(t *testing.T) {
+		tableFile := loadMyApplicationTestData(t)
+		if _, err := tableFile.GetBag(0x7F0B0027, nil); err == nil {
+			t.Error("got no error want an error")
+		}
+	})
+}
+
+func TestNewTableFileBroken(t *testing.T) {
+	inputs := [][]uint8{
+		// empty
+		{},
+
+		// a chunk of zero size, which never advances the offset
+		{
+			0x02, 0x00, 0x0C, 0x00, 0x14, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	for _, input := range inputs {
+		if _, err := NewTableFile(bytes.NewReader(input)); err == nil {
+			t.Errorf("%v: got no error want an error", input)
+		}
+	}
+}
+
+// newTestSplitTableFile returns testdata/resources.arsc with a copy of its package
+// whose id is 0x80, as the table of a split APK has.
+func newTestSplitTableFile(t *testing.T) *TableFile {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// find the package chunk.
+	offset := int(binary.LittleEndian.Uint16(data[2:]))
+	for ChunkType(binary.LittleEndian.Uint16(data[offset:])) != ResTablePackageType {
+		offset += int(binary.LittleEndian.Uint32(data[offset+4:]))
+	}
+	size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+	pkg := append([]byte(nil), data[offset:offset+size]...)
+
+	// rewrite its id and name.
+	binary.LittleEndian.PutUint32(pkg[8:], 0x80)
+	name := make([]byte, 256)
+	for i, c := range utf16.Encode([]rune("com.example.split")) {
+		binary.LittleEndian.PutUint16(name[2*i:], c)
+	}
+	copy(pkg[12:], name)
+
+	data = append(data, pkg...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[8:], binary.LittleEndian.Uint32(data[8:])+1)
+
+	tableFile, err := NewTableFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tableFile
+}
+
+func TestPackages(t *testing.T) {
+	tableFile := newTestSplitTableFile(t)
+	want := []PackageInfo{
+		{ID: 0x7F, Name: "net.sorablue.shogo.FWMeasure"},
+		{ID: 0x80, Name: "com.example.split"},
+	}
+	if got := tableFile.Packages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+
+	// the highest byte of the resource id selects the package.
+	for _, id := range []ResID{0x7F040000, 0x80040000} {
+		val, err := tableFile.GetResource(id, &ResTableConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val != "FireworksMeasure" {
+			t.Errorf("%s: got %v want FireworksMeasure", id, val)
+		}
+	}
+	if _, err := tableFile.GetResource(0x81040000, &ResTableConfig{}); err == nil {
+		t.Error("want error")
+	}
+
+	// names with the package refer to the package, and the ones without refer to the application package.
+	if id, ok := tableFile.findResID("com.example.split", "string", "app_name"); !ok || id != 0x80040000 {
+		t.Errorf("got %s, %v want 0x80040000", id, ok)
+	}
+	if id, ok := tableFile.findResID("", "string", "app_name"); !ok || id != 0x7F040000 {
+		t.Errorf("got %s, %v want 0x7F040000", id, ok)
+	}
+}
+
+func TestOverlayableAndStagedAlias(t *testing.T) {
+	tableFile := loadTestData()
+	var name, actor [256]uint16
+	copy(name[:], utf16.Encode([]rune("ThemeResources")))
+	copy(actor[:], utf16.Encode([]rune("overlay://theme")))
+	pkg := tableFile.tablePackages[0x7F]
+	pkg.Overlayables = []*TableOverlayable{
+		{
+			Header: &ResTableOverlayable{Name: name, Actor: actor},
+			Policies: []*TableOverlayablePolicy{
+				{
+					Header: &ResTableOverlayablePolicy{Flags: PolicyPublic | PolicySignature},
+					IDs:    []ResID{0x7F040000},
+				},
+			},
+		},
+	}
+	pkg.StagedAliases = []TableStagedAlias{{StagedID: 0x7F040001, FinalizedID: 0x7F040000}}
+
+	var buf bytes.Buffer
+	if err := tableFile.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// append a chunk of an unknown type to the package, which is the last chunk of the table.
+	data := buf.Bytes()
+	data = append(data, 0x07, 0x02, 0x08, 0x00, 0x0C, 0x00, 0x00, 0x00, 0xDE, 0xAD, 0xBE, 0xEF)
+	poolSize := binary.LittleEndian.Uint32(data[12+4:])
+	pkgOffset := 12 + poolSize
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[pkgOffset+4:], uint32(len(data))-pkgOffset)
+
+	tableFile, err := NewTableFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := tableFile.GetResource(0x7F040000, &ResTableConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != "FireworksMeasure" {
+		t.Errorf("got %v want FireworksMeasure", val)
+	}
+
+	pkg = tableFile.tablePackages[0x7F]
+	if len(pkg.Overlayables) != 1 {
+		t.Fatalf("got %d overlayables want 1", len(pkg.Overlayables))
+	}
+	o := pkg.Overlayables[0]
+	if o.Name() != "ThemeResources" || o.Actor() != "overlay://theme" {
+		t.Errorf("got %q, %q want ThemeResources, overlay://theme", o.Name(), o.Actor())
+	}
+	if len(o.Policies) != 1 {
+		t.Fatalf("got %d policies want 1", len(o.Policies))
+	}
+	if got := o.Policies[0].Header.Flags; got != PolicyPublic|PolicySignature {
+		t.Errorf("got flags 0x%08X want 0x%08X", got, PolicyPublic|PolicySignature)
+	}
+	if got := o.Policies[0].IDs; !reflect.DeepEqual(got, []ResID{0x7F040000}) {
+		t.Errorf("got %v want [0x7F040000]", got)
+	}
+	if want := []TableStagedAlias{{StagedID: 0x7F040001, FinalizedID: 0x7F040000}}; !reflect.DeepEqual(pkg.StagedAliases, want) {
+		t.Errorf("got %v want %v", pkg.StagedAliases, want)
+	}
+}
+
+func TestTypeSpecFlags(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// declare @0x7F0B0000 as public.
+	offset := int(binary.LittleEndian.Uint16(data[2:]))
+	for ChunkType(binary.LittleEndian.Uint16(data[offset:])) != ResTablePackageType {
+		offset += int(binary.LittleEndian.Uint32(data[offset+4:]))
+	}
+	offset += int(binary.LittleEndian.Uint16(data[offset+2:]))
+	for ChunkType(binary.LittleEndian.Uint16(data[offset:])) != ResTableTypeSpecType || data[offset+8] != 0x0B {
+		offset += int(binary.LittleEndian.Uint32(data[offset+4:]))
+	}
+	flags := data[offset+int(binary.LittleEndian.Uint16(data[offset+2:])):]
+	binary.LittleEndian.PutUint32(flags, binary.LittleEndian.Uint32(flags)|SpecFlagPublic)
+
+	tableFile, err := NewTableFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		id     ResID
+		public bool
+		axes   uint32
+	}{
+		{0x7F0B0000, true, ConfigLocale}, // translated to ja and fr
+		{0x7F0B0001, false, ConfigLocale},
+		{0x7F0B0027, false, 0},
+		{0x7F0BFFFF, false, 0}, // out of range
+		{0x7E0B0000, false, 0}, // no package
+	}
+	for _, c := range cases {
+		if got := tableFile.IsPublic(c.id); got != c.public {
+			t.Errorf("%s: got public %v want %v", c.id, got, c.public)
+		}
+		if got := tableFile.ConfigAxes(c.id); got != c.axes {
+			t.Errorf("%s: got axes %#x want %#x", c.id, got, c.axes)
+		}
+	}
+}
+
+func TestReadResTableConfig(t *testing.T) {
+	// the configuration of old aapt has only 28 bytes. the following bytes are not a part of it.
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = 0xff
+	}
+	binary.LittleEndian.PutUint32(data, 28)
+	var config ResTableConfig
+	if err := readResTableConfig(&config, data); err != nil {
+		t.Fatal(err)
+	}
+	if config.SDKVersion != 0xffff || config.ScreenLayout != 0 || config.ScreenLayout2 != 0 {
+		t.Errorf("unexpected config: %#v", config)
+	}
+
+	// the extended screen config is at the offset 48.
+	data = make([]byte, 64)
+	binary.LittleEndian.PutUint32(data, 64)
+	data[48] = byte(ScreenRoundYes)
+	data[49] = byte(WideColorGamutYes | HDRNo)
+	if err := readResTableConfig(&config, data); err != nil {
+		t.Fatal(err)
+	}
+	if config.ScreenLayout2 != ScreenRoundYes || config.ColorMode != WideColorGamutYes|HDRNo {
+		t.Errorf("unexpected config: %#v", config)
+	}
+	if got := config.String(); got != "round-widecg-lowdr" {
+		t.Errorf("got %q want round-widecg-lowdr", got)
+	}
+}
+
+func TestFindBestConfigExtended(t *testing.T) {
+	configs := []ResTableConfig{
+		{},
+		{UIMode: UIModeNightYes},
+		{SmallestScreenWidthDp: 600},
+		{ScreenLayout2: ScreenRoundYes},
+		{ColorMode: HDRYes},
+	}
+	p := &TablePackage{Header: ResTablePackage{ID: 0x7F}}
+	for i, config := range configs {
+		p.TableTypes = append(p.TableTypes, &TableType{
+			Header: &ResTableType{ID: 0x01, EntryCount: 1, Config: config},
+			Entries: []TableEntry{
+				{
+					Key:   &ResTableEntry{Size: 8},
+					Value: &ResValue{Size: 8, DataType: TypeIntDec, Data: uint32(i)},
+				},
+			},
+		})
+	}
+	tableFile := &TableFile{tablePackages: map[uint32]*TablePackage{0x7F: p}}
+
+	cases := []struct {
+		want     string
+		settings ResTableConfig
+	}{
+		{"", ResTableConfig{UIMode: UIModeNightNo, SmallestScreenWidthDp: 360}},
+		{"night", ResTableConfig{UIMode: UIModeNightYes, SmallestScreenWidthDp: 360}},
+		{"sw600dp", ResTableConfig{UIMode: UIModeNightNo, SmallestScreenWidthDp: 720}},
+		{"sw600dp", ResTableConfig{UIMode: UIModeNightYes, SmallestScreenWidthDp: 720}}, // sw600dp has priority over night
+		{"round", ResTableConfig{ScreenLayout2: ScreenRoundYes}},
+		{"", ResTableConfig{ScreenLayout2: ScreenRoundNo}},
+		{"highdr", ResTableConfig{ColorMode: HDRYes}},
+		{"", ResTableConfig{ColorMode: HDRNo}},
+	}
+	for _, c := range cases {
+		config, _, err := tableFile.FindBestConfig(0x7F010000, &c.settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := config.String(); got != c.want {
+			t.Errorf("%#v: got %q want %q", c.settings, got, c.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	enUS := &ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'U', 'S'}, Density: DensityXXHigh, SDKVersion: 30}
+	cases := []struct {
+		config ResTableConfig
+		device *ResTableConfig
+		want   bool
+	}{
+		// locale fallback
+		{ResTableConfig{}, enUS, true},
+		{ResTableConfig{Language: [2]uint8{'e', 'n'}}, enUS, true},
+		{ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'U', 'S'}}, enUS, true},
+		{ResTableConfig{Language: [2]uint8{'e', 'n'}, Country: [2]uint8{'G', 'B'}}, enUS, false},
+		{ResTableConfig{Language: [2]uint8{'j', 'a'}}, enUS, false},
+
+		// any density is usable
+		{ResTableConfig{Density: DensityHigh}, enUS, true},
+		{ResTableConfig{Density: DensityXXXHigh}, enUS, true},
+
+		// version
+		{ResTableConfig{SDKVersion: 21}, enUS, true},
+		{ResTableConfig{SDKVersion: 31}, enUS, false},
+
+		// nil device matches everything
+		{ResTableConfig{Language: [2]uint8{'j', 'a'}}, nil, true},
+	}
+	for _, c := range cases {
+		if got := c.config.Match(c.device); got != c.want {
+			t.Errorf("%q: got %v want %v", c.config.String(), got, c.want)
+		}
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	const id = ResID(0x7F0B0000) // @string/abc_action_bar_home_description
+
+	defaultConfig := tableFile.DefaultConfig()
+	if defaultConfig.Size == 0 {
+		t.Error("got zero size")
+	}
+	if got := defaultConfig.String(); got != "" {
+		t.Errorf("got %q want no qualifiers", got)
+	}
+
+	config, v, err := tableFile.FindBestConfig(id, defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *config != *defaultConfig {
+		t.Errorf("got %+v want %+v", *config, *defaultConfig)
+	}
+	if got := tableFile.GetString(ResStringPoolRef(v.Data)); got != "Navigate home" {
+		t.Errorf("got %q want %q", got, "Navigate home")
+	}
+
+	found := false
+	for _, config := range tableFile.ConfigsForID(id) {
+		if *config == *defaultConfig {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("the default config is not found in ConfigsForID")
+	}
+
+	// the table without the default configuration.
+	if got := new(TableFile).DefaultConfig(); *got != (ResTableConfig{}) {
+		t.Errorf("got %+v want zero value", *got)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tableFile := loadMyApplicationTestData(t)
+	cases := []struct {
+		id   ResID
+		want string
+	}{
+		{0x7F020052, "attr"},
+		{0x7F040026, "color"},
+		{0x7F0B0027, "string"},
+		{0x7F0C0005, "style"},
+		{0x7F0BFFFF, "string"}, // the entry needn't exist.
+	}
+	for _, c := range cases {
+		got, ok := tableFile.TypeName(c.id)
+		if !ok {
+			t.Errorf("%s: want ok", c.id)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q want %q", c.id, got, c.want)
+		}
+	}
+
+	for _, id := range []ResID{0x7FFF0000, 0x7F000000, 0x01010000} {
+		if got, ok := tableFile.TypeName(id); ok {
+			t.Errorf("%s: got %q want not ok", id, got)
+		}
+	}
+}
+
+func TestResTableConfigKey(t *testing.T) {
+	// the same qualifiers written by the different versions of aapt.
+	a := &ResTableConfig{Size: 48, Language: [2]uint8{'j', 'a'}, Density: 480}
+	b := &ResTableConfig{Size: 64, Language: [2]uint8{'j', 'a'}, Density: 480, InputPad0: 1, ScreenConfigPad2: 2}
+	if a.Key() != b.Key() {
+		t.Errorf("got %q and %q want the same keys", a.Key(), b.Key())
+	}
+
+	cache := map[string]string{a.Key(): "ja-xxhdpi"}
+	if got := cache[b.Key()]; got != "ja-xxhdpi" {
+		t.Errorf("got %q want ja-xxhdpi", got)
+	}
+
+	// the different qualifiers.
+	for _, c := range []*ResTableConfig{
+		{Language: [2]uint8{'j', 'a'}},
+		{Language: [2]uint8{'j', 'a'}, Density: 320},
+		{Language: [2]uint8{'j', 'a'}, Density: 480, SDKVersion: 21},
+		{},
+		nil,
+	} {
+		if c.Key() == a.Key() {
+			t.Errorf("%+v: got the same key as %+v", c, a)
+		}
+	}
+	if (*ResTableConfig)(nil).Key() == (&ResTableConfig{}).Key() {
+		t.Error("nil and zero value have the same key")
+	}
+}
@@ -0,0 +1,28 @@
+#!/bin/sh
+
+# update.sh regenerates the outputs of `aapt2 dump xmltree` that aapt_test.go compares with.
+# aapt2 is in the build-tools of Android SDK.
+
+ROOT=$(cd "$(dirname "$0")" && pwd)
+TESTDATA=$(dirname "$ROOT")
+
+set -uex
+
+TMP=$(mktemp -d)
+trap 'rm -rf "$TMP"' EXIT
+
+dump() {
+    # aapt2 reads the files in APKs, so pack them into a temporary one.
+    rm -rf "$TMP/apk" "$TMP/app.apk"
+    mkdir "$TMP/apk"
+    cp "$1" "$TMP/apk/AndroidManifest.xml"
+    if [ -f "$(dirname "$1")/resources.arsc" ]; then
+        cp "$(dirname "$1")/resources.arsc" "$TMP/apk/resources.arsc"
+    fi
+    (cd "$TMP/apk" && zip -r "$TMP/app.apk" .)
+    aapt2 dump xmltree --file AndroidManifest.xml "$TMP/app.apk" > "$ROOT/$2"
+}
+
+dump "$TESTDATA/AndroidManifest.xml" AndroidManifest.xmltree
+dump "$TESTDATA/MyApplication/AndroidManifest.xml" MyApplication.xmltree
+aapt2 dump xmltree --file AndroidManifest.xml "$(dirname "$TESTDATA")/apk/testdata/helloworld.apk" > "$ROOT/helloworld.xmltree"
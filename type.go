@@ -8,46 +8,108 @@ import (
 )
 
 type injector interface {
-	inject(table *TableFile, config *ResTableConfig)
+	// inject ties table and config to the value.
+	// If strict is true, it returns an error if the value is a reference that is dangling in table.
+	inject(table *TableFile, config *ResTableConfig, strict bool) error
 }
 
 var injectorType = reflect.TypeOf((*injector)(nil)).Elem()
 
-func inject(val reflect.Value, table *TableFile, config *ResTableConfig) {
+// inject ties table and config to the values that implement injector in val.
+// It walks pointers, interfaces, structs (including embedded ones), slices, arrays and map values.
+// Other kinds of values are ignored.
+// If strict is true, it checks the references, injects into all the values even if some fail,
+// and returns the first error.
+func inject(val reflect.Value, table *TableFile, config *ResTableConfig, strict bool) error {
+	if val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		elem := val.Elem()
+		if elem.Kind() != reflect.Ptr && val.CanSet() {
+			// the value in the interface is not addressable. copy it and inject into the copy.
+			v := reflect.New(elem.Type()).Elem()
+			v.Set(elem)
+			err := inject(v, table, config, strict)
+			val.Set(v)
+			return err
+		}
+		val = elem
+	}
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
-			return
+			return nil
 		}
 		val = val.Elem()
 	}
 	if val.CanInterface() && val.Type().Implements(injectorType) {
-		val.Interface().(injector).inject(table, config)
-		return
+		return val.Interface().(injector).inject(table, config, strict)
 	}
 	if val.CanAddr() {
 		pv := val.Addr()
 		if pv.CanInterface() && pv.Type().Implements(injectorType) {
-			pv.Interface().(injector).inject(table, config)
-			return
+			return pv.Interface().(injector).inject(table, config, strict)
 		}
 	}
 
+	var firstErr error
 	switch val.Kind() {
 	default:
 		// ignore other types
-		return
+		return nil
 	case reflect.Slice, reflect.Array:
 		l := val.Len()
 		for i := 0; i < l; i++ {
-			inject(val.Index(i), table, config)
+			if err := inject(val.Index(i), table, config, strict); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	case reflect.Map:
+		if !val.CanInterface() {
+			// unexported fields can not be modified
+			return nil
+		}
+		iter := val.MapRange()
+		for iter.Next() {
+			// map elements are not addressable. copy them and inject into the copies.
+			v := reflect.New(val.Type().Elem()).Elem()
+			v.Set(iter.Value())
+			if err := inject(v, table, config, strict); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			val.SetMapIndex(iter.Key(), v)
 		}
-		return
 	case reflect.Struct:
 		l := val.NumField()
 		for i := 0; i < l; i++ {
-			inject(val.Field(i), table, config)
+			if err := inject(val.Field(i), table, config, strict); err != nil && firstErr == nil {
+				firstErr = err
+			}
 		}
 	}
+	return firstErr
+}
+
+// unresolvedReferenceError returns the error of resolving the reference value.
+func unresolvedReferenceError(value string, err error) error {
+	return fmt.Errorf("androidbinary: failed to resolve %s: %w", value, err)
+}
+
+// checkReference returns an error if value is a reference that is dangling in table,
+// i.e. the package or the entry is not found for config.
+// The references to the entries of other types, e.g. styles, are not errors.
+func checkReference(value string, table *TableFile, config *ResTableConfig) error {
+	if !IsResID(value) {
+		return nil
+	}
+	id, err := ParseResID(value)
+	if err != nil {
+		return unresolvedReferenceError(value, err)
+	}
+	if _, err := table.getEntry(id, config); err != nil {
+		return unresolvedReferenceError(value, err)
+	}
+	return nil
 }
 
 // Bool is a boolean value in XML file.
@@ -76,9 +138,13 @@ func (v Bool) WithResTableConfig(config *ResTableConfig) Bool {
 	}
 }
 
-func (v *Bool) inject(table *TableFile, config *ResTableConfig) {
+func (v *Bool) inject(table *TableFile, config *ResTableConfig, strict bool) error {
 	v.table = table
 	v.config = config
+	if !strict {
+		return nil
+	}
+	return checkReference(v.value, table, config)
 }
 
 // SetBool sets a boolean value.
@@ -171,9 +237,13 @@ func (v Int32) WithResTableConfig(config *ResTableConfig) Bool {
 	}
 }
 
-func (v *Int32) inject(table *TableFile, config *ResTableConfig) {
+func (v *Int32) inject(table *TableFile, config *ResTableConfig, strict bool) error {
 	v.table = table
 	v.config = config
+	if !strict {
+		return nil
+	}
+	return checkReference(v.value, table, config)
 }
 
 // SetInt32 sets an integer value.
@@ -267,9 +337,13 @@ func (v String) WithResTableConfig(config *ResTableConfig) String {
 	}
 }
 
-func (v *String) inject(table *TableFile, config *ResTableConfig) {
+func (v *String) inject(table *TableFile, config *ResTableConfig, strict bool) error {
 	v.table = table
 	v.config = config
+	if !strict {
+		return nil
+	}
+	return checkReference(v.value, table, config)
 }
 
 // SetString sets a string value.
@@ -298,6 +372,8 @@ func (v String) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
 
 // String returns the string value.
 // It resolves the reference if needed.
+// The references to the resources that are not strings, e.g. android:theme="@0x7F0C0005" to a style,
+// are returned as they are.
 func (v String) String() (string, error) {
 	if !IsResID(v.value) {
 		return v.value, nil
@@ -312,11 +388,36 @@ func (v String) String() (string, error) {
 	}
 	ret, ok := value.(string)
 	if !ok {
-		return "", fmt.Errorf("invalid type: %T", value)
+		return v.value, nil
 	}
 	return ret, nil
 }
 
+// Raw returns the value in the XML file without resolving the reference.
+// References are formatted like "@0x7F040000".
+func (v String) Raw() string {
+	return v.value
+}
+
+// ResID returns the resource id if the value is a reference.
+func (v String) ResID() (ResID, bool) {
+	if !IsResID(v.value) {
+		return 0, false
+	}
+	id, err := ParseResID(v.value)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// Resolve returns the string value resolved with table and config
+// instead of the ones stored by Decode.
+// It is useful to resolve the same value against multiple configurations.
+func (v String) Resolve(table *TableFile, config *ResTableConfig) (string, error) {
+	return v.WithTableFile(table).WithResTableConfig(config).String()
+}
+
 // MustString is same as String, but it panics if it fails to parse the value.
 func (v String) MustString() string {
 	ret, err := v.String()
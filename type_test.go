@@ -3,6 +3,7 @@ package androidbinary
 import (
 	"encoding/xml"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -201,3 +202,107 @@ func TestString(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeNestedReferences(t *testing.T) {
+	type myMetaData struct {
+		Name  string `xml:"http://schemas.android.com/apk/res/android name,attr"`
+		Value String `xml:"http://schemas.android.com/apk/res/android value,attr"`
+	}
+	type myLabel struct {
+		Label String `xml:"http://schemas.android.com/apk/res/android label,attr"`
+	}
+	type myXMLApplication struct {
+		myLabel
+		MetaData []*myMetaData `xml:"meta-data"`
+	}
+	type myXMLManifest struct {
+		XMLName     xml.Name          `xml:"manifest"`
+		Application *myXMLApplication `xml:"application"`
+	}
+
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	table := loadMyApplicationTestData(t)
+
+	xmlManifest := new(myXMLManifest)
+	if err := xmlFile.Decode(xmlManifest, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	app := xmlManifest.Application
+
+	// embedded struct
+	if got := app.Label.MustString(); got != "My Application" {
+		t.Errorf("Label: got %q want My Application", got)
+	}
+
+	// slices of pointers
+	found := false
+	for _, data := range app.MetaData {
+		if data.Name != "string_test_arsc" {
+			continue
+		}
+		found = true
+		if got := data.Value.MustString(); got != "foobar" {
+			t.Errorf("%s: got %q want foobar", data.Name, got)
+		}
+	}
+	if !found {
+		t.Error("string_test_arsc is not found")
+	}
+
+	// maps and interfaces
+	ref := String{}
+	ref.SetResID(0x7F0B002A)
+	m := map[string]String{"ref": ref}
+	var i interface{} = ref
+	s := struct {
+		M map[string]String
+		I interface{}
+	}{m, i}
+	inject(reflect.ValueOf(&s), table, nil, false)
+	if got := s.M["ref"].MustString(); got != "foobar" {
+		t.Errorf("map: got %q want foobar", got)
+	}
+	if got := s.I.(String).MustString(); got != "foobar" {
+		t.Errorf("interface: got %q want foobar", got)
+	}
+}
+
+func TestStringResolve(t *testing.T) {
+	table := loadMyApplicationTestData(t)
+
+	var v String
+	v.SetResID(0x7F0B0000)
+	if got := v.Raw(); got != "@0x7F0B0000" {
+		t.Errorf("Raw: got %q want @0x7F0B0000", got)
+	}
+	if id, ok := v.ResID(); !ok || id != 0x7F0B0000 {
+		t.Errorf("ResID: got %v, %v want 0x7F0B0000, true", id, ok)
+	}
+
+	cases := []struct {
+		config *ResTableConfig
+		want   string
+	}{
+		{nil, "Navigate home"},
+		{&ResTableConfig{Language: [2]uint8{'j', 'a'}}, "ホームへ移動"},
+		{&ResTableConfig{Language: [2]uint8{'f', 'r'}}, "Revenir à l'accueil"},
+	}
+	for _, c := range cases {
+		got, err := v.Resolve(table, c.config)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("got %q want %q", got, c.want)
+		}
+	}
+
+	v.SetString("immediate")
+	if _, ok := v.ResID(); ok {
+		t.Error("ResID: got true want false")
+	}
+	if got, err := v.Resolve(nil, nil); err != nil || got != "immediate" {
+		t.Errorf("got %q, %v want immediate", got, err)
+	}
+}
@@ -0,0 +1,272 @@
+package androidbinary
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// typedTag is a parsed `androidbinary:"name,kind"` tag. See Decode for the grammar.
+type typedTag struct {
+	attr string
+	kind string
+}
+
+func parseTypedTag(tag string, typ reflect.Type) (typedTag, error) {
+	t := typedTag{attr: tag}
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		t.attr, t.kind = tag[:i], tag[i+1:]
+	}
+	if t.attr == "" {
+		return t, fmt.Errorf("androidbinary: invalid tag %q: empty attribute name", tag)
+	}
+
+	var want string
+	switch {
+	case typ == reflect.TypeOf(ResID(0)):
+		want = "resid"
+	case typ == reflect.TypeOf(ResValue{}):
+		want = "value"
+	default:
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			want = "int"
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			want = "uint"
+		case reflect.Bool:
+			want = "bool"
+		case reflect.Float32, reflect.Float64:
+			want = "float"
+		case reflect.String:
+			want = "string"
+		default:
+			return t, fmt.Errorf("androidbinary: invalid tag %q: unsupported type %s", tag, typ)
+		}
+	}
+	if t.kind == "" {
+		t.kind = want
+	}
+	if t.kind != want {
+		return t, fmt.Errorf("androidbinary: invalid tag %q: %s is not compatible with %s", tag, t.kind, typ)
+	}
+	return t, nil
+}
+
+// typedDecoder sets the fields tagged with `androidbinary:"..."`.
+type typedDecoder struct {
+	table  *TableFile
+	config *ResTableConfig
+
+	// the first error of the values, e.g. a reference that can't be resolved.
+	err error
+}
+
+// decode sets the tagged fields in val from elem and its descendants.
+// It returns an error if a tag is invalid; the errors of the values are recorded in d.err.
+func (d *typedDecoder) decode(val reflect.Value, elem *XMLElement) error {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			// unexported fields can not be modified
+			continue
+		}
+		fv := val.Field(i)
+		if tag, ok := field.Tag.Lookup("androidbinary"); ok {
+			t, err := parseTypedTag(tag, field.Type)
+			if err != nil {
+				return err
+			}
+			if err := d.set(fv, t, elem); err != nil && d.err == nil {
+				d.err = err
+			}
+			continue
+		}
+
+		name, ok := xmlElementName(field)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			// embedded structs without tags are in the same element.
+			if err := d.decode(fv, elem); err != nil {
+				return err
+			}
+			continue
+		}
+		var children []*XMLElement
+		for _, child := range elem.Children {
+			if localName(child.Name) == name {
+				children = append(children, child)
+			}
+		}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len() && j < len(children); j++ {
+				if err := d.decode(fv.Index(j), children[j]); err != nil {
+					return err
+				}
+			}
+		} else if len(children) > 0 {
+			if err := d.decode(fv, children[0]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// xmlElementName returns the local name of the element that encoding/xml maps the field to.
+// It returns an empty name for the embedded structs without names, and false for the attributes and the others.
+func xmlElementName(field reflect.StructField) (string, bool) {
+	if field.Type == reflect.TypeOf(xml.Name{}) {
+		return "", false
+	}
+	tag := field.Tag.Get("xml")
+	if tag == "-" {
+		return "", false
+	}
+	name := tag
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		name = tag[:i]
+		for _, flag := range strings.Split(tag[i+1:], ",") {
+			if flag != "" && flag != "omitempty" {
+				// attr, chardata, innerxml, comment and any
+				return "", false
+			}
+		}
+	}
+	if i := strings.LastIndexByte(name, ' '); i >= 0 {
+		name = name[i+1:]
+	}
+	if strings.Contains(name, ">") {
+		return "", false
+	}
+	if name == "" && !field.Anonymous {
+		name = field.Name
+	}
+	return name, true
+}
+
+// localName returns the name without the namespace prefix.
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func (d *typedDecoder) set(fv reflect.Value, t typedTag, elem *XMLElement) error {
+	index := elem.attrIndex(t.attr)
+	if index < 0 || index >= len(elem.rawAttrs) {
+		return nil
+	}
+	raw := elem.rawAttrs[index]
+
+	switch t.kind {
+	case "value":
+		fv.Set(reflect.ValueOf(raw.TypedValue))
+		return nil
+	case "resid":
+		switch raw.TypedValue.DataType {
+		case TypeReference, TypeDynamicReference, TypeAttribute, TypeDynamicAttribute:
+			fv.SetUint(uint64(raw.TypedValue.Data))
+			return nil
+		}
+		return fmt.Errorf("androidbinary: %s of %s is not a reference", t.attr, elem.Name)
+	case "string":
+		s, err := elem.stringAttr(t.attr, d.table, d.config)
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+		return nil
+	}
+
+	v, _, err := elem.attrValue(t.attr, d.table, d.config)
+	if err != nil {
+		return err
+	}
+	invalid := fmt.Errorf("androidbinary: %s of %s is not %s: %v", t.attr, elem.Name, t.kind, v)
+	switch t.kind {
+	case "int":
+		var n int64
+		switch v := v.(type) {
+		case int:
+			n = int64(v)
+		case uint32:
+			n = int64(v)
+		case string:
+			if n, err = strconv.ParseInt(v, 0, 64); err != nil {
+				return invalid
+			}
+		default:
+			return invalid
+		}
+		if fv.OverflowInt(n) {
+			return invalid
+		}
+		fv.SetInt(n)
+	case "uint":
+		var n uint64
+		switch v := v.(type) {
+		case int:
+			if v < 0 {
+				return invalid
+			}
+			n = uint64(v)
+		case uint32:
+			n = uint64(v)
+		case string:
+			if n, err = strconv.ParseUint(v, 0, 64); err != nil {
+				return invalid
+			}
+		default:
+			return invalid
+		}
+		if fv.OverflowUint(n) {
+			return invalid
+		}
+		fv.SetUint(n)
+	case "bool":
+		switch v := v.(type) {
+		case bool:
+			fv.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return invalid
+			}
+			fv.SetBool(b)
+		default:
+			return invalid
+		}
+	case "float":
+		switch v := v.(type) {
+		case float32:
+			fv.SetFloat(float64(v))
+		case int:
+			fv.SetFloat(float64(v))
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return invalid
+			}
+			fv.SetFloat(f)
+		default:
+			return invalid
+		}
+	}
+	return nil
+}
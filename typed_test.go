@@ -0,0 +1,113 @@
+package androidbinary
+
+import (
+	"testing"
+)
+
+type typedTestMetaData struct {
+	Name  string   `androidbinary:"android:name"`
+	Value ResValue `androidbinary:"android:value,value"`
+	Int   int      `androidbinary:"android:value"`
+	Bool  bool     `androidbinary:"android:value,bool"`
+}
+
+type typedTestManifest struct {
+	Package     string `androidbinary:"package"`
+	VersionCode int32  `androidbinary:"android:versionCode,int"`
+	SDK         struct {
+		Min    int   `androidbinary:"android:minSdkVersion"`
+		Target uint8 `androidbinary:"android:targetSdkVersion"`
+	} `xml:"uses-sdk"`
+	App struct {
+		Label      string              `androidbinary:"android:label"`
+		Theme      ResID               `androidbinary:"android:theme"`
+		Debuggable bool                `androidbinary:"android:debuggable"`
+		MetaData   []typedTestMetaData `xml:"meta-data"`
+	} `xml:"application"`
+}
+
+func TestDecodeTyped(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	table := loadMyApplicationTestData(t)
+
+	var m typedTestManifest
+	if err := xmlFile.Decode(&m, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m.Package != "com.shogo82148.androidbinary.myapplication" {
+		t.Errorf("Package: got %q", m.Package)
+	}
+	if m.VersionCode != 1 {
+		t.Errorf("VersionCode: got %d want 1", m.VersionCode)
+	}
+	if m.SDK.Min != 26 || m.SDK.Target != 28 {
+		t.Errorf("SDK: got %d, %d want 26, 28", m.SDK.Min, m.SDK.Target)
+	}
+	if m.App.Label != "My Application" {
+		t.Errorf("App.Label: got %q want My Application", m.App.Label)
+	}
+	if m.App.Theme != 0x7F0C0005 {
+		t.Errorf("App.Theme: got %s want 0x7F0C0005", m.App.Theme)
+	}
+	if !m.App.Debuggable {
+		t.Error("App.Debuggable: got false want true")
+	}
+
+	data := m.App.MetaData
+	if len(data) != 8 {
+		t.Fatalf("App.MetaData: got %d elements want 8", len(data))
+	}
+	cases := []struct {
+		index int
+		bool  bool
+		int   int
+		typ   DataType
+	}{
+		{0, true, 0, TypeIntBoolean},
+		{1, false, 0, TypeIntBoolean},
+		{2, true, 0, TypeReference}, // @bool/test_true
+		{3, false, 0, TypeReference},
+		{4, false, 42, TypeIntDec},
+		{5, false, -42, TypeReference}, // @integer/test
+	}
+	for _, c := range cases {
+		d := data[c.index]
+		if d.Bool != c.bool || d.Int != c.int || d.Value.DataType != c.typ {
+			t.Errorf("%s: got %v, %d, 0x%02X want %v, %d, 0x%02X", d.Name, d.Bool, d.Int, d.Value.DataType, c.bool, c.int, c.typ)
+		}
+	}
+
+	// the strings are not integers.
+	if err := xmlFile.DecodeStrict(&m, table, nil); err == nil {
+		t.Error("DecodeStrict: want error")
+	}
+
+	// the references are not resolved without the table.
+	var raw typedTestManifest
+	if err := xmlFile.DecodeRaw(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.App.Label != "@0x7F0B0027" || raw.App.MetaData[4].Int != 42 || raw.App.MetaData[5].Int != 0 {
+		t.Errorf("unexpected value: %q, %d, %d", raw.App.Label, raw.App.MetaData[4].Int, raw.App.MetaData[5].Int)
+	}
+}
+
+func TestDecodeTypedInvalidTag(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	cases := []interface{}{
+		&struct {
+			VersionCode int32 `androidbinary:"android:versionCode,bool"`
+		}{},
+		&struct {
+			VersionCode []int `androidbinary:"android:versionCode"`
+		}{},
+		&struct {
+			VersionCode int `androidbinary:",int"`
+		}{},
+	}
+	for _, v := range cases {
+		if err := xmlFile.Decode(v, nil, nil); err == nil {
+			t.Errorf("%T: want error", v)
+		}
+	}
+}
@@ -4,26 +4,157 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
-// XMLFile is an XML file expressed in binary format.
+// XMLFile is an XML file expressed in binary format, or in text format if it is read by NewXMLFileFromText.
+// It is parsed completely by NewXMLFile and never modified after that except by Reset,
+// so its methods may be called from multiple goroutines.
+// The text format rendered on demand with Options.LazyText is synchronized.
 type XMLFile struct {
 	stringPool     *ResStringPool
-	notPrecessedNS map[ResStringPoolRef]ResStringPoolRef
+	notPrecessedNS []namespaceVal
 	namespaces     xmlNamespaces
 	xmlBuffer      bytes.Buffer
+	lazyText       *lazyText
 	resourceIds    []ResStringPoolRef
+	root           *XMLElement
+	elements       []*XMLElement
+	current        *XMLElement
+	noTree         bool
+	openTag        bool
+	unknownChunks  []uint16
+	referencedIDs  []ResID
+	chunks         []ChunkInfo
+	warnings       []error
+	opts           Options
+	r              io.ReaderAt
 }
 
+// Options are options for parsing XML files.
+type Options struct {
+	// Table is used to render the names of the resources.
+	// e.g. the attribute references are rendered as ?attr/colorPrimary instead of ?0x7F010000.
+	Table *TableFile
+
+	// SelfClosingTags renders the elements without content as self-closing tags,
+	// e.g. <uses-permission android:name="..."/> instead of <uses-permission android:name="..."></uses-permission>.
+	SelfClosingTags bool
+
+	// OmitXMLDeclaration omits the XML declaration <?xml version="1.0" encoding="UTF-8"?>
+	// from the text format.
+	OmitXMLDeclaration bool
+
+	// ResourceNames renders the references as the names of the resources in Table,
+	// e.g. @string/app_name instead of @0x7F0B0027.
+	// The names are for reading the text format; Decode can't resolve them as the values of String and others.
+	ResourceNames bool
+
+	// SpecialAttributesFirst renders the id, class and style attributes that aapt indexes
+	// in ResXMLTreeAttrExt before the other attributes, in that order, e.g. android:id leads.
+	// The other attributes are in the order of the binary XML file, which aapt sorts by resource id.
+	// XMLElement.Attrs are in the rendered order.
+	SpecialAttributesFirst bool
+
+	// SkipInvalidRefs continues parsing past the string pool references that are out of range,
+	// which some obfuscated APKs have, instead of failing with InvalidReferenceError.
+	// The names that can't be read are substituted by placeholders such as "invalid-ref-0x0000FFFF",
+	// the values are substituted by empty strings, and the errors are reported by Warnings.
+	SkipInvalidRefs bool
+
+	// LazyText skips rendering the text format while parsing.
+	// The bytes of the file are kept in memory, and the text is rendered from them on the first call of Reader or WriteTo,
+	// so the io.ReaderAt passed to NewXMLFile may be closed after parsing.
+	// Decode, DecodeStrict and DecodeRaw decode the element tree directly instead of parsing the text,
+	// which is faster; the fields tagged with ",innerxml" and ",comment" are left empty by them.
+	// See BenchmarkDecodeLazyText.
+	LazyText bool
+
+	// ValueFormatter overrides the text format of the attribute values, e.g. to render the dimensions in another unit
+	// or the references as the names of the resources.
+	// It is an option rather than a setter of XMLFile because the text is rendered while parsing.
+	// It is called for each attribute with attr, whose Name, Namespace and Value are the ones rendered as usual,
+	// and value, the typed value of the attribute.
+	// The value it returns is used if it returns true; otherwise the value is rendered as usual.
+	// The values are used for the text format and XMLAttr.Value, and so for Decode,
+	// except for the fields tagged with `androidbinary:"..."`, which are decoded from the typed values.
+	ValueFormatter func(attr XMLAttr, value ResValue) (string, bool)
+}
+
+// lazyText renders the text format of XMLFile on demand.
+type lazyText struct {
+	once sync.Once
+	err  error
+}
+
+// InvalidReferenceError is returned when a string pool reference is out of range.
 type InvalidReferenceError struct {
 	Ref ResStringPoolRef
+
+	// Element is the name of the element where the reference occurred, if known.
+	Element string
+
+	// Attribute is the name of the attribute where the reference occurred, if known.
+	Attribute string
 }
 
 func (e *InvalidReferenceError) Error() string {
-	return fmt.Sprintf("androidbinary: invalid reference: 0x%08X", e.Ref)
+	msg := fmt.Sprintf("androidbinary: invalid reference: 0x%08X", e.Ref)
+	switch {
+	case e.Element != "" && e.Attribute != "":
+		msg += fmt.Sprintf(" in attribute %s of element %s", e.Attribute, e.Element)
+	case e.Element != "":
+		msg += fmt.Sprintf(" in element %s", e.Element)
+	case e.Attribute != "":
+		msg += fmt.Sprintf(" in attribute %s", e.Attribute)
+	}
+	return msg
+}
+
+// skipInvalidReference reports whether parsing continues past err.
+// It records err as a warning if err is an InvalidReferenceError and Options.SkipInvalidRefs is set.
+func (f *XMLFile) skipInvalidReference(err error) bool {
+	var e *InvalidReferenceError
+	if !f.opts.SkipInvalidRefs || !errors.As(err, &e) {
+		return false
+	}
+	f.warnings = append(f.warnings, err)
+	return true
+}
+
+// invalidReferenceName returns the placeholder of the name that the InvalidReferenceError err refers to.
+// It is the same for the same reference, so the start and the end tags match.
+func invalidReferenceName(err error) string {
+	var e *InvalidReferenceError
+	errors.As(err, &e)
+	return fmt.Sprintf("invalid-ref-0x%08X", uint32(e.Ref))
+}
+
+// Warnings returns the errors that were skipped with Options.SkipInvalidRefs, in document order.
+// It returns nil if the file has no such errors or the option is not set.
+func (f *XMLFile) Warnings() []error {
+	if len(f.warnings) == 0 {
+		return nil
+	}
+	return append([]error(nil), f.warnings...)
+}
+
+// withReferenceContext records the element and the attribute in err if it is an InvalidReferenceError without them.
+func withReferenceContext(err error, element, attribute string) error {
+	var e *InvalidReferenceError
+	if errors.As(err, &e) && e.Element == "" && e.Attribute == "" {
+		e.Element = element
+		e.Attribute = attribute
+	}
+	return err
 }
 
 type (
@@ -50,13 +181,13 @@ func (x *xmlNamespaces) remove(key ResStringPoolRef) {
 	}
 }
 
-func (x *xmlNamespaces) get(key ResStringPoolRef) ResStringPoolRef {
+func (x *xmlNamespaces) get(key ResStringPoolRef) (ResStringPoolRef, bool) {
 	for i := len(x.l) - 1; i >= 0; i-- {
 		if x.l[i].key == key {
-			return x.l[i].value
+			return x.l[i].value, true
 		}
 	}
-	return ResStringPoolRef(0)
+	return 0, false
 }
 
 // ResXMLTreeNode is basic XML tree node.
@@ -98,42 +229,489 @@ type ResXMLTreeEndElementExt struct {
 	Name ResStringPoolRef
 }
 
+// ResXMLTreeCdataExt is extended XML tree node for CDATA.
+type ResXMLTreeCdataExt struct {
+	Data      ResStringPoolRef
+	TypedData ResValue
+}
+
 // NewXMLFile returns a new XMLFile.
 func NewXMLFile(r io.ReaderAt) (*XMLFile, error) {
-	f := new(XMLFile)
-	sr := io.NewSectionReader(r, 0, 1<<63-1)
+	return NewXMLFileOptions(r, Options{})
+}
 
-	fmt.Fprintf(&f.xmlBuffer, xml.Header)
+// NewXMLFileOptions returns a new XMLFile parsed with opts.
+func NewXMLFileOptions(r io.ReaderAt, opts Options) (*XMLFile, error) {
+	f := &XMLFile{opts: opts, r: r}
+	if err := f.parse(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
 
-	header := new(ResChunkHeader)
-	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+// NewXMLFileFromReader returns a new XMLFile read from r.
+// It is for the sources that don't implement io.ReaderAt, e.g. an HTTP response body or a gzip stream.
+// r is read to the end into memory, and the result is the same as NewXMLFile with the read bytes.
+func NewXMLFileFromReader(r io.Reader) (*XMLFile, error) {
+	return NewXMLFileFromReaderOptions(r, Options{})
+}
+
+// NewXMLFileFromReaderOptions returns a new XMLFile read from r and parsed with opts.
+func NewXMLFileFromReaderOptions(r io.Reader, opts Options) (*XMLFile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
+	return NewXMLFileOptions(bytes.NewReader(data), opts)
+}
+
+// NewXMLFileFromText returns a new XMLFile of the XML file in text format read from r,
+// e.g. a resource that a build tool stored in an APK without compiling it.
+// The text is kept as it is for Reader, and the element tree is built from it for Root, Find and Decode.
+// The file has neither the string pool nor the typed values, so all attributes are strings.
+func NewXMLFileFromText(r io.Reader) (*XMLFile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	f := &XMLFile{}
+	f.xmlBuffer.Write(data)
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if f.root != nil && f.current == nil {
+				return nil, fmt.Errorf("androidbinary: element <%s> after the root element", textName(t.Name))
+			}
+			elem := &XMLElement{Name: textName(t.Name)}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					elem.Namespaces = append(elem.Namespaces, XMLNamespace{Prefix: attr.Name.Local, URI: attr.Value})
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					elem.Namespaces = append(elem.Namespaces, XMLNamespace{URI: attr.Value})
+				}
+			}
+			sort.Slice(elem.Namespaces, func(i, j int) bool { return elem.Namespaces[i].Prefix < elem.Namespaces[j].Prefix })
+			f.pushElement(elem)
+
+			// the namespaces are resolved after pushing, so that the ones declared on elem are in scope.
+			elem.Namespace, _ = elem.LookupNamespace(t.Name.Space)
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				xmlAttr := XMLAttr{Name: textName(attr.Name), Value: attr.Value}
+				if attr.Name.Space != "" {
+					xmlAttr.Namespace, _ = elem.LookupNamespace(attr.Name.Space)
+				}
+				elem.Attrs = append(elem.Attrs, xmlAttr)
+			}
+		case xml.EndElement:
+			// RawToken doesn't check that the tags are balanced.
+			if f.current == nil || f.current.Name != textName(t.Name) {
+				return nil, fmt.Errorf("androidbinary: unexpected end element </%s>", textName(t.Name))
+			}
+			f.popElement()
+		case xml.CharData:
+			if f.current != nil {
+				f.current.charData += string(t)
+			}
+		}
+	}
+	if f.root == nil {
+		return nil, fmt.Errorf("androidbinary: no elements in the XML file")
+	}
+	if f.current != nil {
+		return nil, fmt.Errorf("androidbinary: element <%s> is not closed", f.current.Name)
+	}
+	return f, nil
+}
+
+// textName returns the name with its namespace prefix, as RawToken of encoding/xml reads it.
+func textName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// Reset discards the contents of f and parses r with the same options,
+// reusing the buffer of the text format and the other internal buffers of f.
+// It is for the scanners that parse many files one after another, e.g. with a sync.Pool of XMLFiles,
+// and it reduces the allocations per file. See BenchmarkXMLFileReset.
+//
+// The *bytes.Reader returned by Reader shares the buffer, so it must not be used after Reset.
+// The elements and the strings that were read from f remain valid.
+// Reset must not be called concurrently with the other methods of f.
+// If it returns an error, f is in an undefined state until the next successful Reset.
+func (f *XMLFile) Reset(r io.ReaderAt) error {
+	buf := f.xmlBuffer
+	buf.Reset()
+	*f = XMLFile{
+		namespaces:    xmlNamespaces{l: f.namespaces.l[:0]},
+		xmlBuffer:     buf,
+		resourceIds:   f.resourceIds[:0],
+		elements:      f.elements[:0],
+		chunks:        f.chunks[:0],
+		referencedIDs: f.referencedIDs[:0],
+		opts:          f.opts,
+		r:             r,
+	}
+	return f.parse()
+}
+
+// parse reads the binary XML file from f.r into f.
+func (f *XMLFile) parse() error {
+	r := f.r
+	if f.opts.LazyText {
+		f.lazyText = new(lazyText)
+	}
+	if !f.opts.OmitXMLDeclaration {
+		fmt.Fprintf(f.text(), xml.Header)
+	}
+
+	header, err := readXMLHeader(r)
+	if err != nil {
+		return err
+	}
+	if f.lazyText != nil {
+		// keep the bytes of the document, so that rendering the text later doesn't read r again.
+		data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, int64(header.Size)))
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+		f.r = r
+	}
 	offset := int64(header.HeaderSize)
 	for offset < int64(header.Size) {
 		chunkHeader, err := f.readChunk(r, offset)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		offset += int64(chunkHeader.Size)
+		if offset > int64(header.Size) {
+			return errChunkExceedsDocument(offset, header)
+		}
 	}
-	return f, nil
+	f.closeStartTag()
+	if f.stringPool == nil {
+		return fmt.Errorf("androidbinary: string pool not found")
+	}
+	return nil
+}
+
+// IsBinaryXML reports whether r starts with the header of a binary XML file, as AndroidManifest.xml in APKs does.
+// It returns false for the XML files in text format and the other files, without parsing the whole file.
+func IsBinaryXML(r io.ReaderAt) bool {
+	_, err := readXMLHeader(r)
+	return err == nil
+}
+
+// readXMLHeader reads the header of the XML document chunk.
+// Its Size is the authoritative size of the document; the bytes after it are ignored.
+func readXMLHeader(r io.ReaderAt) (*ResChunkHeader, error) {
+	sr := io.NewSectionReader(r, 0, 1<<63-1)
+	header := new(ResChunkHeader)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if header.Type != ResXMLChunkType {
+		return nil, fmt.Errorf("androidbinary: not a binary XML file: unexpected chunk type 0x%04X", uint16(header.Type))
+	}
+	if header.HeaderSize < uint16(binary.Size(header)) {
+		return nil, fmt.Errorf("androidbinary: invalid document header size: %d", header.HeaderSize)
+	}
+	if header.Size < uint32(header.HeaderSize) {
+		return nil, fmt.Errorf("androidbinary: invalid document size: %d", header.Size)
+	}
+	return header, nil
+}
+
+func errChunkExceedsDocument(end int64, header *ResChunkHeader) error {
+	return fmt.Errorf("androidbinary: chunk ends at %d beyond the document size %d", end, header.Size)
+}
+
+// Clone returns a deep copy of f.
+// The string pool, the namespaces, the resource ids, the text and the element tree are copied,
+// so the copy can be modified without affecting f.
+// The io.ReaderAt passed to NewXMLFile is shared, and it is never modified;
+// with Options.LazyText, the copy renders its text on demand from the bytes kept by f.
+func (f *XMLFile) Clone() *XMLFile {
+	c := &XMLFile{
+		namespaces:    xmlNamespaces{l: append([]namespaceVal(nil), f.namespaces.l...)},
+		resourceIds:   append([]ResStringPoolRef(nil), f.resourceIds...),
+		noTree:        f.noTree,
+		openTag:       f.openTag,
+		unknownChunks: append([]uint16(nil), f.unknownChunks...),
+		referencedIDs: append([]ResID(nil), f.referencedIDs...),
+		chunks:        append([]ChunkInfo(nil), f.chunks...),
+		warnings:      append([]error(nil), f.warnings...),
+		opts:          f.opts,
+		r:             f.r,
+	}
+	if f.notPrecessedNS != nil {
+		c.notPrecessedNS = append([]namespaceVal(nil), f.notPrecessedNS...)
+	}
+	if f.stringPool != nil {
+		pool := *f.stringPool
+		pool.Strings = append([]string(nil), f.stringPool.Strings...)
+		pool.Styles = append([]ResStringPoolSpan(nil), f.stringPool.Styles...)
+		pool.styleStarts = append([]uint32(nil), f.stringPool.styleStarts...)
+		pool.styleData = append([]byte(nil), f.stringPool.styleData...)
+		c.stringPool = &pool
+	}
+	if f.lazyText != nil {
+		// the copy renders its own text from the shared bytes on demand.
+		c.lazyText = new(lazyText)
+	} else {
+		c.xmlBuffer.Write(f.xmlBuffer.Bytes())
+	}
+	if f.root != nil {
+		c.root = cloneXMLElement(f.root, nil)
+		c.elements = make([]*XMLElement, 0, len(f.elements))
+		walkXMLElement(c.root, func(elem *XMLElement) {
+			c.elements = append(c.elements, elem)
+		})
+	}
+	return c
 }
 
 // Reader returns a reader of XML file expressed in text format.
+// With Options.LazyText, the text is rendered on the first call.
 func (f *XMLFile) Reader() *bytes.Reader {
+	// the text is rendered from the bytes in memory that have been parsed without errors, so it doesn't fail.
+	f.renderText()
 	return bytes.NewReader(f.xmlBuffer.Bytes())
 }
 
+// WriteTo writes the XML file expressed in text format to w.
+// It implements io.WriterTo, and writes the same bytes as Reader without copying them.
+func (f *XMLFile) WriteTo(w io.Writer) (int64, error) {
+	if err := f.renderText(); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(f.xmlBuffer.Bytes())
+	return int64(n), err
+}
+
+// text returns the writer of the text format while parsing.
+// It discards the text if the text is rendered lazily.
+func (f *XMLFile) text() io.Writer {
+	if f.lazyText != nil {
+		return ioutil.Discard
+	}
+	return &f.xmlBuffer
+}
+
+// renderText renders the text format if it is rendered lazily and not rendered yet.
+func (f *XMLFile) renderText() error {
+	l := f.lazyText
+	if l == nil {
+		return nil
+	}
+	l.once.Do(func() {
+		_, l.err = io.Copy(&f.xmlBuffer, f.streamReader())
+	})
+	return l.err
+}
+
+// NewXMLStreamReader returns a reader of the binary XML file r expressed in text format.
+// Unlike NewXMLFile, it builds neither the element tree nor the whole text:
+// it decodes the chunks of r on demand and emits the text incrementally,
+// so only the string pool and the chunk being rendered are held in memory.
+// The bytes it emits are identical to the ones of XMLFile.Reader, and the errors of parsing are returned by Read.
+// r must remain readable until the reader returns io.EOF.
+func NewXMLStreamReader(r io.ReaderAt) io.Reader {
+	return NewXMLStreamReaderOptions(r, Options{})
+}
+
+// NewXMLStreamReaderOptions returns a reader of the binary XML file r expressed in text format with opts.
+func NewXMLStreamReaderOptions(r io.ReaderAt, opts Options) io.Reader {
+	s := &xmlStreamReader{
+		f: &XMLFile{opts: opts, noTree: true},
+		r: r,
+	}
+	if !opts.OmitXMLDeclaration {
+		fmt.Fprintf(&s.f.xmlBuffer, xml.Header)
+	}
+	return s
+}
+
+// streamReader returns a reader of the text format that renders the document parsed by f again.
+func (f *XMLFile) streamReader() io.Reader {
+	return NewXMLStreamReaderOptions(f.r, f.opts)
+}
+
+// xmlStreamReader renders the chunks one by one.
+type xmlStreamReader struct {
+	f      *XMLFile
+	r      io.ReaderAt
+	header *ResChunkHeader
+	offset int64
+	err    error
+}
+
+func (s *xmlStreamReader) Read(p []byte) (int, error) {
+	for s.f.xmlBuffer.Len() == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.err = s.next()
+	}
+	return s.f.xmlBuffer.Read(p)
+}
+
+func (s *xmlStreamReader) next() error {
+	if s.header == nil {
+		header, err := readXMLHeader(s.r)
+		if err != nil {
+			return err
+		}
+		s.header = header
+		s.offset = int64(header.HeaderSize)
+	}
+	if s.offset >= int64(s.header.Size) {
+		s.f.closeStartTag()
+		return io.EOF
+	}
+	chunkHeader, err := s.f.readChunk(s.r, s.offset)
+	if err != nil {
+		return err
+	}
+	s.offset += int64(chunkHeader.Size)
+	if s.offset > int64(s.header.Size) {
+		return errChunkExceedsDocument(s.offset, s.header)
+	}
+	return nil
+}
+
+// newDecoder returns the decoder of the text format.
+// With Options.LazyText, it decodes the tokens of the element tree instead, without rendering the text.
+func (f *XMLFile) newDecoder() (*xml.Decoder, error) {
+	if f.lazyText != nil && f.root != nil {
+		return xml.NewTokenDecoder(newXMLTreeTokenReader(f.root)), nil
+	}
+	if err := f.renderText(); err != nil {
+		return nil, err
+	}
+	return xml.NewDecoder(bytes.NewReader(f.xmlBuffer.Bytes())), nil
+}
+
 // Decode decodes XML file and stores the result in the value pointed to by v.
 // To resolve the resource references, Decode also stores default TableFile and ResTableConfig in the value pointed to by v.
+// Bool, Int32 and String values are found in exported struct fields (including embedded structs),
+// slices, arrays, map values, pointers and interfaces.
+// Decode is safe to call concurrently with different values.
+//
+// The struct fields tagged with `androidbinary:"..."` are decoded from the typed values
+// of the attributes in the binary XML file, instead of their text format.
+// The tag grammar is:
+//
+//	tag  = name [ "," kind ]
+//	kind = "int" | "uint" | "bool" | "float" | "string" | "resid" | "value"
+//
+// name is the name of the attribute including its namespace prefix, e.g. "android:versionCode",
+// and it is matched as XMLElement.Attr does.
+// kind is inferred from the type of the field if it is omitted, and it must be compatible with the type:
+//
+//	int    int, int8, int16, int32 and int64
+//	uint   uint, uint8, uint16, uint32 and uint64
+//	bool   bool
+//	float  float32 and float64
+//	string string
+//	resid  ResID; the id of a reference or an attribute, not resolved
+//	value  ResValue; the typed value as it is, not resolved
+//
+// The references are resolved with table and config as ResValue.Interface does,
+// and the text values, e.g. android:versionCode="1" stored as a string, are parsed.
+// The fields are left as they are if the element doesn't have the attribute or the value can't be converted;
+// DecodeStrict reports them. Decode returns an error if a tag is invalid.
+// The elements are matched to the structs by the names of the xml tags or the fields, as encoding/xml does,
+// and the slices of structs are matched to the repeated elements in order.
+// The paths such as `xml:"a>b"` are not supported. For example:
+//
+//	type Manifest struct {
+//		VersionCode int32 `androidbinary:"android:versionCode"`
+//		App         struct {
+//			Debuggable bool `androidbinary:"android:debuggable,bool"`
+//		} `xml:"application"`
+//	}
 func (f *XMLFile) Decode(v interface{}, table *TableFile, config *ResTableConfig) error {
-	decoder := xml.NewDecoder(f.Reader())
+	decoder, err := f.newDecoder()
+	if err != nil {
+		return err
+	}
 	if err := decoder.Decode(v); err != nil {
 		return err
 	}
-	inject(reflect.ValueOf(v), table, config)
-	return nil
+	// the references are resolved when the values are read.
+	inject(reflect.ValueOf(v), table, config, false)
+	_, err = f.decodeTyped(v, table, config)
+	return err
+}
+
+// DecodeStrict is same as Decode, but it returns an error
+// if some of the references to the resources are dangling in table and config,
+// e.g. @string/foo is missing in table.
+// The references to the resources that String can't read as strings, e.g. styles, are not errors;
+// String returns them as they are.
+// The value pointed to by v is filled even if it returns an error.
+func (f *XMLFile) DecodeStrict(v interface{}, table *TableFile, config *ResTableConfig) error {
+	decoder, err := f.newDecoder()
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	injectErr := inject(reflect.ValueOf(v), table, config, true)
+	valueErr, err := f.decodeTyped(v, table, config)
+	if err != nil {
+		return err
+	}
+	if injectErr != nil {
+		return injectErr
+	}
+	return valueErr
+}
+
+// DecodeRaw is same as Decode, but it doesn't tie any TableFile and ResTableConfig to the values,
+// so the references are kept as they are in the XML file, e.g. "@0x7F0B0027".
+// It is for the tools that report which resources the file refers to; use Raw to read the values.
+// The fields tagged with `androidbinary:"..."` are decoded without resolving the references.
+func (f *XMLFile) DecodeRaw(v interface{}) error {
+	decoder, err := f.newDecoder()
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	_, err = f.decodeTyped(v, nil, nil)
+	return err
+}
+
+// decodeTyped sets the fields of v tagged with `androidbinary:"..."`.
+// It returns the first error of the values and the error of the tags.
+func (f *XMLFile) decodeTyped(v interface{}, table *TableFile, config *ResTableConfig) (valueErr, tagErr error) {
+	if f.root == nil {
+		return nil, nil
+	}
+	d := &typedDecoder{table: table, config: config}
+	if err := d.decode(reflect.ValueOf(v), f.root); err != nil {
+		return nil, err
+	}
+	return d.err, nil
 }
 
 func (f *XMLFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error) {
@@ -145,12 +723,14 @@ func (f *XMLFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error
 	if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
 		return nil, err
 	}
-	if chunkHeader.HeaderSize < uint16(binary.Size(chunkHeader)) {
-		return nil, fmt.Errorf("androidbinary: invalid chunk header size: %d", chunkHeader.HeaderSize)
-	}
-	if chunkHeader.Size < uint32(chunkHeader.HeaderSize) {
-		return nil, fmt.Errorf("androidbinary: invalid chunk size: %d", chunkHeader.Size)
+	if err := validateChunkHeader(chunkHeader); err != nil {
+		return nil, err
 	}
+	f.chunks = append(f.chunks, ChunkInfo{
+		Type:   chunkHeader.Type,
+		Offset: offset,
+		Size:   int64(chunkHeader.Size),
+	})
 
 	var err error
 	if _, err := sr.Seek(0, io.SeekStart); err != nil {
@@ -169,6 +749,13 @@ func (f *XMLFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error
 		err = f.readStartElement(sr)
 	case ResXMLEndElementType:
 		err = f.readEndElement(sr)
+	case ResXMLCDataType:
+		err = f.readCharData(sr)
+	default:
+		// the chunk is skipped by its size, but record it so that callers can detect unsupported features.
+		if chunkHeader.Type >= ResXMLFirstChunkType && chunkHeader.Type <= ResXMLLastChunkType {
+			f.addUnknownChunk(uint16(chunkHeader.Type))
+		}
 	}
 	if err != nil {
 		return nil, err
@@ -177,8 +764,60 @@ func (f *XMLFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error
 	return chunkHeader, nil
 }
 
+func (f *XMLFile) addUnknownChunk(typ uint16) {
+	for _, t := range f.unknownChunks {
+		if t == typ {
+			return
+		}
+	}
+	f.unknownChunks = append(f.unknownChunks, typ)
+}
+
+// UnknownChunks returns the types of the XML tree chunks that are skipped
+// because they are not supported, in the order they first appear.
+func (f *XMLFile) UnknownChunks() []uint16 {
+	if len(f.unknownChunks) == 0 {
+		return nil
+	}
+	return append([]uint16(nil), f.unknownChunks...)
+}
+
+// ReferencedResourceIDs returns the ids of the resources that the attributes refer to,
+// i.e. the data of the references, the attribute references and their dynamic variants, e.g. 0x7F0B0027 for "@string/app_name".
+// The ids are de-duplicated and sorted. @null (the reference to 0) is not included.
+func (f *XMLFile) ReferencedResourceIDs() []ResID {
+	if len(f.referencedIDs) == 0 {
+		return nil
+	}
+	ids := append([]ResID(nil), f.referencedIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	n := 1
+	for _, id := range ids[1:] {
+		if id != ids[n-1] {
+			ids[n] = id
+			n++
+		}
+	}
+	return ids[:n]
+}
+
+// ChunkInfo is the location of a chunk in the binary XML file.
+type ChunkInfo struct {
+	Type   ChunkType
+	Offset int64
+	Size   int64
+}
+
+// Chunks returns the chunks of the document in the order they were read, e.g. the string pool,
+// the resource map, the namespaces and the elements, including the ones of unknown types.
+// The document header itself is not included.
+// It is for inspecting the structure of the file when debugging.
+func (f *XMLFile) Chunks() []ChunkInfo {
+	return append([]ChunkInfo(nil), f.chunks...)
+}
+
 // GetString returns a string referenced by ref.
-// It panics if the pool doesn't contain ref.
+// It panics if the pool doesn't contain ref; use HasString to check it first.
 func (f *XMLFile) GetString(ref ResStringPoolRef) string {
 	return f.stringPool.GetString(ref)
 }
@@ -186,17 +825,41 @@ func (f *XMLFile) GetString(ref ResStringPoolRef) string {
 func (f *XMLFile) HasString(ref ResStringPoolRef) bool {
 	return f.stringPool.HasString(ref)
 }
+
+// Strings returns all strings in the string pool, in pool order.
+func (f *XMLFile) Strings() []string {
+	if f.stringPool == nil {
+		return []string{}
+	}
+	strs := make([]string, len(f.stringPool.Strings))
+	copy(strs, f.stringPool.Strings)
+	return strs
+}
+
+// StringCount returns the number of strings in the string pool.
+func (f *XMLFile) StringCount() int {
+	if f.stringPool == nil {
+		return 0
+	}
+	return len(f.stringPool.Strings)
+}
+
 func (f *XMLFile) readResourceIds(sr *io.SectionReader) error {
-	header := new(ResXMLTreeNode)
+	// the resource map has no tree node header; the ids follow the chunk header.
+	header := new(ResChunkHeader)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
 		return err
 	}
+	if header.Size < uint32(header.HeaderSize) {
+		return fmt.Errorf("androidbinary: invalid chunk size: %d", header.Size)
+	}
 
-	if _, err := sr.Seek(int64(header.Header.HeaderSize), io.SeekStart); err != nil {
+	if _, err := sr.Seek(int64(header.HeaderSize), io.SeekStart); err != nil {
 		return err
 	}
 	var id ResStringPoolRef
-	for i := uint32(0); i < (header.Header.Size/4 - 2); i++ {
+	count := (header.Size - uint32(header.HeaderSize)) / 4
+	for i := uint32(0); i < count; i++ {
 		if err := binary.Read(sr, binary.LittleEndian, &id); err != nil {
 			return err
 		}
@@ -219,14 +882,81 @@ func (f *XMLFile) readStartNamespace(sr *io.SectionReader) error {
 		return err
 	}
 
-	if f.notPrecessedNS == nil {
-		f.notPrecessedNS = make(map[ResStringPoolRef]ResStringPoolRef)
+	if header.Comment != NilResStringPoolRef && f.HasString(header.Comment) {
+		// the comment of the declaration precedes the element that declares it.
+		f.closeStartTag()
+		io.WriteString(f.text(), "<!--")
+		io.WriteString(f.text(), xmlComment(f.GetString(header.Comment)))
+		io.WriteString(f.text(), "-->")
 	}
-	f.notPrecessedNS[namespace.URI] = namespace.Prefix
+
+	// the declarations are kept in order, so that they are rendered in the same order every time.
+	f.notPrecessedNS = append(f.notPrecessedNS, namespaceVal{key: namespace.URI, value: namespace.Prefix})
 	f.namespaces.add(namespace.URI, namespace.Prefix)
 	return nil
 }
 
+// xmlComment returns s that can be put in an XML comment, which can't contain "--" nor end with "-".
+func xmlComment(s string) string {
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "- -")
+	}
+	if strings.HasSuffix(s, "-") {
+		s += " "
+	}
+	return s
+}
+
+// escapeAttr writes s escaped for an attribute value in double quotes,
+// so that XML parsers including encoding/xml read s as it is.
+// Unlike xml.Escape, the quotes are escaped as &quot; and &apos;, which some tools expect.
+// The whitespace other than spaces is escaped as the character references, as attribute-value normalization
+// would replace it with spaces, and the characters that XML can't represent, e.g. U+0001 and invalid UTF-8,
+// are replaced with U+FFFD as xml.Escape does.
+func escapeAttr(w io.Writer, s string) {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		i += width
+		var esc string
+		switch r {
+		case '"':
+			esc = "&quot;"
+		case '\'':
+			esc = "&apos;"
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '\t':
+			esc = "&#x9;"
+		case '\n':
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			if isXMLChar(r) && !(r == utf8.RuneError && width == 1) {
+				continue
+			}
+			esc = "\uFFFD"
+		}
+		io.WriteString(w, s[last:i-width])
+		io.WriteString(w, esc)
+		last = i
+	}
+	io.WriteString(w, s[last:])
+}
+
+// isXMLChar reports whether r is a character of XML 1.0.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
 func (f *XMLFile) readEndNamespace(sr *io.SectionReader) error {
 	header := new(ResXMLTreeNode)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
@@ -244,23 +974,84 @@ func (f *XMLFile) readEndNamespace(sr *io.SectionReader) error {
 	return nil
 }
 
+// attributeOrder returns the indexes of the attributes of the element in the rendered order.
+func (f *XMLFile) attributeOrder(ext *ResXMLTreeAttrExt) []int {
+	count := int(ext.AttributeCount)
+	order := make([]int, 0, count)
+	seen := make([]bool, count)
+	if f.opts.SpecialAttributesFirst {
+		// the indexes are 1-based, and 0 means that the element doesn't have the attribute.
+		for _, index := range []uint16{ext.IDIndex, ext.ClassIndex, ext.StyleIndex} {
+			if i := int(index) - 1; i >= 0 && i < count && !seen[i] {
+				order = append(order, i)
+				seen[i] = true
+			}
+		}
+	}
+	for i := 0; i < count; i++ {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// referenceName returns the name of the resource id if the ResourceNames option is set.
+func (f *XMLFile) referenceName(id ResID) (string, bool) {
+	if !f.opts.ResourceNames {
+		return "", false
+	}
+	return f.opts.Table.referenceName(id)
+}
+
+// addNamespacePrefix returns the qualified name of the attribute.
+// The resource map is authoritative for the names of the attributes that have resource ids,
+// in any namespace: the names in the string pool are often renamed by obfuscators,
+// but Android looks up the attributes by their ids.
+// The string pool is used for the other attributes.
 func (f *XMLFile) addNamespacePrefix(ns, name ResStringPoolRef) (string, error) {
-	var attrName, prefix string
+	// The resource map is parallel to the string pool:
+	// the resource id of the attribute is at the same index as its name in the string pool.
+	// ResStringPoolRef is unsigned, so this also excludes NilResStringPoolRef.
 	if name < ResStringPoolRef(len(f.resourceIds)) {
-		attrName = getAttributteName(f.resourceIds[name])
-		prefix = "android"
+		if attrName := getAttributteName(f.resourceIds[name]); attrName != "" {
+			// the attribute is defined by the android framework.
+			return f.qualifiedName(ns, attrName, "android")
+		}
+	}
+	if !f.HasString(name) {
+		return "", &InvalidReferenceError{Ref: name}
+	}
+	return f.qualifiedName(ns, f.GetString(name), "")
+}
+
+// elementName returns the qualified name of the element.
+// Unlike the attributes, the names of the elements are always in the string pool.
+func (f *XMLFile) elementName(ns, name ResStringPoolRef) (string, error) {
+	if !f.HasString(name) {
+		return "", &InvalidReferenceError{Ref: name}
 	}
-	if attrName == "" {
-		attrName = f.GetString(name)
+	return f.qualifiedName(ns, f.GetString(name), "")
+}
+
+// qualifiedName adds the prefix of ns to localName.
+// defaultPrefix is used if ns is not declared.
+func (f *XMLFile) qualifiedName(ns ResStringPoolRef, localName, defaultPrefix string) (string, error) {
+	if ns == NilResStringPoolRef {
+		return localName, nil
 	}
-	if ns != NilResStringPoolRef {
-		if f.namespaces.get(ns) != 0 {
-			prefix = f.GetString(f.namespaces.get(ns))
+	prefix := defaultPrefix
+	if ref, ok := f.namespaces.get(ns); ok {
+		if !f.HasString(ref) {
+			return "", &InvalidReferenceError{Ref: ref}
 		}
-		return fmt.Sprintf("%s:%s", prefix, attrName), nil
-	} else {
-		return attrName, nil
+		prefix = f.GetString(ref)
 	}
+	if prefix == "" {
+		// the namespace is unknown.
+		return localName, nil
+	}
+	return fmt.Sprintf("%s:%s", prefix, localName), nil
 }
 
 func (f *XMLFile) readStartElement(sr *io.SectionReader) error {
@@ -274,82 +1065,188 @@ func (f *XMLFile) readStartElement(sr *io.SectionReader) error {
 	}
 	ext := new(ResXMLTreeAttrExt)
 	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
-		return nil
+		return err
 	}
 
-	tag, err := f.addNamespacePrefix(ext.NS, ext.Name)
+	tag, err := f.elementName(ext.NS, ext.Name)
 	if err != nil {
-		return err
+		if !f.skipInvalidReference(err) {
+			return err
+		}
+		tag = invalidReferenceName(err)
+	}
+	f.closeStartTag()
+	io.WriteString(f.text(), "<")
+	io.WriteString(f.text(), tag)
+	elem := &XMLElement{
+		Name:      tag,
+		Namespace: f.namespaceURI(ext.NS),
 	}
-	f.xmlBuffer.WriteString("<")
-	f.xmlBuffer.WriteString(tag)
 
 	// output XML namespaces
 	if f.notPrecessedNS != nil {
-		for uri, prefix := range f.notPrecessedNS {
+		for _, ns := range f.notPrecessedNS {
+			uri, prefix := ns.key, ns.value
+			var err error
 			if !f.HasString(uri) {
-				return &InvalidReferenceError{Ref: uri}
+				err = &InvalidReferenceError{Ref: uri, Element: tag}
+			} else if !f.HasString(prefix) {
+				err = &InvalidReferenceError{Ref: prefix, Element: tag}
 			}
-			if !f.HasString(prefix) {
-				return &InvalidReferenceError{Ref: prefix}
+			if err != nil {
+				if !f.skipInvalidReference(err) {
+					return err
+				}
+				continue
 			}
-			fmt.Fprintf(&f.xmlBuffer, " xmlns:%s=\"", f.GetString(prefix))
-			xml.Escape(&f.xmlBuffer, []byte(f.GetString(uri)))
-			fmt.Fprint(&f.xmlBuffer, "\"")
+			fmt.Fprintf(f.text(), " xmlns:%s=\"", f.GetString(prefix))
+			escapeAttr(f.text(), f.GetString(uri))
+			fmt.Fprint(f.text(), "\"")
+			elem.Namespaces = append(elem.Namespaces, XMLNamespace{Prefix: f.GetString(prefix), URI: f.GetString(uri)})
 		}
+		sort.Slice(elem.Namespaces, func(i, j int) bool { return elem.Namespaces[i].Prefix < elem.Namespaces[j].Prefix })
 		f.notPrecessedNS = nil
 	}
 
 	// process attributes
-	offset := int64(ext.AttributeStart + header.Header.HeaderSize)
-	for i := 0; i < int(ext.AttributeCount); i++ {
+	start := int64(ext.AttributeStart) + int64(header.Header.HeaderSize)
+	if ext.AttributeCount > 0 {
+		if int(ext.AttributeSize) < binary.Size(ResXMLTreeAttribute{}) {
+			return fmt.Errorf("androidbinary: invalid attribute size: %d", ext.AttributeSize)
+		}
+		end := start + int64(ext.AttributeCount)*int64(ext.AttributeSize)
+		if end > int64(header.Header.Size) {
+			return fmt.Errorf("androidbinary: %d attributes end at %d beyond the chunk size %d", ext.AttributeCount, end, header.Header.Size)
+		}
+	}
+	for _, i := range f.attributeOrder(ext) {
+		offset := start + int64(i)*int64(ext.AttributeSize)
 		if _, err := sr.Seek(offset, io.SeekStart); err != nil {
 			return err
 		}
 		attr := new(ResXMLTreeAttribute)
-		binary.Read(sr, binary.LittleEndian, attr)
+		if err := binary.Read(sr, binary.LittleEndian, attr); err != nil {
+			return err
+		}
+
+		name, err := f.addNamespacePrefix(attr.NS, attr.Name)
+		if err != nil {
+			err = withReferenceContext(err, tag, "")
+			if !f.skipInvalidReference(err) {
+				return err
+			}
+			name = invalidReferenceName(err)
+		}
 
 		var value string
 		if attr.RawValue != NilResStringPoolRef {
-			if !f.HasString(attr.RawValue) {
-				return &InvalidReferenceError{Ref: attr.RawValue}
+			if f.HasString(attr.RawValue) {
+				value = f.GetString(attr.RawValue)
+			} else {
+				// the value is left empty if the error is skipped.
+				err := &InvalidReferenceError{Ref: attr.RawValue, Element: tag, Attribute: name}
+				if !f.skipInvalidReference(err) {
+					return err
+				}
 			}
-			value = f.GetString(attr.RawValue)
 		} else {
 			data := attr.TypedValue.Data
+			value = attr.TypedValue.String()
 			switch attr.TypedValue.DataType {
-			case TypeNull:
-				value = ""
-			case TypeReference:
-				value = fmt.Sprintf("@0x%08X", data)
-			case TypeIntDec:
-				value = fmt.Sprintf("%d", data)
-			case TypeIntHex:
-				value = fmt.Sprintf("0x%08X", data)
-			case TypeIntBoolean:
+			case TypeReference, TypeDynamicReference, TypeAttribute, TypeDynamicAttribute:
 				if data != 0 {
-					value = "true"
-				} else {
-					value = "false"
+					f.referencedIDs = append(f.referencedIDs, ResID(data))
 				}
-			default:
-				value = fmt.Sprintf("@0x%08X", data)
+			}
+			switch attr.TypedValue.DataType {
+			case TypeString:
+				// the value is in the string pool of the XML file.
+				if f.HasString(ResStringPoolRef(data)) {
+					value = f.GetString(ResStringPoolRef(data))
+				}
+			case TypeReference, TypeDynamicReference:
+				if name, ok := f.referenceName(ResID(data)); ok {
+					value = "@" + name
+				}
+			case TypeAttribute:
+				if name, ok := f.opts.Table.attributeName(ResID(data)); ok {
+					value = "?" + name
+				}
+			}
+		}
+		xmlAttr := XMLAttr{
+			Name:      name,
+			Namespace: f.namespaceURI(attr.NS),
+			Value:     value,
+		}
+		if f.opts.ValueFormatter != nil {
+			if v, ok := f.opts.ValueFormatter(xmlAttr, attr.TypedValue); ok {
+				xmlAttr.Value = v
 			}
 		}
 
-		name, err := f.addNamespacePrefix(attr.NS, attr.Name)
-		if err != nil {
-			return err
+		fmt.Fprintf(f.text(), " %s=\"", name)
+		escapeAttr(f.text(), xmlAttr.Value)
+		fmt.Fprint(f.text(), "\"")
+		elem.Attrs = append(elem.Attrs, xmlAttr)
+		elem.rawAttrs = append(elem.rawAttrs, *attr)
+		switch uint16(i + 1) {
+		case ext.IDIndex:
+			elem.idIndex = len(elem.Attrs)
+		case ext.ClassIndex:
+			elem.classIndex = len(elem.Attrs)
+		case ext.StyleIndex:
+			elem.styleIndex = len(elem.Attrs)
 		}
-		fmt.Fprintf(&f.xmlBuffer, " %s=\"", name)
-		xml.Escape(&f.xmlBuffer, []byte(value))
-		fmt.Fprint(&f.xmlBuffer, "\"")
-		offset += int64(ext.AttributeSize)
 	}
-	fmt.Fprint(&f.xmlBuffer, ">")
+	if f.opts.SelfClosingTags {
+		// defer closing the tag until we know whether the element has content.
+		f.openTag = true
+	} else {
+		fmt.Fprint(f.text(), ">")
+	}
+	f.pushElement(elem)
 	return nil
 }
 
+// formatColor formats the color in #aarrggbb form as aapt prints it.
+func formatColor(typ DataType, data uint32) string {
+	return fmt.Sprintf("#%08x", colorARGB(typ, data))
+}
+
+// colorARGB returns the color of the color data type in the #aarrggbb form.
+func colorARGB(typ DataType, data uint32) uint32 {
+	// aapt expands the short forms when it compiles the resources,
+	// but the 4-bit per channel forms, e.g. #f00f, may be stored as is.
+	switch typ {
+	case TypeIntColorARGB4:
+		if data <= 0xffff {
+			data = expandNibbles(data>>12&0xf)<<24 | expandNibbles(data>>8&0xf)<<16 |
+				expandNibbles(data>>4&0xf)<<8 | expandNibbles(data&0xf)
+		}
+	case TypeIntColorRGB4:
+		if data <= 0xfff {
+			data = 0xff000000 | expandNibbles(data>>8&0xf)<<16 |
+				expandNibbles(data>>4&0xf)<<8 | expandNibbles(data&0xf)
+		}
+	case TypeIntColorRGB8:
+		data |= 0xff000000
+	}
+	return data
+}
+
+func expandNibbles(n uint32) uint32 {
+	return n<<4 | n
+}
+
+// closeStartTag closes the start tag left open by readStartElement.
+func (f *XMLFile) closeStartTag() {
+	if f.openTag {
+		fmt.Fprint(f.text(), ">")
+		f.openTag = false
+	}
+}
+
 func (f *XMLFile) readEndElement(sr *io.SectionReader) error {
 	header := new(ResXMLTreeNode)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
@@ -362,10 +1259,56 @@ func (f *XMLFile) readEndElement(sr *io.SectionReader) error {
 	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
 		return err
 	}
-	tag, err := f.addNamespacePrefix(ext.NS, ext.Name)
+	tag, err := f.elementName(ext.NS, ext.Name)
 	if err != nil {
-		return err
+		if !f.skipInvalidReference(err) {
+			return err
+		}
+		tag = invalidReferenceName(err)
+	}
+	if f.openTag {
+		fmt.Fprint(f.text(), "/>")
+		f.openTag = false
+	} else {
+		fmt.Fprintf(f.text(), "</%s>", tag)
 	}
-	fmt.Fprintf(&f.xmlBuffer, "</%s>", tag)
+	f.popElement()
 	return nil
 }
+
+func (f *XMLFile) readCharData(sr *io.SectionReader) error {
+	header := new(ResXMLTreeNode)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := sr.Seek(int64(header.Header.HeaderSize), io.SeekStart); err != nil {
+		return err
+	}
+	ext := new(ResXMLTreeCdataExt)
+	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
+		return err
+	}
+	if ext.Data == NilResStringPoolRef {
+		return nil
+	}
+	if !f.HasString(ext.Data) {
+		err := &InvalidReferenceError{Ref: ext.Data}
+		if f.current != nil {
+			err.Element = f.current.Name
+		}
+		if f.skipInvalidReference(err) {
+			return nil
+		}
+		return err
+	}
+
+	f.closeStartTag()
+	if f.current != nil && !f.noTree {
+		f.current.charData += f.GetString(ext.Data)
+	}
+
+	// aapt stores the text literally, including leading and trailing whitespace.
+	// xml.EscapeText escapes newlines, tabs and carriage returns as character references,
+	// so the text survives the end-of-line normalization of XML parsers.
+	return xml.EscapeText(f.text(), []byte(f.GetString(ext.Data)))
+}
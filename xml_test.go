@@ -2,11 +2,18 @@ package androidbinary
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"unicode/utf16"
 )
 
 type XMLManifest struct {
@@ -122,11 +129,11 @@ func TestReadStartNamespace(t *testing.T) {
 	if err != nil {
 		t.Errorf("got %v want no error", err)
 	}
-	if f.notPrecessedNS[ResStringPoolRef(1)] != ResStringPoolRef(2) {
-		t.Errorf("got %v want %v", f.notPrecessedNS[ResStringPoolRef(1)], ResStringPoolRef(2))
+	if want := []namespaceVal{{key: ResStringPoolRef(1), value: ResStringPoolRef(2)}}; !reflect.DeepEqual(f.notPrecessedNS, want) {
+		t.Errorf("got %v want %v", f.notPrecessedNS, want)
 	}
-	if f.namespaces.get(ResStringPoolRef(1)) != ResStringPoolRef(2) {
-		t.Errorf("got %v want %v", f.namespaces.get(ResStringPoolRef(1)), ResStringPoolRef(2))
+	if prefix, ok := f.namespaces.get(ResStringPoolRef(1)); !ok || prefix != ResStringPoolRef(2) {
+		t.Errorf("got %v, %v want %v", prefix, ok, ResStringPoolRef(2))
 	}
 }
 
@@ -211,8 +218,7 @@ func TestReadStartElement(t *testing.T) {
 	uriRef := ResStringPoolRef(3)
 
 	f := new(XMLFile)
-	f.notPrecessedNS = make(map[ResStringPoolRef]ResStringPoolRef)
-	f.notPrecessedNS[uriRef] = prefixRef
+	f.notPrecessedNS = []namespaceVal{{key: uriRef, value: prefixRef}}
 	f.namespaces.add(uriRef, prefixRef)
 	f.stringPool = new(ResStringPool)
 	f.stringPool.Strings = []string{"", "name", "prefix", "http://example.com", "attr", "value"}
@@ -227,6 +233,79 @@ func TestReadStartElement(t *testing.T) {
 	}
 }
 
+// newTypedStartElement returns a RES_XML_START_ELEMENT_TYPE chunk of the element "name"
+// that has the attribute "attr" with the typed value.
+func newTypedStartElement(dataType DataType, data uint32) []uint8 {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, ResXMLTreeNode{
+		Header: ResChunkHeader{
+			Type:       ResXMLStartElementType,
+			HeaderSize: 16,
+			Size:       60,
+		},
+		LineNumber: 1,
+		Comment:    NilResStringPoolRef,
+	})
+	binary.Write(buf, binary.LittleEndian, ResXMLTreeAttrExt{
+		NS:             NilResStringPoolRef,
+		Name:           1,
+		AttributeStart: 20,
+		AttributeSize:  20,
+		AttributeCount: 1,
+	})
+	binary.Write(buf, binary.LittleEndian, ResXMLTreeAttribute{
+		NS:       NilResStringPoolRef,
+		Name:     2,
+		RawValue: NilResStringPoolRef,
+		TypedValue: ResValue{
+			Size:     8,
+			DataType: dataType,
+			Data:     data,
+		},
+	})
+	return buf.Bytes()
+}
+
+var readStartElementTypedValueTests = []struct {
+	dataType DataType
+	data     uint32
+	want     string
+}{
+	{TypeNull, 0x00000000, `<name attr="">`},
+	{TypeReference, 0x7F040000, `<name attr="@0x7F040000">`},
+	{TypeAttribute, 0x7F010000, `<name attr="?0x7F010000">`},
+	{TypeDynamicReference, 0x02040000, `<name attr="@0x02040000">`},
+	{TypeDynamicAttribute, 0x02010000, `<name attr="?0x02010000">`},
+	{TypeString, 1, `<name attr="name">`}, // the string in the string pool of the XML file
+	{TypeFloat, 0x3FC00000, `<name attr="1.5">`},
+	{TypeDemention, 0x00000E02, `<name attr="14sp">`},
+	{TypeFraction, 0x40000031, `<name attr="50%p">`},
+	{TypeIntDec, 42, `<name attr="42">`},
+	{TypeIntDec, 0xFFFFFFFF, `<name attr="-1">`}, // match_parent
+	{TypeIntHex, 0x2A, `<name attr="0x0000002A">`},
+	{TypeIntBoolean, 0, `<name attr="false">`},
+	{TypeIntBoolean, 1, `<name attr="true">`},
+	{TypeIntBoolean, 0xFFFFFFFF, `<name attr="true">`}, // aapt stores true as 0xFFFFFFFF
+}
+
+func TestReadStartElementTypedValue(t *testing.T) {
+	for _, tt := range readStartElementTypedValueTests {
+		input := newTypedStartElement(tt.dataType, tt.data)
+		sr := io.NewSectionReader(bytes.NewReader(input), 0, int64(len(input)))
+
+		f := new(XMLFile)
+		f.stringPool = new(ResStringPool)
+		f.stringPool.Strings = []string{"", "name", "attr"}
+		if err := f.readStartElement(sr); err != nil {
+			t.Errorf("type 0x%02X: got %v want no error", tt.dataType, err)
+			continue
+		}
+		if got := f.xmlBuffer.String(); got != tt.want {
+			t.Errorf("type 0x%02X: got %s want %s", tt.dataType, got, tt.want)
+		}
+	}
+}
+
 func TestReadEndElement(t *testing.T) {
 	input := []uint8{
 		0x03, 0x01, // Type = RES_XML_END_ELEMENT_TYPE
@@ -253,3 +332,1874 @@ func TestReadEndElement(t *testing.T) {
 		t.Errorf("got %v want </name>", actual)
 	}
 }
+
+// testXMLAttr is an attribute for testXMLBuilder.
+type testXMLAttr struct {
+	ns       string
+	name     string
+	resID    uint32
+	raw      string
+	hasRaw   bool
+	dataType DataType
+	data     uint32
+}
+
+// testStringAttr returns an attribute that has the string value.
+func testStringAttr(ns, name string, resID uint32, value string) testXMLAttr {
+	return testXMLAttr{ns: ns, name: name, resID: resID, raw: value, hasRaw: true, dataType: TypeString}
+}
+
+// testTypedAttr returns an attribute that has the typed value.
+func testTypedAttr(ns, name string, resID uint32, dataType DataType, data uint32) testXMLAttr {
+	return testXMLAttr{ns: ns, name: name, resID: resID, dataType: dataType, data: data}
+}
+
+// testXMLBuilder builds binary XML documents for testing.
+type testXMLBuilder struct {
+	strings []string
+	index   map[string]ResStringPoolRef
+	resIDs  []uint32
+	nodes   []func(b *testXMLBuilder) []byte
+}
+
+const testAndroidNS = "http://schemas.android.com/apk/res/android"
+
+func (b *testXMLBuilder) intern(s string) ResStringPoolRef {
+	if b.index == nil {
+		b.index = make(map[string]ResStringPoolRef)
+	}
+	if ref, ok := b.index[s]; ok {
+		return ref
+	}
+	ref := ResStringPoolRef(len(b.strings))
+	b.strings = append(b.strings, s)
+	b.index[s] = ref
+	return ref
+}
+
+func (b *testXMLBuilder) ref(s string) ResStringPoolRef {
+	if s == "" {
+		return NilResStringPoolRef
+	}
+	return b.intern(s)
+}
+
+// StartNamespace appends a RES_XML_START_NAMESPACE_TYPE chunk.
+func (b *testXMLBuilder) StartNamespace(prefix, uri string) {
+	b.StartNamespaceComment(prefix, uri, "")
+}
+
+// StartNamespaceComment appends a RES_XML_START_NAMESPACE_TYPE chunk with the comment.
+func (b *testXMLBuilder) StartNamespaceComment(prefix, uri, comment string) {
+	b.nodes = append(b.nodes, func(b *testXMLBuilder) []byte {
+		return b.namespaceChunk(ResXMLStartNamespaceType, prefix, uri, comment)
+	})
+}
+
+// EndNamespace appends a RES_XML_END_NAMESPACE_TYPE chunk.
+func (b *testXMLBuilder) EndNamespace(prefix, uri string) {
+	b.nodes = append(b.nodes, func(b *testXMLBuilder) []byte {
+		return b.namespaceChunk(ResXMLEndNamespaceType, prefix, uri, "")
+	})
+}
+
+func (b *testXMLBuilder) namespaceChunk(typ ChunkType, prefix, uri, comment string) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, ResXMLTreeNode{
+		Header:     ResChunkHeader{Type: typ, HeaderSize: 16, Size: 24},
+		LineNumber: 1,
+		Comment:    b.ref(comment),
+	})
+	binary.Write(buf, binary.LittleEndian, ResXMLTreeNamespaceExt{
+		Prefix: b.ref(prefix),
+		URI:    b.ref(uri),
+	})
+	return buf.Bytes()
+}
+
+// StartElement appends a RES_XML_START_ELEMENT_TYPE chunk.
+func (b *testXMLBuilder) StartElement(ns, name string, attrs ...testXMLAttr) {
+	// the names of the attributes with resource ids must be stored
+	// at the beginning of the string pool.
+	for _, attr := range attrs {
+		if attr.resID != 0 {
+			if _, ok := b.index[attr.name]; !ok {
+				b.intern(attr.name)
+				b.resIDs = append(b.resIDs, attr.resID)
+			}
+		}
+	}
+	b.nodes = append(b.nodes, func(b *testXMLBuilder) []byte {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, ResXMLTreeNode{
+			Header: ResChunkHeader{
+				Type:       ResXMLStartElementType,
+				HeaderSize: 16,
+				Size:       uint32(16 + 20 + 20*len(attrs)),
+			},
+			LineNumber: 1,
+			Comment:    NilResStringPoolRef,
+		})
+		ext := ResXMLTreeAttrExt{
+			NS:             b.ref(ns),
+			Name:           b.ref(name),
+			AttributeStart: 20,
+			AttributeSize:  20,
+			AttributeCount: uint16(len(attrs)),
+		}
+		// index the special attributes in the same way as aapt2 does.
+		for i, attr := range attrs {
+			switch {
+			case attr.resID == 0x010100d0:
+				ext.IDIndex = uint16(i + 1)
+			case attr.ns == "" && attr.name == "class":
+				ext.ClassIndex = uint16(i + 1)
+			case attr.ns == "" && attr.name == "style":
+				ext.StyleIndex = uint16(i + 1)
+			}
+		}
+		binary.Write(buf, binary.LittleEndian, ext)
+		for _, attr := range attrs {
+			raw := NilResStringPoolRef
+			data := attr.data
+			if attr.hasRaw {
+				raw = b.intern(attr.raw)
+				data = uint32(raw)
+			}
+			binary.Write(buf, binary.LittleEndian, ResXMLTreeAttribute{
+				NS:       b.ref(attr.ns),
+				Name:     b.ref(attr.name),
+				RawValue: raw,
+				TypedValue: ResValue{
+					Size:     8,
+					DataType: attr.dataType,
+					Data:     data,
+				},
+			})
+		}
+		return buf.Bytes()
+	})
+}
+
+// EndElement appends a RES_XML_END_ELEMENT_TYPE chunk.
+func (b *testXMLBuilder) EndElement(ns, name string) {
+	b.nodes = append(b.nodes, func(b *testXMLBuilder) []byte {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, ResXMLTreeNode{
+			Header:     ResChunkHeader{Type: ResXMLEndElementType, HeaderSize: 16, Size: 24},
+			LineNumber: 1,
+			Comment:    NilResStringPoolRef,
+		})
+		binary.Write(buf, binary.LittleEndian, ResXMLTreeEndElementExt{
+			NS:   b.ref(ns),
+			Name: b.ref(name),
+		})
+		return buf.Bytes()
+	})
+}
+
+// CharData appends a RES_XML_CDATA_TYPE chunk.
+func (b *testXMLBuilder) CharData(text string) {
+	b.nodes = append(b.nodes, func(b *testXMLBuilder) []byte {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, ResXMLTreeNode{
+			Header:     ResChunkHeader{Type: ResXMLCDataType, HeaderSize: 16, Size: 28},
+			LineNumber: 1,
+			Comment:    NilResStringPoolRef,
+		})
+		binary.Write(buf, binary.LittleEndian, ResXMLTreeCdataExt{
+			Data:      b.intern(text),
+			TypedData: ResValue{Size: 8, DataType: TypeNull},
+		})
+		return buf.Bytes()
+	})
+}
+
+// Chunk appends a chunk of typ with an empty body.
+func (b *testXMLBuilder) Chunk(typ ChunkType, size uint32) {
+	b.nodes = append(b.nodes, func(b *testXMLBuilder) []byte {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, ResChunkHeader{Type: typ, HeaderSize: 8, Size: size})
+		buf.Write(make([]byte, size-8))
+		return buf.Bytes()
+	})
+}
+
+// Bytes returns the binary XML document.
+func (b *testXMLBuilder) Bytes() []byte {
+	var nodes []byte
+	for _, node := range b.nodes {
+		nodes = append(nodes, node(b)...)
+	}
+
+	body := new(bytes.Buffer)
+	body.Write(newTestStringPool(b.strings))
+	if len(b.resIDs) > 0 {
+		binary.Write(body, binary.LittleEndian, ResChunkHeader{
+			Type:       ResXMLResourceMapType,
+			HeaderSize: 8,
+			Size:       uint32(8 + 4*len(b.resIDs)),
+		})
+		binary.Write(body, binary.LittleEndian, b.resIDs)
+	}
+	body.Write(nodes)
+
+	doc := new(bytes.Buffer)
+	binary.Write(doc, binary.LittleEndian, ResChunkHeader{
+		Type:       ResXMLChunkType,
+		HeaderSize: 8,
+		Size:       uint32(8 + body.Len()),
+	})
+	doc.Write(body.Bytes())
+	return doc.Bytes()
+}
+
+// newTestStringPool returns a UTF-16 string pool chunk.
+func newTestStringPool(strs []string) []byte {
+	data := new(bytes.Buffer)
+	offsets := make([]uint32, len(strs))
+	for i, s := range strs {
+		offsets[i] = uint32(data.Len())
+		u := utf16.Encode([]rune(s))
+		binary.Write(data, binary.LittleEndian, uint16(len(u)))
+		binary.Write(data, binary.LittleEndian, u)
+		binary.Write(data, binary.LittleEndian, uint16(0))
+	}
+	for data.Len()%4 != 0 {
+		data.WriteByte(0)
+	}
+
+	headerSize := 28
+	stringStart := headerSize + 4*len(strs)
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, ResStringPoolHeader{
+		Header: ResChunkHeader{
+			Type:       ResStringPoolChunkType,
+			HeaderSize: uint16(headerSize),
+			Size:       uint32(stringStart + data.Len()),
+		},
+		StringCount: uint32(len(strs)),
+		StringStart: uint32(stringStart),
+	})
+	binary.Write(buf, binary.LittleEndian, offsets)
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+func TestNewXMLFileOptionsAttributeReference(t *testing.T) {
+	arscFile, err := os.Open("testdata/MyApplication/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arscFile.Close()
+	table, err := NewTableFile(arscFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// <LinearLayout xmlns:android="http://schemas.android.com/apk/res/android"
+	//     android:background="?attr/colorPrimary">
+	//     <TextView android:textColor="?android:attr/textColorPrimary" />
+	// </LinearLayout>
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "LinearLayout",
+		testTypedAttr(testAndroidNS, "background", 0x010100d4, TypeAttribute, 0x7F020052),
+	)
+	b.StartElement("", "TextView",
+		testTypedAttr(testAndroidNS, "textColor", 0x01010098, TypeAttribute, 0x01010036),
+		testTypedAttr(testAndroidNS, "text", 0x0101014f, TypeReference, 0x7F0B0027),
+	)
+	b.EndElement("", "TextView")
+	b.EndElement("", "LinearLayout")
+	b.EndNamespace("android", testAndroidNS)
+	data := b.Bytes()
+
+	cases := []struct {
+		opts Options
+		want string
+	}{
+		{
+			opts: Options{},
+			want: xml.Header + `<LinearLayout xmlns:android="http://schemas.android.com/apk/res/android" android:background="?0x7F020052">` +
+				`<TextView android:textColor="?0x01010036" android:text="@0x7F0B0027"></TextView></LinearLayout>`,
+		},
+		{
+			opts: Options{Table: table},
+			want: xml.Header + `<LinearLayout xmlns:android="http://schemas.android.com/apk/res/android" android:background="?attr/colorPrimary">` +
+				`<TextView android:textColor="?android:attr/textColorPrimary" android:text="@0x7F0B0027"></TextView></LinearLayout>`,
+		},
+		{
+			opts: Options{Table: table, ResourceNames: true},
+			want: xml.Header + `<LinearLayout xmlns:android="http://schemas.android.com/apk/res/android" android:background="?attr/colorPrimary">` +
+				`<TextView android:textColor="?android:attr/textColorPrimary" android:text="@string/app_name"></TextView></LinearLayout>`,
+		},
+	}
+	for _, c := range cases {
+		xmlFile, err := NewXMLFileOptions(bytes.NewReader(data), c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != c.want {
+			t.Errorf("got %s want %s", got, c.want)
+		}
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	files := []string{
+		"testdata/AndroidManifest.xml",
+		"testdata/MyApplication/AndroidManifest.xml",
+	}
+	for _, name := range files {
+		xmlFile := loadXMLTestData(t, name)
+		want, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(NewXMLStreamReader(f))
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: got %v want no error", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %s want %s", name, got, want)
+		}
+	}
+}
+
+func TestStreamReaderNamespaces(t *testing.T) {
+	// the namespaces declared on an element are rendered in declaration order.
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartNamespace("app", "http://schemas.android.com/apk/res-auto")
+	b.StartNamespace("tools", "http://schemas.android.com/tools")
+	b.StartElement("", "LinearLayout")
+	b.EndElement("", "LinearLayout")
+	b.EndNamespace("tools", "http://schemas.android.com/tools")
+	b.EndNamespace("app", "http://schemas.android.com/apk/res-auto")
+	b.EndNamespace("android", testAndroidNS)
+	data := b.Bytes()
+
+	want := xml.Header + `<LinearLayout xmlns:android="http://schemas.android.com/apk/res/android"` +
+		` xmlns:app="http://schemas.android.com/apk/res-auto" xmlns:tools="http://schemas.android.com/tools"></LinearLayout>`
+	for i := 0; i < 10; i++ {
+		xmlFile, err := NewXMLFile(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, err := ioutil.ReadAll(NewXMLStreamReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(text) != want {
+			t.Fatalf("got %s want %s", text, want)
+		}
+		if !bytes.Equal(stream, text) {
+			t.Fatalf("got %s want %s", stream, text)
+		}
+	}
+}
+
+func TestXMLFileWriteTo(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	want, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := xmlFile.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("got %d bytes want %d", n, len(want))
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %s want %s", buf.Bytes(), want)
+	}
+
+	// WriteTo doesn't consume the text.
+	buf.Reset()
+	var w io.WriterTo = xmlFile
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %s want %s", buf.Bytes(), want)
+	}
+}
+
+func TestSpecialAttributesFirst(t *testing.T) {
+	// <View xmlns:android="http://schemas.android.com/apk/res/android"
+	//     android:layout_width="match_parent" android:id="@+id/view" style="@style/Text" />
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "View",
+		testTypedAttr(testAndroidNS, "layout_width", 0x010100f4, TypeIntDec, 0xFFFFFFFF),
+		testTypedAttr(testAndroidNS, "id", 0x010100d0, TypeReference, 0x7F080001),
+		testTypedAttr("", "style", 0, TypeReference, 0x7F0C0005),
+	)
+	b.EndElement("", "View")
+	b.EndNamespace("android", testAndroidNS)
+	data := b.Bytes()
+
+	cases := []struct {
+		opts Options
+		want []string
+	}{
+		{
+			opts: Options{},
+			want: []string{"android:layout_width", "android:id", "style"},
+		},
+		{
+			opts: Options{SpecialAttributesFirst: true},
+			want: []string{"android:id", "style", "android:layout_width"},
+		},
+	}
+	for _, c := range cases {
+		xmlFile, err := NewXMLFileOptions(bytes.NewReader(data), c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := xmlFile.ElementAttributes(0); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("got %v want %v", got, c.want)
+		}
+		text, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(text), `<View xmlns:android="`+testAndroidNS+`" `+c.want[0]+"=") {
+			t.Errorf("%s doesn't start with %s", text, c.want[0])
+		}
+
+		// the stream reader renders the attributes in the same order.
+		stream, err := ioutil.ReadAll(xmlFile.streamReader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stream, text) {
+			t.Errorf("got %s want %s", stream, text)
+		}
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		xmlFile, err := NewXMLFile(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, xmlFile.Reader())
+	}
+}
+
+func BenchmarkXMLFileReset(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	xmlFile, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := xmlFile.Reset(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+		xmlFile.WriteTo(ioutil.Discard)
+	}
+}
+
+// BenchmarkStreamReader renders the same file as BenchmarkReader, which includes parsing it with NewXMLFile.
+func BenchmarkStreamReader(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := io.Copy(ioutil.Discard, NewXMLStreamReader(bytes.NewReader(data))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestXMLFileStrings(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	strs := xmlFile.Strings()
+	if len(strs) != xmlFile.StringCount() {
+		t.Errorf("got %d strings want %d", len(strs), xmlFile.StringCount())
+	}
+	for i, s := range strs {
+		if got := xmlFile.GetString(ResStringPoolRef(i)); got != s {
+			t.Errorf("%d: got %q want %q", i, got, s)
+		}
+	}
+	found := false
+	for _, s := range strs {
+		if s == "net.sorablue.shogo.FWMeasure" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("the package name is not found")
+	}
+
+	// the pool is not shared with the caller.
+	strs[0] = "modified"
+	if xmlFile.GetString(0) == "modified" {
+		t.Error("the string pool is modified")
+	}
+
+	// nil pool
+	empty := new(XMLFile)
+	if strs := empty.Strings(); strs == nil || len(strs) != 0 {
+		t.Errorf("got %#v want empty slice", strs)
+	}
+	if n := empty.StringCount(); n != 0 {
+		t.Errorf("got %d want 0", n)
+	}
+}
+
+func TestReadResourceIds(t *testing.T) {
+	cases := []struct {
+		input []uint8
+		want  []ResStringPoolRef
+	}{
+		{
+			input: []uint8{
+				0x80, 0x01, // Type = RES_XML_RESOURCE_MAP_TYPE
+				0x08, 0x00, // HeaderSize = 8 bytes
+				0x10, 0x00, 0x00, 0x00, // Size = 16 bytes
+				0x03, 0x00, 0x01, 0x01, // android:name
+				0x02, 0x00, 0x01, 0x01, // android:icon
+			},
+			want: []ResStringPoolRef{0x01010003, 0x01010002},
+		},
+		{
+			// non-default header size
+			input: []uint8{
+				0x80, 0x01, // Type = RES_XML_RESOURCE_MAP_TYPE
+				0x0C, 0x00, // HeaderSize = 12 bytes
+				0x14, 0x00, 0x00, 0x00, // Size = 20 bytes
+				0x00, 0x00, 0x00, 0x00, // extended header
+				0x03, 0x00, 0x01, 0x01, // android:name
+				0x02, 0x00, 0x01, 0x01, // android:icon
+			},
+			want: []ResStringPoolRef{0x01010003, 0x01010002},
+		},
+	}
+	for _, c := range cases {
+		sr := io.NewSectionReader(bytes.NewReader(c.input), 0, int64(len(c.input)))
+		f := new(XMLFile)
+		if err := f.readResourceIds(sr); err != nil {
+			t.Errorf("got %v want no error", err)
+			continue
+		}
+		if !reflect.DeepEqual(f.resourceIds, c.want) {
+			t.Errorf("got %v want %v", f.resourceIds, c.want)
+		}
+	}
+}
+
+func TestNewXMLFileNotXML(t *testing.T) {
+	f, err := os.Open("testdata/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = NewXMLFile(f)
+	if err == nil {
+		t.Fatal("got no error want an error")
+	}
+	if !strings.Contains(err.Error(), "not a binary XML file") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIsBinaryXML(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBinaryXML(bytes.NewReader(data)) {
+		t.Error("want binary XML")
+	}
+
+	xmlFile, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	arsc, err := ioutil.ReadFile("testdata/MyApplication/resources.arsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range [][]byte{text, arsc, {}, {0x03, 0x00}} {
+		if IsBinaryXML(bytes.NewReader(input)) {
+			t.Errorf("%.16q: want not binary XML", input)
+		}
+	}
+}
+
+func TestNewXMLFileAttributeCount(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.StartElement("", "application", testStringAttr("", "label", 0, "Example"))
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	doc := b.Bytes()
+
+	// find the first start element chunk.
+	offset := 8
+	for ChunkType(binary.LittleEndian.Uint16(doc[offset:])) != ResXMLStartElementType {
+		offset += int(binary.LittleEndian.Uint32(doc[offset+4:]))
+	}
+
+	cases := []struct {
+		name  string
+		field int // the offset of the field in ResXMLTreeAttrExt
+		value uint16
+	}{
+		// the attributes would be read from the next chunk.
+		{"inflated AttributeCount", 12, 3},
+		// the attributes would overlap each other.
+		{"small AttributeSize", 10, 8},
+	}
+	for _, c := range cases {
+		data := append([]byte{}, doc...)
+		binary.LittleEndian.PutUint16(data[offset+16+c.field:], c.value)
+		if _, err := NewXMLFile(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: got no error want an error", c.name)
+		}
+	}
+}
+
+func TestInvalidReferenceErrorContext(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.StartElement("", "application", testStringAttr(testAndroidNS, "label", 0x01010001, "Example"))
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	doc := b.Bytes()
+
+	// find the start element of the application.
+	offset := 8
+	for n := 0; ; offset += int(binary.LittleEndian.Uint32(doc[offset+4:])) {
+		if ChunkType(binary.LittleEndian.Uint16(doc[offset:])) == ResXMLStartElementType {
+			if n++; n == 2 {
+				break
+			}
+		}
+	}
+	// break the raw value of android:label.
+	binary.LittleEndian.PutUint32(doc[offset+16+20+8:], 0xFFFF)
+
+	_, err := NewXMLFile(bytes.NewReader(doc))
+	var refErr *InvalidReferenceError
+	if !errors.As(err, &refErr) {
+		t.Fatalf("got %v want InvalidReferenceError", err)
+	}
+	if refErr.Ref != 0xFFFF || refErr.Element != "application" || refErr.Attribute != "android:label" {
+		t.Errorf("unexpected error: %#v", refErr)
+	}
+	want := "androidbinary: invalid reference: 0x0000FFFF in attribute android:label of element application"
+	if err.Error() != want {
+		t.Errorf("got %q want %q", err.Error(), want)
+	}
+
+	// the error without the context.
+	err = &InvalidReferenceError{Ref: 1}
+	if got := err.Error(); got != "androidbinary: invalid reference: 0x00000001" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNewXMLFileOptionsSkipInvalidRefs(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.StartElement("", "application", testStringAttr("", "label", 0, "Example"))
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	doc := b.Bytes()
+
+	// break the raw value of the label.
+	offset := 8
+	for n := 0; ; offset += int(binary.LittleEndian.Uint32(doc[offset+4:])) {
+		if ChunkType(binary.LittleEndian.Uint16(doc[offset:])) == ResXMLStartElementType {
+			if n++; n == 2 {
+				break
+			}
+		}
+	}
+	binary.LittleEndian.PutUint32(doc[offset+16+20+8:], 0xFFFF)
+
+	if _, err := NewXMLFile(bytes.NewReader(doc)); err == nil {
+		t.Fatal("want error")
+	}
+
+	xmlFile, err := NewXMLFileOptions(bytes.NewReader(doc), Options{SkipInvalidRefs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xml.Header + `<manifest package="com.example"><application label=""></application></manifest>`
+	if got := xmlFile.xmlBuffer.String(); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	warnings := xmlFile.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("got %v want one warning", warnings)
+	}
+	var refErr *InvalidReferenceError
+	if !errors.As(warnings[0], &refErr) || refErr.Ref != 0xFFFF || refErr.Attribute != "label" {
+		t.Errorf("unexpected warning: %#v", warnings[0])
+	}
+
+	// the names are substituted by placeholders.
+	data := append([]byte{}, doc...)
+	binary.LittleEndian.PutUint32(data[offset+16+4:], 0xFFFE)    // the name of the element
+	binary.LittleEndian.PutUint32(data[offset+16+20+4:], 0xFFFD) // the name of the attribute
+	for end := offset; ; end += int(binary.LittleEndian.Uint32(data[end+4:])) {
+		if ChunkType(binary.LittleEndian.Uint16(data[end:])) == ResXMLEndElementType {
+			binary.LittleEndian.PutUint32(data[end+16+4:], 0xFFFE)
+			break
+		}
+	}
+	xmlFile, err = NewXMLFileOptions(bytes.NewReader(data), Options{SkipInvalidRefs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = xml.Header + `<manifest package="com.example"><invalid-ref-0x0000FFFE invalid-ref-0x0000FFFD=""></invalid-ref-0x0000FFFE></manifest>`
+	if got := xmlFile.xmlBuffer.String(); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	// the start tag, the attribute name, the attribute value and the end tag
+	if got := len(xmlFile.Warnings()); got != 4 {
+		t.Errorf("got %d warnings want 4", got)
+	}
+}
+
+func TestXMLFileChunks(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlFile, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := xmlFile.Chunks()
+
+	wantTypes := []ChunkType{
+		ResStringPoolChunkType,
+		ResXMLResourceMapType,
+		ResXMLStartNamespaceType,
+		ResXMLStartElementType, // manifest
+	}
+	if len(chunks) < len(wantTypes)+2 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	for i, want := range wantTypes {
+		if chunks[i].Type != want {
+			t.Errorf("%d: got type 0x%04X want 0x%04X", i, chunks[i].Type, want)
+		}
+	}
+	if got := chunks[len(chunks)-2].Type; got != ResXMLEndElementType {
+		t.Errorf("got type 0x%04X want 0x%04X", got, ResXMLEndElementType)
+	}
+	if got := chunks[len(chunks)-1].Type; got != ResXMLEndNamespaceType {
+		t.Errorf("got type 0x%04X want 0x%04X", got, ResXMLEndNamespaceType)
+	}
+
+	// the chunks are contiguous from the end of the document header to the end of the document.
+	offset := int64(8)
+	for i, c := range chunks {
+		if c.Offset != offset {
+			t.Fatalf("%d: got offset %d want %d", i, c.Offset, offset)
+		}
+		offset += c.Size
+	}
+	if want := int64(binary.LittleEndian.Uint32(data[4:])); offset != want {
+		t.Errorf("got end %d want %d", offset, want)
+	}
+}
+
+func TestNewXMLFileDocumentSize(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest", testStringAttr("", "package", 0, "com.example"))
+	b.EndElement("", "manifest")
+	doc := b.Bytes()
+
+	t.Run("trailing garbage", func(t *testing.T) {
+		garbage := new(bytes.Buffer)
+		binary.Write(garbage, binary.LittleEndian, ResChunkHeader{
+			Type:       ResXMLStartElementType,
+			HeaderSize: 8,
+			Size:       0xffff,
+		})
+		data := append(append([]byte{}, doc...), garbage.Bytes()...)
+		xmlFile, err := NewXMLFile(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := xml.Header + `<manifest package="com.example"></manifest>`
+		if string(got) != want {
+			t.Errorf("got %s want %s", got, want)
+		}
+	})
+
+	t.Run("chunk beyond the document", func(t *testing.T) {
+		// shrink the document so that the last chunk exceeds it
+		data := append([]byte{}, doc...)
+		binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-4))
+		if _, err := NewXMLFile(bytes.NewReader(data)); err == nil {
+			t.Error("got no error want an error")
+		}
+		if _, err := ioutil.ReadAll(NewXMLStreamReader(bytes.NewReader(data))); err == nil {
+			t.Error("NewXMLStreamReader: got no error want an error")
+		}
+	})
+}
+
+func TestAddNamespacePrefixResourceMap(t *testing.T) {
+	const resAutoNS = "http://schemas.android.com/apk/res-auto"
+
+	t.Run("app attribute in unknown namespace", func(t *testing.T) {
+		// the namespace chunks are stripped, e.g. by obfuscators.
+		b := new(testXMLBuilder)
+		b.StartElement("", "LinearLayout",
+			testTypedAttr(testAndroidNS, "orientation", 0x010100c4, TypeIntDec, 1),
+			testStringAttr(resAutoNS, "layout_behavior", 0x7F010000, "Behavior"),
+		)
+		b.EndElement("", "LinearLayout")
+
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		attrs := xmlFile.Root().Attrs
+		if len(attrs) != 2 {
+			t.Fatalf("got %d attributes want 2", len(attrs))
+		}
+		if attrs[0].Name != "android:orientation" {
+			t.Errorf("got %q want android:orientation", attrs[0].Name)
+		}
+		if attrs[1].Name != "layout_behavior" {
+			t.Errorf("got %q want layout_behavior", attrs[1].Name)
+		}
+	})
+
+	t.Run("prefix at index 0", func(t *testing.T) {
+		b := new(testXMLBuilder)
+		b.intern("app")
+		b.StartNamespace("app", resAutoNS)
+		b.StartElement("", "LinearLayout",
+			testStringAttr(resAutoNS, "custom", 0, "value"),
+		)
+		b.EndElement("", "LinearLayout")
+		b.EndNamespace("app", resAutoNS)
+
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := xmlFile.Root().Attrs[0].Name; got != "app:custom" {
+			t.Errorf("got %q want app:custom", got)
+		}
+	})
+
+	t.Run("mapped name overrides pooled string", func(t *testing.T) {
+		// the obfuscator renamed android:name to "a" in the string pool,
+		// and the element shares the string with the attribute.
+		b := new(testXMLBuilder)
+		b.StartNamespace("app", resAutoNS)
+		b.StartElement("", "a", testStringAttr(resAutoNS, "a", 0x01010003, "value"))
+		b.EndElement("", "a")
+		b.EndNamespace("app", resAutoNS)
+
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := xmlFile.Root()
+		if root.Name != "a" {
+			t.Errorf("got %q want a", root.Name)
+		}
+		if got := root.Attrs[0].Name; got != "app:name" {
+			t.Errorf("got %q want app:name", got)
+		}
+	})
+}
+
+func TestDecodeConcurrently(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	table := loadMyApplicationTestData(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var m Manifest
+			if err := xmlFile.Decode(&m, table, nil); err != nil {
+				t.Error(err)
+				return
+			}
+			if got, err := m.App.Label.String(); err != nil || got != "My Application" {
+				t.Errorf("got %q, %v want My Application", got, err)
+			}
+			if len(xmlFile.Find("//meta-data")) != 8 {
+				t.Error("unexpected number of meta-data")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestXMLFileReset(t *testing.T) {
+	first, err := ioutil.ReadFile("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewXMLFile(bytes.NewReader(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlFile, err := NewXMLFile(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := xmlFile.Root()
+	if err := xmlFile.Reset(bytes.NewReader(second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := xmlFile.xmlBuffer.String(); got != want.xmlBuffer.String() {
+		t.Errorf("got %q want %q", got, want.xmlBuffer.String())
+	}
+	if !reflect.DeepEqual(xmlFile.Root(), want.Root()) {
+		t.Error("the element trees are different")
+	}
+
+	// the elements read before Reset are not modified.
+	if got := root.Attrs[len(root.Attrs)-1].Value; got != "net.sorablue.shogo.FWMeasure" {
+		t.Errorf("got %q want net.sorablue.shogo.FWMeasure", got)
+	}
+
+	if err := xmlFile.Reset(bytes.NewReader([]byte("<manifest/>"))); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestXMLFileClone(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	table := loadMyApplicationTestData(t)
+	clone := xmlFile.Clone()
+
+	var want, got Manifest
+	if err := xmlFile.Decode(&want, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := clone.Decode(&got, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+
+	// modify the clone.
+	root := clone.Root()
+	root.Attrs[0].Value = "modified"
+	root.Children = root.Children[:0]
+	clone.stringPool.Strings[0] = "modified"
+	clone.xmlBuffer.Reset()
+
+	orig := xmlFile.Root()
+	if orig.Attrs[0].Value == "modified" {
+		t.Error("the attributes of the original are modified")
+	}
+	if len(orig.Children) == 0 {
+		t.Error("the children of the original are modified")
+	}
+	if xmlFile.GetString(0) == "modified" {
+		t.Error("the string pool of the original is modified")
+	}
+	for _, child := range orig.Children {
+		if child.Parent != orig {
+			t.Error("the parent of the children is not the original")
+		}
+	}
+	var m Manifest
+	if err := xmlFile.Decode(&m, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %#v want %#v", m, want)
+	}
+}
+
+func TestCharDataWhitespace(t *testing.T) {
+	texts := []string{
+		"  spaced  ",
+		"\n\tindented\r\n",
+		"\r",
+		" <&> ",
+	}
+	for _, text := range texts {
+		b := new(testXMLBuilder)
+		b.StartElement("", "string")
+		b.CharData(text)
+		b.EndElement("", "string")
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v struct {
+			Text string `xml:",chardata"`
+		}
+		if err := xmlFile.Decode(&v, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if v.Text != text {
+			t.Errorf("got %q want %q", v.Text, text)
+		}
+
+		// the stream reader renders the same text.
+		stream, err := ioutil.ReadAll(xmlFile.streamReader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		full, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stream) != string(full) {
+			t.Errorf("got %q want %q", stream, full)
+		}
+	}
+}
+
+func TestSelfClosingTags(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest")
+	b.StartElement("", "uses-permission", testStringAttr("", "name", 0, "android.permission.INTERNET"))
+	b.EndElement("", "uses-permission")
+	b.StartElement("", "application")
+	b.CharData("text")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	data := b.Bytes()
+
+	cases := []struct {
+		opts Options
+		want string
+	}{
+		{
+			opts: Options{},
+			want: xml.Header + `<manifest><uses-permission name="android.permission.INTERNET"></uses-permission><application>text</application></manifest>`,
+		},
+		{
+			opts: Options{SelfClosingTags: true},
+			want: xml.Header + `<manifest><uses-permission name="android.permission.INTERNET"/><application>text</application></manifest>`,
+		},
+	}
+	for _, c := range cases {
+		xmlFile, err := NewXMLFileOptions(bytes.NewReader(data), c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(xmlFile.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != c.want {
+			t.Errorf("got %q want %q", got, c.want)
+		}
+		stream, err := ioutil.ReadAll(xmlFile.streamReader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stream) != c.want {
+			t.Errorf("got %q want %q", stream, c.want)
+		}
+	}
+}
+
+func TestSelfClosingTagsDecode(t *testing.T) {
+	f, err := os.Open("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	xmlFile, err := NewXMLFileOptions(f, Options{SelfClosingTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), `<uses-permission android:name="android.permission.INTERNET"/>`) {
+		t.Error("uses-permission is not self-closing")
+	}
+
+	var got, want XMLManifest
+	if err := xmlFile.Decode(&got, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadXMLTestData(t, "testdata/AndroidManifest.xml").Decode(&want, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+}
+
+func TestUnknownChunks(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	if chunks := xmlFile.UnknownChunks(); chunks != nil {
+		t.Errorf("got %v want nil", chunks)
+	}
+
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest")
+	b.Chunk(0x0150, 16)
+	b.Chunk(0x0105, 8)
+	b.Chunk(0x0150, 12)
+	b.Chunk(0x0300, 8) // not an XML tree chunk
+	b.StartElement("", "application")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint16{0x0150, 0x0105}
+	if got := xmlFile.UnknownChunks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#x want %#x", got, want)
+	}
+	if elems := xmlFile.Find("/manifest/application"); len(elems) != 1 {
+		t.Errorf("got %d elements want 1", len(elems))
+	}
+}
+
+func TestOmitXMLDeclaration(t *testing.T) {
+	f, err := os.Open("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	xmlFile, err := NewXMLFileOptions(f, Options{OmitXMLDeclaration: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(text, []byte("<manifest ")) {
+		t.Errorf("unexpected prefix: %q", text[:20])
+	}
+	stream, err := ioutil.ReadAll(xmlFile.streamReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stream, text) {
+		t.Errorf("got %q want %q", stream, text)
+	}
+
+	// the default output has the declaration.
+	text, err = ioutil.ReadAll(loadXMLTestData(t, "testdata/AndroidManifest.xml").Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(text, []byte(xml.Header)) {
+		t.Errorf("unexpected prefix: %q", text[:20])
+	}
+}
+
+func TestColorAttributes(t *testing.T) {
+	cases := []struct {
+		dataType DataType
+		data     uint32
+		want     string
+	}{
+		{TypeIntColorARGB4, 0xf00f, "#ff0000ff"},
+		{TypeIntColorARGB4, 0x8abc, "#88aabbcc"},
+		{TypeIntColorRGB4, 0xf00, "#ffff0000"},
+		{TypeIntColorARGB8, 0x80ff0000, "#80ff0000"},
+		{TypeIntColorRGB8, 0xff0000, "#ffff0000"},
+		// the short forms already expanded by aapt
+		{TypeIntColorARGB4, 0xff0000ff, "#ff0000ff"},
+		{TypeIntColorRGB4, 0xffff0000, "#ffff0000"},
+	}
+	for _, c := range cases {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "color", testTypedAttr(testAndroidNS, "textColor", 0x01010098, c.dataType, c.data))
+		b.EndElement("", "color")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := xmlFile.Root().Attr("android:textColor"); got != c.want {
+			t.Errorf("%#x(%#x): got %q want %q", c.data, c.dataType, got, c.want)
+		}
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	table := loadMyApplicationTestData(t)
+	newXMLFile := func(label uint32) *XMLFile {
+		b := new(testXMLBuilder)
+		b.StartNamespace("android", testAndroidNS)
+		b.StartElement("", "application", testTypedAttr(testAndroidNS, "label", 0x01010001, TypeReference, label))
+		b.EndElement("", "application")
+		b.EndNamespace("android", testAndroidNS)
+		xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return xmlFile
+	}
+	type application struct {
+		Label String `xml:"http://schemas.android.com/apk/res/android label,attr"`
+	}
+
+	// the reference is resolved.
+	var app application
+	if err := newXMLFile(0x7F0B0027).DecodeStrict(&app, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.Label.MustString(); got != "My Application" {
+		t.Errorf("got %q want My Application", got)
+	}
+
+	// the dangling reference is reported by DecodeStrict, but not by Decode.
+	xmlFile := newXMLFile(0x7F0BFFFF)
+	if err := xmlFile.DecodeStrict(&app, table, nil); err == nil {
+		t.Error("want error")
+	} else if !strings.Contains(err.Error(), "@0x7F0BFFFF") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := app.Label.Raw(); got != "@0x7F0BFFFF" {
+		t.Errorf("got %q want @0x7F0BFFFF", got)
+	}
+	if err := xmlFile.Decode(&app, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Label.String(); err == nil {
+		t.Error("want error")
+	}
+
+	// the reference to a style is not dangling, and it is kept as it is.
+	var m Manifest
+	if err := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml").DecodeStrict(&m, table, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.App.Theme.MustString(); got != "@0x7F0C0005" {
+		t.Errorf("got %q want @0x7F0C0005", got)
+	}
+}
+
+func TestDecodeRaw(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/MyApplication/AndroidManifest.xml")
+	var m Manifest
+	if err := xmlFile.DecodeRaw(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.App.Label.Raw(); got != "@0x7F0B0027" {
+		t.Errorf("got %q want @0x7F0B0027", got)
+	}
+	if _, err := m.App.Label.String(); err == nil {
+		t.Error("want error, the reference can't be resolved without the table")
+	}
+	if got := m.Package.MustString(); got != "com.shogo82148.androidbinary.myapplication" {
+		t.Errorf("got %q want com.shogo82148.androidbinary.myapplication", got)
+	}
+}
+
+func TestEmojiAttribute(t *testing.T) {
+	// <application android:label="Emoji 😀 App" />
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "application",
+		testStringAttr(testAndroidNS, "label", 0x01010001, "Emoji \U0001F600 App"),
+	)
+	b.EndElement("", "application")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := xmlFile.Root().Attr("android:label"); got != "Emoji \U0001F600 App" {
+		t.Errorf("got %q want %q", got, "Emoji \U0001F600 App")
+	}
+	text, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(text, []byte("android:label=\"Emoji \U0001F600 App\"")) {
+		t.Errorf("got %s", text)
+	}
+}
+
+func TestNewXMLFileWithoutStringPool(t *testing.T) {
+	// an empty document.
+	empty := new(bytes.Buffer)
+	binary.Write(empty, binary.LittleEndian, ResChunkHeader{Type: ResXMLChunkType, HeaderSize: 8, Size: 8})
+
+	// a document that has elements, but no string pool.
+	b := new(testXMLBuilder)
+	b.StartElement("", "manifest")
+	b.EndElement("", "manifest")
+	data := b.Bytes()
+	poolSize := binary.LittleEndian.Uint32(data[12:])
+	noPool := append(append([]byte(nil), data[:8]...), data[8+poolSize:]...)
+	binary.LittleEndian.PutUint32(noPool[4:], uint32(len(noPool)))
+
+	for _, data := range [][]byte{empty.Bytes(), noPool} {
+		if _, err := NewXMLFile(bytes.NewReader(data)); err == nil {
+			t.Error("want error")
+		}
+	}
+
+	// HasString and GetString are safe without the string pool.
+	if new(XMLFile).HasString(0) {
+		t.Error("got true want false")
+	}
+	if got := new(XMLFile).GetString(0); got != "" {
+		t.Errorf("got %q want empty", got)
+	}
+	table := new(TableFile)
+	if got := table.GetString(0); got != "" {
+		t.Errorf("got %q want empty", got)
+	}
+	if got := table.GetStyledString(0); got != "" {
+		t.Errorf("got %q want empty", got)
+	}
+}
+
+func TestNamespaceComment(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespaceComment("android", testAndroidNS, "generated -- do not edit-")
+	b.StartElement("", "manifest", testStringAttr(testAndroidNS, "versionName", 0x0101021c, "1.0"))
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	data := b.Bytes()
+
+	xmlFile, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xml.Header + `<!--generated - - do not edit- --><manifest xmlns:android="` + testAndroidNS + `" android:versionName="1.0"></manifest>`
+	got, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	stream, err := ioutil.ReadAll(xmlFile.streamReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stream) != want {
+		t.Errorf("got %q want %q", stream, want)
+	}
+
+	var manifest struct {
+		VersionName string `xml:"http://schemas.android.com/apk/res/android versionName,attr"`
+	}
+	if err := xmlFile.Decode(&manifest, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.VersionName != "1.0" {
+		t.Errorf("got %q want %q", manifest.VersionName, "1.0")
+	}
+}
+
+// testOnlyReader hides the methods other than Read, e.g. ReadAt of bytes.Reader.
+type testOnlyReader struct {
+	r io.Reader
+}
+
+func (r testOnlyReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestNewXMLFileFromReader(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewXMLFileFromReader(testOnlyReader{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantText, err := ioutil.ReadAll(want.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotText, err := ioutil.ReadAll(got.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotText, wantText) {
+		t.Errorf("got %q want %q", gotText, wantText)
+	}
+
+	var gotManifest, wantManifest XMLManifest
+	if err := got.Decode(&gotManifest, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := want.Decode(&wantManifest, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotManifest, wantManifest) {
+		t.Errorf("got %#v want %#v", gotManifest, wantManifest)
+	}
+
+	// the errors are the same as NewXMLFile.
+	if _, err := NewXMLFileFromReader(testOnlyReader{strings.NewReader("<manifest/>")}); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestNewXMLFileFromText(t *testing.T) {
+	want := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	text, err := ioutil.ReadAll(want.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewXMLFileFromText(bytes.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotText, err := ioutil.ReadAll(got.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotText, text) {
+		t.Errorf("got %q want %q", gotText, text)
+	}
+
+	// the tree is the same as the one of the binary XML file.
+	var compare func(got, want *XMLElement)
+	compare = func(got, want *XMLElement) {
+		if got.Name != want.Name || got.Namespace != want.Namespace {
+			t.Errorf("got %s (%s) want %s (%s)", got.Name, got.Namespace, want.Name, want.Namespace)
+		}
+		if !reflect.DeepEqual(got.Attrs, want.Attrs) {
+			t.Errorf("%s: got %v want %v", want.Name, got.Attrs, want.Attrs)
+		}
+		if !reflect.DeepEqual(got.Namespaces, want.Namespaces) {
+			t.Errorf("%s: got %v want %v", want.Name, got.Namespaces, want.Namespaces)
+		}
+		if len(got.Children) != len(want.Children) {
+			t.Errorf("%s: got %d children want %d", want.Name, len(got.Children), len(want.Children))
+			return
+		}
+		for i := range want.Children {
+			compare(got.Children[i], want.Children[i])
+		}
+	}
+	compare(got.Root(), want.Root())
+
+	var gotManifest, wantManifest XMLManifest
+	if err := got.Decode(&gotManifest, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := want.Decode(&wantManifest, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotManifest, wantManifest) {
+		t.Errorf("got %#v want %#v", gotManifest, wantManifest)
+	}
+
+	for _, s := range []string{"", "<manifest>", "<manifest></application>", "<manifest/><application>"} {
+		if _, err := NewXMLFileFromText(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+func TestDecodeBoolean(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest")
+	b.StartElement("", "application",
+		testTypedAttr(testAndroidNS, "debuggable", 0x0101000f, TypeIntBoolean, 0xFFFFFFFF),
+		testTypedAttr(testAndroidNS, "allowBackup", 0x01010280, TypeIntBoolean, 0),
+	)
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := xmlFile.DecodeManifest(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := manifest.App.Debuggable.Bool(); err != nil || !got {
+		t.Errorf("debuggable: got %v, %v want true", got, err)
+	}
+	if got, err := manifest.App.AllowBackup.Bool(); err != nil || got {
+		t.Errorf("allowBackup: got %v, %v want false", got, err)
+	}
+}
+
+func TestReferencedResourceIDs(t *testing.T) {
+	xmlFile := loadXMLTestData(t, "testdata/AndroidManifest.xml")
+	want := []ResID{0x7F020000, 0x7F040000} // @drawable/icon and @string/app_name
+	if got := xmlFile.ReferencedResourceIDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "manifest")
+	b.StartElement("", "application",
+		testTypedAttr(testAndroidNS, "theme", 0x01010000, TypeReference, 0x7F0C0005),
+		testTypedAttr(testAndroidNS, "label", 0x01010001, TypeReference, 0x7F0B0027),
+		testTypedAttr(testAndroidNS, "icon", 0x01010002, TypeDynamicReference, 0x02020000),
+		testTypedAttr(testAndroidNS, "logo", 0x010102be, TypeReference, 0), // @null
+	)
+	b.StartElement("", "activity",
+		testTypedAttr(testAndroidNS, "label", 0x01010001, TypeReference, 0x7F0B0027),
+		testTypedAttr(testAndroidNS, "theme", 0x01010000, TypeAttribute, 0x01010036),
+		testTypedAttr(testAndroidNS, "enabled", 0x0101000e, TypeIntBoolean, 0xFFFFFFFF),
+		testStringAttr(testAndroidNS, "name", 0x01010003, ".MainActivity"),
+	)
+	b.EndElement("", "activity")
+	b.EndElement("", "application")
+	b.EndElement("", "manifest")
+	b.EndNamespace("android", testAndroidNS)
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ResID{0x01010036, 0x02020000, 0x7F0B0027, 0x7F0C0005}
+	if got := xmlFile.ReferencedResourceIDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestLazyText(t *testing.T) {
+	table := loadMyApplicationTestData(t)
+	for _, name := range []string{"testdata/AndroidManifest.xml", "testdata/MyApplication/AndroidManifest.xml"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := NewXMLFile(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewXMLFileOptions(bytes.NewReader(data), Options{LazyText: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the struct fields are decoded from the element tree.
+		var gotManifest, wantManifest XMLManifest
+		if err := got.Decode(&gotManifest, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got.xmlBuffer.Len() != 0 {
+			t.Errorf("%s: the text is rendered by Decode", name)
+		}
+		if err := want.Decode(&wantManifest, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotManifest, wantManifest) {
+			t.Errorf("%s: got %#v want %#v", name, gotManifest, wantManifest)
+		}
+		gotDecoded, err := got.DecodeManifest(table, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantDecoded, err := want.DecodeManifest(table, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotDecoded, wantDecoded) {
+			t.Errorf("%s: got %#v want %#v", name, gotDecoded, wantDecoded)
+		}
+
+		// the text is rendered on demand.
+		wantText, err := ioutil.ReadAll(want.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotText, err := ioutil.ReadAll(got.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(gotText, wantText) {
+			t.Errorf("%s: got %s want %s", name, gotText, wantText)
+		}
+		buf := new(bytes.Buffer)
+		if _, err := got.Clone().WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), wantText) {
+			t.Errorf("%s: got %s want %s", name, buf.Bytes(), wantText)
+		}
+	}
+}
+
+// testClosableReaderAt is an io.ReaderAt that fails after it is closed.
+type testClosableReaderAt struct {
+	r      *bytes.Reader
+	closed bool
+}
+
+func (r *testClosableReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	return r.r.ReadAt(p, off)
+}
+
+func TestLazyTextClosedReader(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/AndroidManifest.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadAll(loadXMLTestData(t, "testdata/AndroidManifest.xml").Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testClosableReaderAt{r: bytes.NewReader(data)}
+	xmlFile, err := NewXMLFileOptions(r, Options{LazyText: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := xmlFile.Clone()
+	r.closed = true
+
+	// the text is rendered from the bytes kept while parsing, without reading r again.
+	for _, f := range []*XMLFile{xmlFile, clone} {
+		buf := new(bytes.Buffer)
+		if _, err := f.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("got %s want %s", buf.Bytes(), want)
+		}
+		got, err := ioutil.ReadAll(f.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("got %s want %s", got, want)
+		}
+	}
+
+	// the file that can't be read is reported by NewXMLFileOptions.
+	if _, err := NewXMLFileOptions(r, Options{LazyText: true}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("want os.ErrClosed: %v", err)
+	}
+}
+
+func TestLazyTextCharData(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "resources")
+	b.StartElement("", "string", testStringAttr("", "name", 0, "app_name"))
+	b.CharData("My Application")
+	b.EndElement("", "string")
+	b.StartElement("", "string", testStringAttr("", "name", 0, "empty"))
+	b.EndElement("", "string")
+	b.EndElement("", "resources")
+	b.EndNamespace("android", testAndroidNS)
+	data := b.Bytes()
+
+	type resources struct {
+		Strings []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"string"`
+	}
+	var want, got resources
+	xmlFile, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xmlFile.Decode(&want, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	xmlFile, err = NewXMLFileOptions(bytes.NewReader(data), Options{LazyText: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xmlFile.Decode(&got, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+	if len(got.Strings) != 2 || got.Strings[0].Value != "My Application" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	benchmarkDecode(b, Options{})
+}
+
+func BenchmarkDecodeLazyText(b *testing.B) {
+	benchmarkDecode(b, Options{LazyText: true})
+}
+
+func benchmarkDecode(b *testing.B, opts Options) {
+	data, err := ioutil.ReadFile("testdata/MyApplication/AndroidManifest.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		xmlFile, err := NewXMLFileOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var manifest Manifest
+		if err := xmlFile.Decode(&manifest, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestValueFormatter(t *testing.T) {
+	b := new(testXMLBuilder)
+	b.StartNamespace("android", testAndroidNS)
+	b.StartElement("", "View",
+		testTypedAttr(testAndroidNS, "background", 0x010100d4, TypeIntColorRGB8, 0x00ff00),
+		testTypedAttr(testAndroidNS, "visibility", 0x010100dc, TypeIntDec, 1),
+		testTypedAttr(testAndroidNS, "text", 0x0101014f, TypeReference, 0x7F010000),
+		testStringAttr(testAndroidNS, "contentDescription", 0x01010273, "@string/raw"),
+	)
+	b.EndElement("", "View")
+	b.EndNamespace("android", testAndroidNS)
+
+	// the colors are rendered as rgb(r, g, b), and the reference of android:text is rendered as its name.
+	formatter := func(attr XMLAttr, value ResValue) (string, bool) {
+		switch {
+		case value.DataType == TypeIntColorRGB8:
+			data := value.Data
+			return fmt.Sprintf("rgb(%d, %d, %d)", data>>16&0xff, data>>8&0xff, data&0xff), true
+		case attr.Namespace == testAndroidNS && attr.Name == "android:text" && value.DataType == TypeReference:
+			return "@string/label", true
+		case attr.Name == "android:contentDescription":
+			// the strings are rendered as usual.
+			return "[" + attr.Value + "]", true
+		}
+		return "", false
+	}
+	xmlFile, err := NewXMLFileOptions(bytes.NewReader(b.Bytes()), Options{ValueFormatter: formatter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := xml.Header + `<View xmlns:android="` + testAndroidNS + `" android:background="rgb(0, 255, 0)" android:visibility="1"` +
+		` android:text="@string/label" android:contentDescription="[@string/raw]"></View>`
+	got, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if v, _ := xmlFile.Root().Attr("android:background"); v != "rgb(0, 255, 0)" {
+		t.Errorf("got %q want rgb(0, 255, 0)", v)
+	}
+}
+
+func TestAttributeEscape(t *testing.T) {
+	const value = "say \"hi\" & 'bye'\n\t<b>\r\n end "
+	b := new(testXMLBuilder)
+	b.StartElement("", "string",
+		testStringAttr("", "value", 0, value),
+		testStringAttr("", "broken", 0, "a\x01b\xffc"),
+	)
+	b.EndElement("", "string")
+	xmlFile, err := NewXMLFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := ioutil.ReadAll(xmlFile.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xml.Header + `<string value="say &quot;hi&quot; &amp; &apos;bye&apos;&#xA;&#x9;&lt;b&gt;&#xD;&#xA; end " broken="a` + "�" + `b` + "�" + `c"></string>`
+	if string(text) != want {
+		t.Errorf("got %q want %q", text, want)
+	}
+
+	// encoding/xml reads the values as they are.
+	var got struct {
+		Value  string `xml:"value,attr"`
+		Broken string `xml:"broken,attr"`
+	}
+	if err := xml.Unmarshal(text, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != value {
+		t.Errorf("got %q want %q", got.Value, value)
+	}
+	if want := "a�b�c"; got.Broken != want {
+		t.Errorf("got %q want %q", got.Broken, want)
+	}
+}